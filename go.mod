@@ -42,6 +42,7 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	golang.org/x/term v0.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
+	rsc.io/qr v0.2.0 // indirect
 )
 
 require (
@@ -49,6 +50,7 @@ require (
 	github.com/andygrunwald/vdf v1.1.0
 	github.com/clausecker/nfc/v2 v2.1.4
 	github.com/ebfe/scard v0.0.0-20230420082256-7db3f9b7c8a7
+	github.com/eclipse/paho.mqtt.golang v1.5.0
 	github.com/gdamore/tcell/v2 v2.8.1
 	github.com/getlantern/systray v1.2.2
 	github.com/go-chi/chi/v5 v5.0.12
@@ -58,6 +60,7 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/hsanjuan/go-ndef v0.0.1
+	github.com/mdp/qrterminal/v3 v3.2.0
 	github.com/olahol/melody v1.2.1
 	github.com/pelletier/go-toml/v2 v2.2.3
 	github.com/rivo/tview v0.0.0-20241227133733-17b7edb88c57
@@ -66,6 +69,7 @@ require (
 	github.com/wizzomafizzo/mrext v0.1.3
 	go.bug.st/serial v1.6.2
 	go.etcd.io/bbolt v1.3.9
+	golang.org/x/crypto v0.36.0
 	golang.org/x/sync v0.12.0
 	golang.org/x/text v0.23.0
 )
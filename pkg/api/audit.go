@@ -0,0 +1,154 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// auditPruneInterval is how often expired entries are removed from the
+// audit log.
+const auditPruneInterval = time.Hour
+
+// paramsDigest returns the hex SHA-256 of a request's raw params.
+func paramsDigest(params []byte) string {
+	if len(params) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(params)
+	return hex.EncodeToString(sum[:])
+}
+
+// readOnlyMethod returns true if a method only reads state, so successful
+// requests to it aren't worth recording.
+func readOnlyMethod(method string) bool {
+	scope, ok := methodScopes[method]
+	return ok && (scope == "" || scope == models.ScopeRead)
+}
+
+// auditRequest records a handled API request in the audit log. Successful
+// requests to read-only methods are skipped.
+func auditRequest(env requests.RequestEnv, req models.RequestObject, rpcError *models.ErrorObject) {
+	if env.Database == nil || env.Config == nil || !env.Config.AuditEnabled() {
+		return
+	}
+
+	if rpcError == nil && readOnlyMethod(req.Method) {
+		return
+	}
+
+	entry := database.AuditEntry{
+		Time:         time.Now(),
+		Method:       req.Method,
+		Address:      env.Address,
+		Local:        env.IsLocal,
+		ParamsDigest: paramsDigest(req.Params),
+		Ok:           rpcError == nil,
+	}
+
+	if env.Client != nil {
+		id := env.Client.ID
+		entry.ClientID = &id
+		entry.ClientName = env.Client.Name
+	}
+
+	if rpcError != nil {
+		entry.ErrorCode = rpcError.Code
+	}
+
+	err := env.Database.AddAuditEntry(entry)
+	if err != nil {
+		log.Error().Err(err).Msg("error adding audit entry")
+	}
+}
+
+// restErrorCode returns the API error code closest to the HTTP status of a
+// failed REST request.
+func restErrorCode(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return models.ErrorCodeInvalidParams
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return models.ErrorCodeNotAllowed
+	default:
+		return models.ErrorCodeGeneric
+	}
+}
+
+// auditRest wraps a REST run endpoint to record its requests in the audit
+// log, as a run request with the token text as its params.
+func auditRest(cfg *config.Instance, db *database.Database, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next(ww, r)
+
+		if !cfg.AuditEnabled() {
+			return
+		}
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		ip := clientIp(r.RemoteAddr)
+		entry := database.AuditEntry{
+			Time:         time.Now(),
+			Method:       models.MethodRun,
+			Address:      ip.String(),
+			Local:        ip.IsLoopback(),
+			ParamsDigest: paramsDigest([]byte(chi.URLParam(r, "*"))),
+			Ok:           status < http.StatusBadRequest,
+		}
+		if !entry.Ok {
+			entry.ErrorCode = restErrorCode(status)
+		}
+
+		err := db.AddAuditEntry(entry)
+		if err != nil {
+			log.Error().Err(err).Msg("error adding audit entry")
+		}
+	}
+}
+
+// pruneAudit periodically removes audit log entries older than the
+// configured retention, until the service context is cancelled.
+func pruneAudit(cfg *config.Instance, st *state.State, db *database.Database) {
+	prune := func() {
+		retention := cfg.AuditRetention()
+		if retention == 0 {
+			return
+		}
+
+		n, err := db.PruneAudit(time.Now().Add(-retention))
+		if err != nil {
+			log.Error().Err(err).Msg("error pruning audit log")
+		} else if n > 0 {
+			log.Debug().Msgf("pruned %d audit log entries", n)
+		}
+	}
+
+	prune()
+
+	ticker := time.NewTicker(auditPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-st.GetContext().Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
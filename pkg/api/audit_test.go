@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRequest(t *testing.T) {
+	cfg, err := config.NewConfig(t.TempDir(), config.BaseDefaults)
+	require.NoError(t, err)
+	db := testDatabase(t)
+
+	env := requests.RequestEnv{
+		Config:   cfg,
+		Database: db,
+		Address:  "192.168.1.10",
+	}
+
+	auditRequest(env, models.RequestObject{Method: models.MethodMedia}, nil)
+	auditRequest(env, models.RequestObject{Method: models.MethodVersion}, nil)
+	auditRequest(env, models.RequestObject{Method: models.MethodRun, Params: []byte(`"a"`)}, nil)
+	auditRequest(env, models.RequestObject{Method: models.MethodMedia}, &models.ErrorObject{
+		Code: models.ErrorCodeNotAllowed,
+	})
+
+	entries, err := db.GetAudit(database.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	// newest first, successful reads aren't recorded
+	assert.Equal(t, models.MethodMedia, entries[0].Method)
+	assert.False(t, entries[0].Ok)
+	assert.Equal(t, models.ErrorCodeNotAllowed, entries[0].ErrorCode)
+	assert.Equal(t, models.MethodRun, entries[1].Method)
+	assert.True(t, entries[1].Ok)
+	assert.Equal(t, paramsDigest([]byte(`"a"`)), entries[1].ParamsDigest)
+}
+
+func TestAuditRest(t *testing.T) {
+	cfg, err := config.NewConfig(t.TempDir(), config.BaseDefaults)
+	require.NoError(t, err)
+	db := testDatabase(t)
+
+	r := chi.NewRouter()
+	r.Get("/run/*", auditRest(cfg, db, func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "*") == "blocked" {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	}))
+
+	for _, path := range []string{"/run/**launch.system:snes", "/run/blocked"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "127.0.0.1:1234"
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	entries, err := db.GetAudit(database.AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, models.MethodRun, entries[0].Method)
+	assert.False(t, entries[0].Ok)
+	assert.Equal(t, models.ErrorCodeNotAllowed, entries[0].ErrorCode)
+	assert.Equal(t, paramsDigest([]byte("blocked")), entries[0].ParamsDigest)
+
+	assert.True(t, entries[1].Ok)
+	assert.True(t, entries[1].Local)
+	assert.Equal(t, "127.0.0.1", entries[1].Address)
+	assert.Equal(t, paramsDigest([]byte("**launch.system:snes")), entries[1].ParamsDigest)
+}
@@ -0,0 +1,210 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CertFile     = "api.crt"
+	KeyFile      = "api.key"
+	CertValidity = 10 * 365 * 24 * time.Hour
+)
+
+// Certificate is the TLS certificate used by the API server.
+type Certificate struct {
+	tls.Certificate
+	Leaf       *x509.Certificate
+	SelfSigned bool
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate, as
+// colon-separated upper case hex pairs.
+func (c Certificate) Fingerprint() string {
+	return Fingerprint(c.Leaf.Raw)
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded certificate.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+var ErrIncompleteFiles = errors.New("tls_cert and tls_key must both be set")
+
+// Paths returns the certificate and key file paths used by the API. If
+// neither is set in the config, the self-signed certificate in the data
+// directory is used. Setting only one of them is an error.
+func Paths(pl platforms.Platform, cfg *config.Instance) (string, string, bool, error) {
+	certPath, keyPath := cfg.ApiTlsFiles()
+	if certPath != "" && keyPath != "" {
+		return certPath, keyPath, false, nil
+	} else if certPath != "" || keyPath != "" {
+		return "", "", false, ErrIncompleteFiles
+	}
+	return filepath.Join(pl.DataDir(), CertFile), filepath.Join(pl.DataDir(), KeyFile), true, nil
+}
+
+// Load returns the API's TLS certificate. A self-signed certificate is
+// generated and saved to the data directory if one doesn't exist yet, or if
+// the existing one has expired.
+func Load(pl platforms.Platform, cfg *config.Instance) (Certificate, error) {
+	certPath, keyPath, selfSigned, err := Paths(pl, cfg)
+	if err != nil {
+		return Certificate{}, err
+	}
+
+	if selfSigned {
+		err = ensureSelfSigned(certPath, keyPath)
+		if err != nil {
+			return Certificate{}, fmt.Errorf("error generating certificate: %w", err)
+		}
+	}
+
+	kp, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return Certificate{}, fmt.Errorf("error loading certificate: %w", err)
+	}
+
+	leaf, err := x509.ParseCertificate(kp.Certificate[0])
+	if err != nil {
+		return Certificate{}, fmt.Errorf("error parsing certificate: %w", err)
+	}
+	kp.Leaf = leaf
+
+	return Certificate{
+		Certificate: kp,
+		Leaf:        leaf,
+		SelfSigned:  selfSigned,
+	}, nil
+}
+
+func ensureSelfSigned(certPath string, keyPath string) error {
+	data, err := os.ReadFile(certPath)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block != nil {
+			leaf, err := x509.ParseCertificate(block.Bytes)
+			if err == nil && !leaf.IsCA && time.Now().Before(leaf.NotAfter) {
+				if _, err := os.Stat(keyPath); err == nil {
+					return nil
+				}
+			}
+		}
+		log.Warn().Msgf("replacing invalid, expired or CA certificate: %s", certPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	log.Info().Msgf("generating self-signed certificate: %s", certPath)
+	certPem, keyPem, err := GenerateSelfSigned(time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(keyPath, keyPem, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(certPath, certPem, 0644)
+}
+
+// hostAddresses returns the hostname and IP addresses of all network
+// interfaces, to be included in the certificate.
+func hostAddresses() ([]string, []net.IP) {
+	names := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		names = append(names, hostname)
+		if !strings.Contains(hostname, ".") {
+			names = append(names, hostname+".local")
+		}
+	}
+
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		log.Warn().Err(err).Msg("error listing interface addresses")
+		return names, ips
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		ips = append(ips, ipNet.IP)
+	}
+
+	return names, ips
+}
+
+// GenerateSelfSigned creates a new self-signed certificate and private key
+// for the API server, PEM encoded. The certificate is a server leaf, not a
+// CA, so trusting it can't be used to sign certificates for other hosts.
+func GenerateSelfSigned(now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names, ips := hostAddresses()
+
+	tmpl := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Zaparoo Core",
+			Organization: []string{"Zaparoo"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(CertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+		DNSNames:              names,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	return certPem, keyPem, nil
+}
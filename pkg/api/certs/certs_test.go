@@ -0,0 +1,166 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, CertFile)
+	keyPath := filepath.Join(dir, KeyFile)
+
+	require.NoError(t, ensureSelfSigned(certPath, keyPath))
+	first, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+
+	// existing valid certificate is kept
+	require.NoError(t, ensureSelfSigned(certPath, keyPath))
+	second, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	info, err := os.Stat(keyPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestFingerprint(t *testing.T) {
+	fp := Fingerprint([]byte("test"))
+	assert.Len(t, fp, 32*3-1)
+	assert.Equal(t, "9F:86:D0", fp[:8])
+}
+
+func TestGenerateSelfSigned(t *testing.T) {
+	now := time.Now()
+	certPem, keyPem, err := GenerateSelfSigned(now)
+	require.NoError(t, err)
+
+	_, err = tls.X509KeyPair(certPem, keyPem)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(certPem)
+	require.NotNil(t, block)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	assert.False(t, leaf.IsCA)
+	assert.True(t, leaf.BasicConstraintsValid)
+	assert.Equal(t,
+		x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment,
+		leaf.KeyUsage,
+	)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, leaf.ExtKeyUsage)
+	assert.Contains(t, leaf.DNSNames, "localhost")
+	assert.True(t, leaf.NotAfter.After(now.Add(CertValidity-time.Minute)))
+}
+
+func TestEnsureSelfSignedReplacesCA(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, CertFile)
+	keyPath := filepath.Join(dir, KeyFile)
+
+	// certificates generated by older versions were marked as a CA
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(certPath, ca, 0644))
+	require.NoError(t, os.WriteFile(keyPath, []byte("key"), 0600))
+
+	require.NoError(t, ensureSelfSigned(certPath, keyPath))
+	replaced, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	assert.NotEqual(t, ca, replaced)
+}
+
+type testPlatform struct {
+	platforms.Platform
+	dataDir string
+}
+
+func (p testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func TestPaths(t *testing.T) {
+	pl := testPlatform{dataDir: "/data"}
+
+	tests := []struct {
+		name           string
+		cert           string
+		key            string
+		wantCert       string
+		wantKey        string
+		wantSelfSigned bool
+		wantErr        error
+	}{
+		{
+			name:           "self_signed",
+			wantCert:       filepath.Join("/data", CertFile),
+			wantKey:        filepath.Join("/data", KeyFile),
+			wantSelfSigned: true,
+		},
+		{
+			name:     "user_files",
+			cert:     "/etc/core.crt",
+			key:      "/etc/core.key",
+			wantCert: "/etc/core.crt",
+			wantKey:  "/etc/core.key",
+		},
+		{
+			name:    "cert_only",
+			cert:    "/etc/core.crt",
+			wantErr: ErrIncompleteFiles,
+		},
+		{
+			name:    "key_only",
+			key:     "/etc/core.key",
+			wantErr: ErrIncompleteFiles,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := config.BaseDefaults
+			defaults.Service.TlsCert = tt.cert
+			defaults.Service.TlsKey = tt.key
+			cfg, err := config.NewConfig(t.TempDir(), defaults)
+			require.NoError(t, err)
+
+			certPath, keyPath, selfSigned, err := Paths(pl, cfg)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantCert, certPath)
+			assert.Equal(t, tt.wantKey, keyPath)
+			assert.Equal(t, tt.wantSelfSigned, selfSigned)
+
+			if tt.wantErr != nil {
+				_, err := Load(pl, cfg)
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
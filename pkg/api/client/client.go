@@ -1,6 +1,7 @@
 package client
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,6 +24,30 @@ var (
 
 const ApiPath = "/api/v0.1"
 
+// localDialer returns the WebSocket URL and dialer for the local running API
+// service, using TLS if it's enabled. The certificate isn't verified because
+// the connection never leaves the loopback interface, and the service may be
+// using a self-signed certificate.
+func localDialer(cfg *config.Instance) (url.URL, *websocket.Dialer) {
+	u := url.URL{
+		Scheme: "ws",
+		Host:   "localhost:" + strconv.Itoa(cfg.ApiPort()),
+		Path:   ApiPath,
+	}
+
+	if !cfg.ApiTls() {
+		return u, websocket.DefaultDialer
+	}
+
+	u.Scheme = "wss"
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: true,
+	}
+
+	return u, &dialer
+}
+
 // Disable runZapScript and returns a function that enables it back
 func ZapScriptWrapper(cfg *config.Instance) func() {
 	_, err := LocalClient(
@@ -60,11 +85,7 @@ func LocalClient(
 	method string,
 	params string,
 ) (string, error) {
-	localWebsocketUrl := url.URL{
-		Scheme: "ws",
-		Host:   "localhost:" + strconv.Itoa(cfg.ApiPort()),
-		Path:   ApiPath,
-	}
+	localWebsocketUrl, dialer := localDialer(cfg)
 
 	id, err := uuid.NewUUID()
 	if err != nil {
@@ -85,7 +106,7 @@ func LocalClient(
 		return "", ErrInvalidParams
 	}
 
-	c, _, err := websocket.DefaultDialer.Dial(localWebsocketUrl.String(), nil)
+	c, _, err := dialer.Dial(localWebsocketUrl.String(), nil)
 	if err != nil {
 		return "", err
 	}
@@ -162,13 +183,9 @@ func WaitNotification(
 	cfg *config.Instance,
 	id string,
 ) (string, error) {
-	u := url.URL{
-		Scheme: "ws",
-		Host:   "localhost:" + strconv.Itoa(cfg.ApiPort()),
-		Path:   ApiPath,
-	}
+	u, dialer := localDialer(cfg)
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	c, _, err := dialer.Dial(u.String(), nil)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"sort"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// methodSpec describes the params and result of an API method. A nil params
+// schema means the method takes no params.
+type methodSpec struct {
+	params     *schema.Schema
+	result     *schema.Schema
+	deprecated bool
+	replacedBy string
+}
+
+var methodSpecs = map[string]methodSpec{
+	// run
+	models.MethodLaunch: {
+		params:     schema.OneOf(schema.Generate(models.RunParams{}), &schema.Schema{Type: "string"}),
+		result:     schema.OneOf(schema.Generate(models.RunResponse{}), schema.Generate(models.LaunchResultResponse{})),
+		deprecated: true,
+		replacedBy: models.MethodRun,
+	},
+	models.MethodRun: {
+		params: schema.OneOf(schema.Generate(models.RunParams{}), &schema.Schema{Type: "string"}),
+		result: schema.OneOf(schema.Generate(models.RunResponse{}), schema.Generate(models.LaunchResultResponse{})),
+	},
+	models.MethodRunScript: {
+		params: schema.Generate(models.RunScriptParams{}),
+		result: schema.OneOf(schema.Generate(models.RunResponse{}), schema.Generate(models.LaunchResultResponse{})),
+	},
+	models.MethodRunQueue: {
+		result: schema.Generate(models.RunQueueResponse{}),
+	},
+	models.MethodRunResolve: {
+		params: schema.OneOf(schema.Generate(models.RunResolveParams{}), &schema.Schema{Type: "string"}),
+		result: schema.Generate(models.RunResolveResponse{}),
+	},
+	models.MethodStop: {
+		result: schema.Null(),
+	},
+	// tokens
+	models.MethodTokens: {
+		result: schema.Generate(models.TokensResponse{}),
+	},
+	models.MethodHistory: {
+		result: schema.Generate(models.HistoryResponse{}),
+	},
+	// media
+	models.MethodMedia: {
+		result: schema.Generate(models.MediaResponse{}),
+	},
+	models.MethodMediaGenerate: {
+		params: schema.Generate(models.MediaIndexParams{}),
+		result: schema.Null(),
+	},
+	models.MethodMediaIndex: {
+		params:     schema.Generate(models.MediaIndexParams{}),
+		result:     schema.Null(),
+		deprecated: true,
+		replacedBy: models.MethodMediaGenerate,
+	},
+	models.MethodMediaSearch: {
+		params: schema.Generate(models.SearchParams{}),
+		result: schema.Generate(models.SearchResults{}),
+	},
+	models.MethodMediaActive: {
+		result: schema.OneOf(schema.Generate(models.ActiveMedia{}), schema.Null()),
+	},
+	models.MethodMediaActiveUpdate: {
+		params: schema.Generate(models.UpdateActiveMediaParams{}),
+		result: schema.Null(),
+	},
+	// settings
+	models.MethodSettings: {
+		result: schema.Generate(models.SettingsResponse{}),
+	},
+	models.MethodSettingsUpdate: {
+		params: schema.Generate(models.UpdateSettingsParams{}),
+		result: schema.Null(),
+	},
+	models.MethodSettingsReload: {
+		result: schema.Null(),
+	},
+	// systems
+	models.MethodSystems: {
+		result: schema.Generate(models.SystemsResponse{}),
+	},
+	// mappings
+	models.MethodMappings: {
+		result: schema.Generate(models.AllMappingsResponse{}),
+	},
+	models.MethodMappingsNew: {
+		params: schema.Generate(models.AddMappingParams{}),
+		result: schema.Null(),
+	},
+	models.MethodMappingsDelete: {
+		params: schema.Generate(models.DeleteMappingParams{}),
+		result: schema.Null(),
+	},
+	models.MethodMappingsUpdate: {
+		params: schema.Generate(models.UpdateMappingParams{}),
+		result: schema.Null(),
+	},
+	models.MethodMappingsReload: {
+		result: schema.Null(),
+	},
+	// readers
+	models.MethodReadersWrite: {
+		params: schema.Generate(models.ReaderWriteParams{}),
+		result: schema.Null(),
+	},
+	// clients
+	models.MethodClients: {
+		result: schema.Generate([]models.ClientSummaryResponse{}),
+	},
+	models.MethodClientsNew: {
+		params: schema.Generate(models.NewClientParams{}),
+		result: schema.Generate(models.ClientResponse{}),
+	},
+	models.MethodClientsDelete: {
+		params: schema.Generate(models.DeleteClientParams{}),
+		result: schema.Null(),
+	},
+	models.MethodClientsSecret: {
+		params: schema.Generate(models.ClientSecretParams{}),
+		result: schema.Generate(models.ClientResponse{}),
+	},
+	// notifications
+	models.MethodNotificationsSubscribe: {
+		params: schema.Generate(models.NotificationsSubscribeParams{}),
+		result: schema.Generate(models.NotificationsSubscriptionsResponse{}),
+	},
+	models.MethodNotificationsUnsubscribe: {
+		params: schema.Generate(models.NotificationsSubscribeParams{}),
+		result: schema.Generate(models.NotificationsSubscriptionsResponse{}),
+	},
+	// stats
+	models.MethodStatsMedia: {
+		params: schema.Generate(models.StatsParams{}),
+		result: schema.Generate(models.StatsMediaResponse{}),
+	},
+	models.MethodStatsSystems: {
+		params: schema.Generate(models.StatsParams{}),
+		result: schema.Generate(models.StatsSystemsResponse{}),
+	},
+	models.MethodStatsTokens: {
+		params: schema.Generate(models.StatsParams{}),
+		result: schema.Generate(models.StatsTokensResponse{}),
+	},
+	models.MethodStatsDaily: {
+		params: schema.Generate(models.StatsParams{}),
+		result: schema.Generate(models.StatsDailyResponse{}),
+	},
+	// schedule
+	models.MethodSchedule: {
+		result: schema.Generate(models.ScheduleResponse{}),
+	},
+	models.MethodScheduleNew: {
+		params: schema.Generate(models.AddScheduledJobParams{}),
+		result: schema.Generate(models.ScheduledJobResponse{}),
+	},
+	models.MethodScheduleUpdate: {
+		params: schema.Generate(models.UpdateScheduledJobParams{}),
+		result: schema.Null(),
+	},
+	models.MethodScheduleDelete: {
+		params: schema.Generate(models.DeleteScheduledJobParams{}),
+		result: schema.Null(),
+	},
+	// limits
+	models.MethodLimitsOverride: {
+		params: schema.Generate(models.LimitsOverrideParams{}),
+		result: schema.Generate(models.LimitsOverrideResponse{}),
+	},
+	// utils
+	models.MethodAudit: {
+		params: schema.Generate(models.AuditParams{}),
+		result: schema.Generate(models.AuditResponse{}),
+	},
+	models.MethodCertificate: {
+		result: schema.Generate(models.CertificateResponse{}),
+	},
+	models.MethodVersion: {
+		result: schema.Generate(models.VersionResponse{}),
+	},
+	models.MethodMethods: {
+		result: schema.Generate(models.MethodsResponse{}),
+	},
+}
+
+// handleMethods returns a handler listing every method registered in the
+// method map, along with its required scope and params and result schemas
+// if known.
+func handleMethods(methodMap *MethodMap) func(requests.RequestEnv) (any, error) {
+	return func(env requests.RequestEnv) (any, error) {
+		log.Info().Msg("received methods request")
+
+		names := methodMap.ListMethods()
+		sort.Strings(names)
+
+		resp := models.MethodsResponse{
+			Methods: make([]models.MethodResponse, 0, len(names)),
+		}
+
+		for _, name := range names {
+			mr := models.MethodResponse{
+				Name:  name,
+				Scope: methodScopes[name],
+			}
+
+			if spec, ok := methodSpecs[name]; ok {
+				mr.Params = spec.params
+				mr.Result = spec.result
+				mr.Deprecated = spec.deprecated
+				mr.ReplacedBy = spec.replacedBy
+			}
+
+			resp.Methods = append(resp.Methods, mr)
+		}
+
+		return resp, nil
+	}
+}
@@ -0,0 +1,252 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/olahol/melody"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	sessionKeyEncryption = "encryption"
+	sessionSaltLength    = 16
+	// HKDF info strings used to derive a separate key for each direction,
+	// so a message sent by one side can't be reflected back to it.
+	keyInfoClientToServer = "zaparoo api client to server"
+	keyInfoServerToClient = "zaparoo api server to client"
+)
+
+var (
+	ErrInvalidEnvelope  = errors.New("invalid encrypted envelope")
+	ErrReplayedEnvelope = errors.New("replayed or out of order encrypted envelope")
+)
+
+// deriveCipher returns an AES-GCM cipher keyed with HKDF-SHA256 from a
+// client secret, the session salt and the direction of the traffic.
+func deriveCipher(secret string, salt []byte, info string) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	_, err := io.ReadFull(hkdf.New(sha256.New, []byte(secret), salt, []byte(info)), key)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sessionCrypto is the encryption state of a single encrypted WebSocket
+// session. Every session has a random salt chosen by the server, which is
+// sent to the client in plaintext before any encrypted message. Each sealed
+// message carries a sequence number which is authenticated with the
+// payload and must always increase, so captured messages can't be replayed
+// in the same session or any other.
+type sessionCrypto struct {
+	mu      sync.Mutex
+	salt    []byte
+	send    cipher.AEAD
+	recv    cipher.AEAD
+	sendSeq uint64
+	recvSeq uint64
+	started bool
+	authed  bool
+}
+
+func newSessionCrypto(secret string) (*sessionCrypto, error) {
+	salt := make([]byte, sessionSaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, fmt.Errorf("error generating session salt: %w", err)
+	}
+
+	send, err := deriveCipher(secret, salt, keyInfoServerToClient)
+	if err != nil {
+		return nil, err
+	}
+
+	recv, err := deriveCipher(secret, salt, keyInfoClientToServer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessionCrypto{
+		salt: salt,
+		send: send,
+		recv: recv,
+	}, nil
+}
+
+func seqData(seq uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, seq)
+	return b
+}
+
+// sealEnvelope encrypts a payload with the next sequence number of the
+// given cipher and wraps it in an envelope. A new random nonce is prepended
+// to every ciphertext.
+func sealEnvelope(aead cipher.AEAD, seq uint64, payload []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, payload, seqData(seq))
+
+	return json.Marshal(models.EncryptedEnvelope{
+		Seq:  seq,
+		Data: base64.StdEncoding.EncodeToString(sealed),
+	})
+}
+
+// openEnvelope unwraps and decrypts an envelope, returning its sequence
+// number and the original plaintext payload.
+func openEnvelope(aead cipher.AEAD, msg []byte) (uint64, []byte, error) {
+	var env models.EncryptedEnvelope
+	err := json.Unmarshal(msg, &env)
+	if err != nil || env.Data == "" || env.Seq == 0 {
+		return 0, nil, ErrInvalidEnvelope
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(env.Data)
+	if err != nil {
+		return 0, nil, ErrInvalidEnvelope
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return 0, nil, ErrInvalidEnvelope
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	payload, err := aead.Open(nil, nonce, ciphertext, seqData(env.Seq))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return env.Seq, payload, nil
+}
+
+// start writes the plaintext session start message with the session salt,
+// if it hasn't been sent yet. Must be called with the lock held.
+func (sc *sessionCrypto) start(write func([]byte) error) error {
+	if sc.started {
+		return nil
+	}
+
+	data, err := json.Marshal(models.EncryptedSessionStart{
+		Salt: base64.StdEncoding.EncodeToString(sc.salt),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = write(data)
+	if err != nil {
+		return err
+	}
+
+	sc.started = true
+	return nil
+}
+
+// write seals a payload and sends it with the given write function,
+// sending the session start message first if needed.
+func (sc *sessionCrypto) write(write func([]byte) error, payload []byte) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	err := sc.start(write)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := sealEnvelope(sc.send, sc.sendSeq+1, payload)
+	if err != nil {
+		return err
+	}
+	sc.sendSeq++
+
+	return write(sealed)
+}
+
+// read decrypts a message from the client. Messages with a sequence number
+// not greater than the last accepted one are rejected.
+func (sc *sessionCrypto) read(msg []byte) ([]byte, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	seq, payload, err := openEnvelope(sc.recv, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if seq <= sc.recvSeq {
+		return nil, ErrReplayedEnvelope
+	}
+	sc.recvSeq = seq
+
+	return payload, nil
+}
+
+// authenticate returns true the first time it's called after a message
+// from the client was decrypted, which proves the client knows the secret.
+func (sc *sessionCrypto) authenticate() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.authed || sc.recvSeq == 0 {
+		return false
+	}
+	sc.authed = true
+	return true
+}
+
+// sessionEncryption returns the encryption state of an encrypted WebSocket
+// session, or nil if the session is plaintext.
+func sessionEncryption(session *melody.Session) *sessionCrypto {
+	v, ok := session.Get(sessionKeyEncryption)
+	if !ok {
+		return nil
+	}
+	sc, ok := v.(*sessionCrypto)
+	if !ok {
+		return nil
+	}
+	return sc
+}
+
+// startSession sends the session start message to a newly connected
+// encrypted session. Does nothing for plaintext sessions.
+func startSession(session *melody.Session) error {
+	sc := sessionEncryption(session)
+	if sc == nil {
+		return nil
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.start(session.Write)
+}
+
+// writeSession sends a payload to a WebSocket session, encrypting it first
+// if the session was opened in encrypted mode.
+func writeSession(session *melody.Session, data []byte) error {
+	sc := sessionEncryption(session)
+	if sc == nil {
+		return session.Write(data)
+	}
+	return sc.write(session.Write, data)
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClientCiphers derives the keys a client would use for a session
+// from the salt in the session start message.
+func testClientCiphers(t *testing.T, secret string, start []byte) (cipher.AEAD, cipher.AEAD) {
+	var ss models.EncryptedSessionStart
+	require.NoError(t, json.Unmarshal(start, &ss))
+
+	salt, err := base64.StdEncoding.DecodeString(ss.Salt)
+	require.NoError(t, err)
+	require.Len(t, salt, sessionSaltLength)
+
+	send, err := deriveCipher(secret, salt, keyInfoClientToServer)
+	require.NoError(t, err)
+	recv, err := deriveCipher(secret, salt, keyInfoServerToClient)
+	require.NoError(t, err)
+
+	return send, recv
+}
+
+func testSessionCrypto(t *testing.T, secret string) (*sessionCrypto, *[][]byte) {
+	sc, err := newSessionCrypto(secret)
+	require.NoError(t, err)
+
+	var written [][]byte
+	require.NoError(t, sc.write(func(b []byte) error {
+		written = append(written, b)
+		return nil
+	}, []byte(`{"jsonrpc":"2.0","method":"media.started","params":{}}`)))
+
+	return sc, &written
+}
+
+func TestSessionCryptoRoundTrip(t *testing.T) {
+	sc, written := testSessionCrypto(t, "client-secret")
+
+	// session start is sent in plaintext before the first sealed message
+	require.Len(t, *written, 2)
+	send, recv := testClientCiphers(t, "client-secret", (*written)[0])
+
+	assert.NotContains(t, string((*written)[1]), "media.started")
+	seq, payload, err := openEnvelope(recv, (*written)[1])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), seq)
+	assert.Contains(t, string(payload), "media.started")
+
+	for i := uint64(1); i <= 3; i++ {
+		msg, err := sealEnvelope(send, i, []byte("ping"))
+		require.NoError(t, err)
+		opened, err := sc.read(msg)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ping"), opened)
+	}
+}
+
+func TestSessionCryptoRejects(t *testing.T) {
+	sc, written := testSessionCrypto(t, "client-secret")
+	send, _ := testClientCiphers(t, "client-secret", (*written)[0])
+
+	first, err := sealEnvelope(send, 5, []byte("first"))
+	require.NoError(t, err)
+	_, err = sc.read(first)
+	require.NoError(t, err)
+
+	t.Run("replay", func(t *testing.T) {
+		_, err := sc.read(first)
+		assert.ErrorIs(t, err, ErrReplayedEnvelope)
+	})
+
+	t.Run("old_sequence", func(t *testing.T) {
+		msg, err := sealEnvelope(send, 4, []byte("old"))
+		require.NoError(t, err)
+		_, err = sc.read(msg)
+		assert.ErrorIs(t, err, ErrReplayedEnvelope)
+	})
+
+	t.Run("tampered_data", func(t *testing.T) {
+		msg, err := sealEnvelope(send, 6, []byte("tamper"))
+		require.NoError(t, err)
+
+		var env models.EncryptedEnvelope
+		require.NoError(t, json.Unmarshal(msg, &env))
+		sealed, err := base64.StdEncoding.DecodeString(env.Data)
+		require.NoError(t, err)
+		sealed[len(sealed)-1] ^= 0xff
+		env.Data = base64.StdEncoding.EncodeToString(sealed)
+		msg, err = json.Marshal(env)
+		require.NoError(t, err)
+
+		_, err = sc.read(msg)
+		assert.Error(t, err)
+	})
+
+	t.Run("tampered_sequence", func(t *testing.T) {
+		msg, err := sealEnvelope(send, 7, []byte("tamper"))
+		require.NoError(t, err)
+
+		var env models.EncryptedEnvelope
+		require.NoError(t, json.Unmarshal(msg, &env))
+		env.Seq = 100
+		msg, err = json.Marshal(env)
+		require.NoError(t, err)
+
+		_, err = sc.read(msg)
+		assert.Error(t, err)
+	})
+
+	t.Run("reflected", func(t *testing.T) {
+		// messages sent by the server are sealed with the other key
+		_, err := sc.read((*written)[1])
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		other, _ := testClientCiphers(t, "other-secret", (*written)[0])
+		msg, err := sealEnvelope(other, 8, []byte("ping"))
+		require.NoError(t, err)
+		_, err = sc.read(msg)
+		assert.Error(t, err)
+	})
+
+	t.Run("other_session", func(t *testing.T) {
+		// same secret but a different session salt
+		osc, err := newSessionCrypto("client-secret")
+		require.NoError(t, err)
+		msg, err := sealEnvelope(send, 1, []byte("ping"))
+		require.NoError(t, err)
+		_, err = osc.read(msg)
+		assert.Error(t, err)
+	})
+
+	// rejected messages don't advance the sequence
+	next, err := sealEnvelope(send, 6, []byte("next"))
+	require.NoError(t, err)
+	_, err = sc.read(next)
+	assert.NoError(t, err)
+}
+
+func TestSessionCryptoAuthenticate(t *testing.T) {
+	sc, written := testSessionCrypto(t, "client-secret")
+	send, _ := testClientCiphers(t, "client-secret", (*written)[0])
+	assert.False(t, sc.authenticate())
+
+	// a message sealed with the wrong secret doesn't authenticate
+	wrong, _ := testClientCiphers(t, "wrong-secret", (*written)[0])
+	msg, err := sealEnvelope(wrong, 1, []byte("ping"))
+	require.NoError(t, err)
+	_, err = sc.read(msg)
+	require.Error(t, err)
+	assert.False(t, sc.authenticate())
+
+	msg, err = sealEnvelope(send, 1, []byte("ping"))
+	require.NoError(t, err)
+	_, err = sc.read(msg)
+	require.NoError(t, err)
+	assert.True(t, sc.authenticate())
+
+	// only the first decrypted message authenticates the session
+	msg, err = sealEnvelope(send, 2, []byte("ping"))
+	require.NoError(t, err)
+	_, err = sc.read(msg)
+	require.NoError(t, err)
+	assert.False(t, sc.authenticate())
+}
+
+func TestOpenEnvelopeInvalid(t *testing.T) {
+	aead, err := deriveCipher("client-secret", []byte("salt"), keyInfoClientToServer)
+	require.NoError(t, err)
+
+	short, err := json.Marshal(models.EncryptedEnvelope{
+		Seq:  1,
+		Data: base64.StdEncoding.EncodeToString([]byte("abc")),
+	})
+	require.NoError(t, err)
+
+	tests := map[string][]byte{
+		"not_json":      []byte("ping"),
+		"empty_data":    []byte(`{"seq":1,"data":""}`),
+		"no_sequence":   []byte(`{"data":"AAAA"}`),
+		"not_base64":    []byte(`{"seq":1,"data":"!!!"}`),
+		"shorter_nonce": short,
+	}
+
+	for name, msg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := openEnvelope(aead, msg)
+			assert.ErrorIs(t, err, ErrInvalidEnvelope)
+		})
+	}
+}
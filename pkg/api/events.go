@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// eventStreamBuffer is the number of notifications queued for a slow
+	// event stream before new ones are dropped.
+	eventStreamBuffer = 32
+	// eventStreamKeepAlive is how often a comment is sent on an idle event
+	// stream, so proxies and clients don't time out the connection.
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+// event is a marshalled notification ready to be sent to an event stream.
+type event struct {
+	method string
+	data   []byte
+}
+
+type eventStream struct {
+	subs   *notifications.Subscriptions
+	events chan event
+}
+
+// eventStreams tracks all connected Server-Sent Events clients.
+type eventStreams struct {
+	mu      sync.Mutex
+	streams map[*eventStream]struct{}
+}
+
+func newEventStreams() *eventStreams {
+	return &eventStreams{
+		streams: make(map[*eventStream]struct{}),
+	}
+}
+
+func (es *eventStreams) add(subs *notifications.Subscriptions) *eventStream {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	s := &eventStream{
+		subs:   subs,
+		events: make(chan event, eventStreamBuffer),
+	}
+	es.streams[s] = struct{}{}
+
+	return s
+}
+
+func (es *eventStreams) remove(s *eventStream) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	delete(es.streams, s)
+}
+
+// broadcast queues a notification on every stream subscribed to its method.
+// Streams which aren't keeping up have the notification dropped rather than
+// blocking the other clients.
+func (es *eventStreams) broadcast(e event) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	for s := range es.streams {
+		if !s.subs.Matches(e.method) {
+			continue
+		}
+
+		select {
+		case s.events <- e:
+		default:
+			log.Warn().Msgf("event stream full, dropping notification: %s", e.method)
+		}
+	}
+}
+
+// eventSubscriptions creates the subscriptions for an event stream from the
+// methods query parameter, a comma separated list of notification methods or
+// wildcard patterns. Streams with no filter receive all notifications.
+func eventSubscriptions(r *http.Request) (*notifications.Subscriptions, error) {
+	subs := notifications.NewSubscriptions()
+
+	q := r.URL.Query().Get("methods")
+	if q == "" {
+		return subs, nil
+	}
+
+	var patterns []string
+	for _, p := range strings.Split(q, ",") {
+		p = strings.TrimSpace(p)
+		if !notifications.ValidPattern(p) {
+			return nil, fmt.Errorf("invalid method pattern: %s", p)
+		}
+		patterns = append(patterns, p)
+	}
+
+	subs.Unsubscribe(nil)
+	subs.Subscribe(patterns)
+
+	return subs, nil
+}
+
+// handleEventsRequest streams API notifications to the client as
+// Server-Sent Events. Each event is named after the notification method and
+// its data is the same JSON-RPC notification object sent to WebSocket
+// sessions.
+func handleEventsRequest(
+	db *database.Database,
+	st *state.State,
+	streams *eventStreams,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, ok := authenticateRequest(db, r)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		subs, err := eventSubscriptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		s := streams.add(subs)
+		defer streams.remove(s)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		log.Debug().Msgf("event stream opened: %s", r.RemoteAddr)
+
+		ticker := time.NewTicker(eventStreamKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Debug().Msgf("event stream closed: %s", r.RemoteAddr)
+				return
+			case <-st.GetContext().Done():
+				log.Debug().Msgf("closing event stream via context cancellation: %s", r.RemoteAddr)
+				return
+			case <-ticker.C:
+				_, err := fmt.Fprint(w, ": keep-alive\n\n")
+				if err != nil {
+					log.Debug().Err(err).Msg("writing event stream keep-alive")
+					return
+				}
+				flusher.Flush()
+			case e := <-s.events:
+				_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.method, e.data)
+				if err != nil {
+					log.Debug().Err(err).Msg("writing event stream notification")
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
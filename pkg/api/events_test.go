@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStreamsBroadcast(t *testing.T) {
+	streams := newEventStreams()
+
+	r := httptest.NewRequest("GET", "/api/events?methods=readers.*,media.started", nil)
+	subs, err := eventSubscriptions(r)
+	require.NoError(t, err)
+	filtered := streams.add(subs)
+
+	r = httptest.NewRequest("GET", "/api/events", nil)
+	subs, err = eventSubscriptions(r)
+	require.NoError(t, err)
+	all := streams.add(subs)
+
+	streams.broadcast(event{method: "readers.added", data: []byte("{}")})
+	streams.broadcast(event{method: "tokens.added", data: []byte("{}")})
+
+	assert.Len(t, filtered.events, 1)
+	assert.Len(t, all.events, 2)
+
+	streams.remove(all)
+	streams.broadcast(event{method: "media.started", data: []byte("{}")})
+	assert.Len(t, filtered.events, 2)
+	assert.Len(t, all.events, 2)
+}
+
+func TestEventSubscriptionsInvalid(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/events?methods=readers.*.added", nil)
+	_, err := eventSubscriptions(r)
+	assert.Error(t, err)
+}
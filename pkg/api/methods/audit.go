@@ -0,0 +1,82 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func HandleAudit(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received audit request")
+
+	q := database.AuditQuery{
+		Limit: defaultAuditLimit,
+	}
+
+	if len(env.Params) > 0 {
+		var params models.AuditParams
+		err := json.Unmarshal(env.Params, &params)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+
+		if params.Limit != nil {
+			if *params.Limit < 1 || *params.Limit > maxAuditLimit {
+				return nil, ErrInvalidParams
+			}
+			q.Limit = *params.Limit
+		}
+
+		if params.Method != nil {
+			q.Method = *params.Method
+		}
+
+		if params.ClientID != nil {
+			id, err := uuid.Parse(*params.ClientID)
+			if err != nil {
+				return nil, ErrInvalidParams
+			}
+			q.ClientID = &id
+		}
+
+		if params.Since != nil {
+			q.Since = *params.Since
+		}
+	}
+
+	entries, err := env.Database.GetAudit(q)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting audit log")
+		return nil, errors.New("error getting audit log")
+	}
+
+	resp := models.AuditResponse{
+		Entries: make([]models.AuditResponseEntry, len(entries)),
+	}
+
+	for i, e := range entries {
+		resp.Entries[i] = models.AuditResponseEntry{
+			Time:         e.Time,
+			Method:       e.Method,
+			ClientID:     e.ClientID,
+			ClientName:   e.ClientName,
+			Address:      e.Address,
+			Local:        e.Local,
+			ParamsDigest: e.ParamsDigest,
+			Ok:           e.Ok,
+			ErrorCode:    e.ErrorCode,
+		}
+	}
+
+	return resp, nil
+}
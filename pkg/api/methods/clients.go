@@ -0,0 +1,171 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ClientScopes returns the permission scopes of a registered client.
+// Clients registered before scopes were added get the legacy scopes.
+func ClientScopes(c database.Client) []string {
+	if c.Scopes == nil {
+		return models.LegacyScopes
+	}
+	return c.Scopes
+}
+
+func clientResponse(c database.Client) models.ClientResponse {
+	return models.ClientResponse{
+		ID:      c.ID,
+		Name:    c.Name,
+		Address: c.Address,
+		Secret:  c.Secret,
+		Scopes:  ClientScopes(c),
+	}
+}
+
+func HandleClients(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received clients request")
+
+	if !env.HasScope(models.ScopeAdmin) {
+		return nil, ErrNotAllowed
+	}
+
+	clients, err := env.Database.GetAllClients()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting clients")
+		return nil, errors.New("error getting clients")
+	}
+
+	resp := make([]models.ClientSummaryResponse, 0)
+	for _, c := range clients {
+		resp = append(resp, models.ClientSummaryResponse{
+			ID:      c.ID,
+			Name:    c.Name,
+			Address: c.Address,
+			Scopes:  ClientScopes(c),
+		})
+	}
+
+	return resp, nil
+}
+
+func HandleNewClient(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received new client request")
+
+	if !env.HasScope(models.ScopeAdmin) {
+		return nil, ErrNotAllowed
+	}
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.NewClientParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	scopes := params.Scopes
+	if len(scopes) == 0 {
+		scopes = models.DefaultScopes
+	}
+
+	for _, s := range scopes {
+		if !slices.Contains(models.AllScopes, s) {
+			return nil, WrapError(ErrInvalidParams, nil, map[string]string{"scope": s})
+		}
+		// clients can't register other clients with more access than
+		// they have themselves
+		if !env.HasScope(s) {
+			return nil, WrapError(ErrNotAllowed, nil, map[string]string{"scope": s})
+		}
+	}
+
+	c, err := env.Database.AddClient(params.Name, scopes)
+	if err != nil {
+		log.Error().Err(err).Msg("error adding client")
+		return nil, errors.New("error adding client")
+	}
+
+	log.Info().Msgf("registered new client: %s", c.ID)
+
+	return clientResponse(c), nil
+}
+
+// HandleClientSecret returns a registered client including its secret, so
+// a pairing QR code can be shown again. Only allowed for local requests
+// made without a client, such as from the CLI.
+func HandleClientSecret(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received client secret request")
+
+	if !env.IsLocal || env.Client != nil {
+		return nil, ErrNotAllowed
+	}
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.ClientSecretParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	id, err := uuid.Parse(params.Id)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	c, err := env.Database.GetClient(id)
+	if errors.Is(err, database.ErrClientNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return clientResponse(c), nil
+}
+
+func HandleDeleteClient(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received delete client request")
+
+	if !env.HasScope(models.ScopeAdmin) {
+		return nil, ErrNotAllowed
+	}
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.DeleteClientParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	id, err := uuid.Parse(params.Id)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	err = env.Database.DeleteClient(id)
+	if errors.Is(err, database.ErrClientNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("revoked client: %s", id)
+
+	return nil, nil
+}
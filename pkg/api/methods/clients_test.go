@@ -0,0 +1,210 @@
+package methods
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlatform struct {
+	platforms.Platform
+	dataDir string
+}
+
+func (p testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func testDatabase(t *testing.T) *database.Database {
+	db, err := database.Open(testPlatform{dataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestClientScopes(t *testing.T) {
+	// clients registered before scopes keep their access, but don't get
+	// unsafe access
+	assert.Equal(t, models.LegacyScopes, ClientScopes(database.Client{}))
+	assert.NotContains(t, ClientScopes(database.Client{}), models.ScopeUnsafe)
+
+	scopes := []string{models.ScopeRead}
+	assert.Equal(t, scopes, ClientScopes(database.Client{Scopes: scopes}))
+}
+
+func TestClientsAdminScope(t *testing.T) {
+	db := testDatabase(t)
+	_, err := db.AddClient("phone", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		env     requests.RequestEnv
+		allowed bool
+	}{
+		{
+			name: "remote_admin",
+			env: requests.RequestEnv{
+				Scopes: []string{models.ScopeAdmin},
+			},
+			allowed: true,
+		},
+		{
+			name: "local_no_admin",
+			env: requests.RequestEnv{
+				IsLocal: true,
+				Scopes:  []string{models.ScopeRead, models.ScopeLaunch},
+			},
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			env.Database = db
+
+			resp, err := HandleClients(env)
+			if !tt.allowed {
+				assert.ErrorIs(t, err, ErrNotAllowed)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, resp, 1)
+
+			env.Params = json.RawMessage(`{"name":"tablet","scopes":["admin"]}`)
+			_, err = HandleNewClient(env)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNewClientScopeEscalation(t *testing.T) {
+	env := requests.RequestEnv{
+		Database: testDatabase(t),
+		Scopes:   []string{models.ScopeRead, models.ScopeAdmin},
+		Params:   json.RawMessage(`{"name":"tablet","scopes":["read","unsafe"]}`),
+	}
+
+	_, err := HandleNewClient(env)
+	assert.ErrorIs(t, err, ErrNotAllowed)
+
+	clients, err := env.Database.GetAllClients()
+	require.NoError(t, err)
+	assert.Empty(t, clients)
+}
+
+func TestNewClientDefaultScopes(t *testing.T) {
+	env := requests.RequestEnv{
+		Database: testDatabase(t),
+		Scopes:   models.AllScopes,
+		Params:   json.RawMessage(`{"name":"tablet"}`),
+	}
+
+	_, err := HandleNewClient(env)
+	require.NoError(t, err)
+
+	clients, err := env.Database.GetAllClients()
+	require.NoError(t, err)
+	require.Len(t, clients, 1)
+	assert.Equal(t, []string{models.ScopeRead, models.ScopeLaunch}, clients[0].Scopes)
+}
+
+func TestHandleClientsNoSecrets(t *testing.T) {
+	db := testDatabase(t)
+	c, err := db.AddClient("phone", []string{models.ScopeRead})
+	require.NoError(t, err)
+	require.NoError(t, db.UpdateClientAddress(c.ID, "192.168.1.20"))
+
+	resp, err := HandleClients(requests.RequestEnv{
+		Database: db,
+		Scopes:   []string{models.ScopeAdmin},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []models.ClientSummaryResponse{
+		{
+			ID:      c.ID,
+			Name:    "phone",
+			Address: "192.168.1.20",
+			Scopes:  []string{models.ScopeRead},
+		},
+	}, resp)
+
+	data, err := json.Marshal(resp)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), c.Secret)
+}
+
+func TestHandleClientSecret(t *testing.T) {
+	db := testDatabase(t)
+	c, err := db.AddClient("phone", nil)
+	require.NoError(t, err)
+
+	params := json.RawMessage(`{"id":"` + c.ID.String() + `"}`)
+
+	tests := []struct {
+		name    string
+		env     requests.RequestEnv
+		allowed bool
+	}{
+		{
+			name: "local",
+			env: requests.RequestEnv{
+				IsLocal: true,
+				Scopes:  models.AllScopes,
+			},
+			allowed: true,
+		},
+		{
+			name: "local_client",
+			env: requests.RequestEnv{
+				IsLocal: true,
+				Client:  &c,
+				Scopes:  models.AllScopes,
+			},
+		},
+		{
+			name: "remote_admin",
+			env: requests.RequestEnv{
+				Client: &c,
+				Scopes: models.AllScopes,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			env.Database = db
+			env.Params = params
+
+			resp, err := HandleClientSecret(env)
+			if !tt.allowed {
+				assert.ErrorIs(t, err, ErrNotAllowed)
+				return
+			}
+			require.NoError(t, err)
+
+			cr, ok := resp.(models.ClientResponse)
+			require.True(t, ok)
+			assert.Equal(t, c.Secret, cr.Secret)
+		})
+	}
+
+	_, err = HandleClientSecret(requests.RequestEnv{
+		Database: db,
+		IsLocal:  true,
+		Params:   json.RawMessage(`{"id":"` + uuid.NewString() + `"}`),
+	})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,66 @@
+package methods
+
+import (
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+)
+
+// Error is a typed error returned by a method handler. Its code is sent to
+// the client as the JSON-RPC error code, along with the optional data.
+type Error struct {
+	Code    int
+	Message string
+	Data    any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches any other method error with the same code and message, so
+// wrapped copies of the sentinel errors below can be checked with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return e.Code == t.Code && e.Message == t.Message
+}
+
+// NewError returns a new method error with the given code.
+func NewError(code int, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapError returns a copy of a method error with an underlying cause and
+// optional data attached.
+func WrapError(e *Error, err error, data any) *Error {
+	return &Error{
+		Code:    e.Code,
+		Message: e.Message,
+		Data:    data,
+		Err:     err,
+	}
+}
+
+var (
+	ErrMissingParams    = NewError(models.ErrorCodeInvalidParams, "missing params")
+	ErrInvalidParams    = NewError(models.ErrorCodeInvalidParams, "invalid params")
+	ErrNotAllowed       = NewError(models.ErrorCodeNotAllowed, "not allowed")
+	ErrNotFound         = NewError(models.ErrorCodeNotFound, "not found")
+	ErrReaderBusy       = NewError(models.ErrorCodeReaderBusy, "reader busy")
+	ErrIndexing         = NewError(models.ErrorCodeIndexing, "indexing in progress")
+	ErrInvalidZapScript = NewError(models.ErrorCodeInvalidZapScript, "invalid ZapScript")
+	ErrLaunchFailed     = NewError(models.ErrorCodeLaunchFailed, "launch failed")
+	ErrLimitReached     = NewError(models.ErrorCodeLimitReached, "playtime limit reached")
+)
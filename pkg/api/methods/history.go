@@ -2,6 +2,8 @@ package methods
 
 import (
 	"errors"
+	"sort"
+
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
 	"github.com/rs/zerolog/log"
@@ -14,8 +16,23 @@ func HandleTokens(env requests.RequestEnv) (any, error) {
 		Active: make([]models.TokenResponse, 0),
 	}
 
+	for device, t := range env.State.GetReaderTokens() {
+		resp.Active = append(resp.Active, models.TokenResponse{
+			Type:     t.Type,
+			UID:      t.UID,
+			Text:     t.Text,
+			Data:     t.Data,
+			ScanTime: t.ScanTime,
+			Reader:   device,
+		})
+	}
+	sort.Slice(resp.Active, func(i, j int) bool {
+		return resp.Active[i].ScanTime.Before(resp.Active[j].ScanTime)
+	})
+
+	// tokens set through the API aren't attached to a reader
 	active := env.State.GetActiveCard()
-	if !active.ScanTime.IsZero() {
+	if len(resp.Active) == 0 && !active.ScanTime.IsZero() {
 		resp.Active = append(resp.Active, models.TokenResponse{
 			Type:     active.Type,
 			UID:      active.UID,
@@ -59,6 +76,7 @@ func HandleHistory(env requests.RequestEnv) (any, error) {
 			UID:     e.UID,
 			Text:    e.Text,
 			Data:    e.Data,
+			Source:  e.Source,
 			Success: e.Success,
 		}
 	}
@@ -0,0 +1,48 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/limits"
+	"github.com/rs/zerolog/log"
+)
+
+// HandleLimitsOverride turns off playtime limits for the configured
+// override time if the PIN matches, and cancels any pending stop of the
+// running media. Repeated wrong PINs lock out further attempts for a while.
+func HandleLimitsOverride(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received limits override request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.LimitsOverrideParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	if !env.Config.Limits().Enabled {
+		return nil, errors.New("playtime limits are not enabled")
+	}
+
+	if env.LimitsOverride == nil {
+		return nil, errors.New("playtime limits are not running")
+	}
+
+	until, err := env.LimitsOverride(params.Pin)
+	if errors.Is(err, limits.ErrInvalidPin) || errors.Is(err, limits.ErrPinLocked) {
+		log.Warn().Err(err).Msg("limits override refused")
+		return nil, WrapError(ErrNotAllowed, err, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return models.LimitsOverrideResponse{
+		Until: until,
+	}, nil
+}
@@ -66,7 +66,12 @@ func validateAddMappingParams(amr *models.AddMappingParams) error {
 		return errors.New("missing pattern")
 	}
 
-	if amr.Match == database.MatchTypeRegex {
+	if amr.Type == database.MappingTypeCombo {
+		_, err := database.ParseComboPattern(amr.Pattern)
+		if err != nil {
+			return err
+		}
+	} else if amr.Match == database.MatchTypeRegex {
 		_, err := regexp.Compile(amr.Pattern)
 		if err != nil {
 			return err
@@ -126,7 +131,9 @@ func HandleDeleteMapping(env requests.RequestEnv) (any, error) {
 	}
 
 	err = env.Database.DeleteMapping(strconv.Itoa(params.Id))
-	if err != nil {
+	if errors.Is(err, database.ErrMappingNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -150,7 +157,9 @@ func validateUpdateMappingParams(umr *models.UpdateMappingParams) error {
 		return errors.New("missing pattern")
 	}
 
-	if umr.Match != nil && *umr.Match == database.MatchTypeRegex {
+	// combo patterns are validated against the full mapping when it's saved
+	isCombo := umr.Type != nil && *umr.Type == database.MappingTypeCombo
+	if !isCombo && umr.Match != nil && *umr.Match == database.MatchTypeRegex {
 		_, err := regexp.Compile(*umr.Pattern)
 		if err != nil {
 			return err
@@ -180,7 +189,9 @@ func HandleUpdateMapping(env requests.RequestEnv) (any, error) {
 	}
 
 	oldMapping, err := env.Database.GetMapping(strconv.Itoa(params.Id))
-	if err != nil {
+	if errors.Is(err, database.ErrMappingNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,43 @@
+package methods
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleDeleteMapping(t *testing.T) {
+	db := testDatabase(t)
+	require.NoError(t, db.AddMapping(database.Mapping{
+		Enabled:  true,
+		Type:     database.MappingTypeUID,
+		Match:    database.MatchTypeExact,
+		Pattern:  "04aabbcc",
+		Override: "**launch.random:snes",
+	}))
+
+	env := requests.RequestEnv{
+		Database: db,
+		Params:   json.RawMessage(`{"id":1}`),
+	}
+
+	_, err := HandleDeleteMapping(env)
+	require.NoError(t, err)
+
+	ms, err := db.GetAllMappings()
+	require.NoError(t, err)
+	assert.Empty(t, ms)
+
+	// deleting a mapping which doesn't exist is the same error as updating
+	// one
+	_, err = HandleDeleteMapping(env)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	env.Params = json.RawMessage(`{"id":1,"enabled":false}`)
+	_, err = HandleUpdateMapping(env)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -38,13 +38,12 @@ func (s *IndexingStatus) GenerateMediaDB(
 	cfg *config.Instance,
 	ns chan<- models.Notification,
 	systems []systemdefs.System,
-) {
+) error {
 	// TODO: this function should block until index is complete
 	// confirm that concurrent requests is working
 
 	if s.Indexing {
-		// TODO: return an error to client
-		return
+		return ErrIndexing
 	}
 
 	s.mu.Lock()
@@ -108,6 +107,8 @@ func (s *IndexingStatus) GenerateMediaDB(
 			TotalFiles: &total,
 		})
 	}()
+
+	return nil
 }
 
 func NewIndexingStatus() *IndexingStatus {
@@ -143,12 +144,16 @@ func HandleGenerateMedia(env requests.RequestEnv) (any, error) {
 		systems = systemdefs.AllSystems()
 	}
 
-	IndexingStatusInstance.GenerateMediaDB(
+	err := IndexingStatusInstance.GenerateMediaDB(
 		env.Platform,
 		env.Config,
 		env.State.Notifications,
 		systems,
 	)
+	if err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
@@ -0,0 +1,82 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrNoSession = errors.New("subscriptions require a websocket session")
+
+func parseSubscribeParams(env requests.RequestEnv, required bool) ([]string, error) {
+	if len(env.Params) == 0 {
+		if required {
+			return nil, ErrMissingParams
+		}
+		return nil, nil
+	}
+
+	var params models.NotificationsSubscribeParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	if required && len(params.Methods) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	for _, m := range params.Methods {
+		if !notifications.ValidPattern(m) {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	return params.Methods, nil
+}
+
+func subscriptionsResponse(subs *notifications.Subscriptions) models.NotificationsSubscriptionsResponse {
+	ms, es := subs.Patterns()
+	return models.NotificationsSubscriptionsResponse{
+		Methods:  ms,
+		Excluded: es,
+	}
+}
+
+func HandleNotificationsSubscribe(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received notifications subscribe request")
+
+	if env.Subscriptions == nil {
+		return nil, ErrNoSession
+	}
+
+	ms, err := parseSubscribeParams(env, true)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Subscriptions.Subscribe(ms)
+
+	return subscriptionsResponse(env.Subscriptions), nil
+}
+
+func HandleNotificationsUnsubscribe(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received notifications unsubscribe request")
+
+	if env.Subscriptions == nil {
+		return nil, ErrNoSession
+	}
+
+	ms, err := parseSubscribeParams(env, false)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Subscriptions.Unsubscribe(ms)
+
+	return subscriptionsResponse(env.Subscriptions), nil
+}
@@ -3,12 +3,17 @@ package methods
 import (
 	"encoding/json"
 	"errors"
+	"sync"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
 	"github.com/rs/zerolog/log"
 )
 
+// writeMu is held while a reader write is in progress, so concurrent write
+// requests fail fast instead of racing for the same tag.
+var writeMu sync.Mutex
+
 func HandleReaderWrite(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received reader write request")
 
@@ -39,6 +44,11 @@ func HandleReaderWrite(env requests.RequestEnv) (any, error) {
 		return nil, errors.New("reader not connected: " + rs[0])
 	}
 
+	if !writeMu.TryLock() {
+		return nil, ErrReaderBusy
+	}
+	defer writeMu.Unlock()
+
 	t, err := reader.Write(params.Text)
 	if err != nil {
 		log.Error().Err(err).Msg("error writing to reader")
@@ -5,36 +5,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
-	widgetModels "github.com/ZaparooProject/zaparoo-core/pkg/configui/widgets/models"
-	"github.com/ZaparooProject/zaparoo-core/pkg/database/systemdefs"
-	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
 	zapScriptModels "github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/limits"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/mappings"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"golang.org/x/text/unicode/norm"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	ErrMissingParams = errors.New("missing params")
-	ErrInvalidParams = errors.New("invalid params")
-	ErrNotAllowed    = errors.New("not allowed")
-)
-
 func HandleRun(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received run request")
 
@@ -80,9 +72,7 @@ func HandleRun(env requests.RequestEnv) (any, error) {
 			return nil, ErrInvalidParams
 		}
 
-		if params.Unsafe {
-			t.Unsafe = true
-		}
+		t.Unsafe = params.Unsafe
 	} else {
 		log.Debug().Msgf("could not unmarshal run params, trying string: %s", env.Params)
 
@@ -101,12 +91,70 @@ func HandleRun(env requests.RequestEnv) (any, error) {
 
 	t.ScanTime = time.Now()
 	t.FromAPI = true
+	// clients without the unsafe scope are always treated as untrusted,
+	// and any client can ask to be
+	t.Unsafe = t.Unsafe || !env.HasScope(models.ScopeUnsafe)
+
+	return runToken(env, t, params.Wait)
+}
+
+// runToken adds a token from the API to the launch queue. By default it
+// returns the run ID straight away and the outcome is sent later as a
+// launch.result notification. In wait mode, it blocks until the launch has
+// finished and returns the outcome directly.
+func runToken(env requests.RequestEnv, t tokens.Token, wait bool) (any, error) {
+	t.RunID = uuid.New()
+
+	var resCh chan tokens.LaunchResult
+	if wait {
+		resCh = make(chan tokens.LaunchResult, 1)
+		t.Result = resCh
+	}
 
-	// TODO: how do we report back errors? put channel in queue
 	env.State.SetActiveCard(t)
-	env.TokenQueue <- t
+	select {
+	case env.TokenQueue <- t:
+	case <-env.State.GetContext().Done():
+		return nil, errors.New("service stopped before launch queued")
+	}
+
+	if !wait {
+		return models.RunResponse{
+			RunID: t.RunID,
+		}, nil
+	}
+
+	timer := time.NewTimer(config.LaunchWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case res := <-resCh:
+		if errors.Is(res.Err, limits.ErrLimitReached) {
+			return nil, WrapError(
+				ErrLimitReached,
+				res.Err,
+				models.RunResponse{RunID: res.RunID},
+			)
+		} else if res.Err != nil {
+			return nil, WrapError(
+				ErrLaunchFailed,
+				res.Err,
+				models.RunResponse{RunID: res.RunID},
+			)
+		}
 
-	return nil, nil
+		return models.LaunchResultResponse{
+			RunID:        res.RunID,
+			Success:      true,
+			Path:         res.Path,
+			Launcher:     res.Launcher,
+			MediaChanged: res.MediaChanged,
+		}, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("timed out waiting for launch: %s", t.RunID)
+	case <-env.State.GetContext().Done():
+		return nil, errors.New("service stopped before launch finished")
+	}
 }
 
 func HandleRunScript(env requests.RequestEnv) (any, error) {
@@ -125,9 +173,7 @@ func HandleRunScript(env requests.RequestEnv) (any, error) {
 		return nil, ErrInvalidParams
 	}
 
-	if zsrp.Unsafe {
-		t.Unsafe = true
-	}
+	t.Unsafe = zsrp.Unsafe
 
 	zs := zapScriptModels.ZapScript{
 		ZapScript: zsrp.ZapScript,
@@ -137,12 +183,16 @@ func HandleRunScript(env requests.RequestEnv) (any, error) {
 
 	if zs.ZapScript != 1 {
 		log.Error().Msgf("invalid zapscript version: %d", zs.ZapScript)
-		return nil, ErrInvalidParams
+		return nil, WrapError(
+			ErrInvalidZapScript,
+			fmt.Errorf("unsupported version: %d", zs.ZapScript),
+			nil,
+		)
 	}
 
 	if len(zs.Cmds) == 0 {
 		log.Error().Msg("no commands in zapscript")
-		return nil, ErrInvalidParams
+		return nil, WrapError(ErrInvalidZapScript, errors.New("no commands"), nil)
 	} else if len(zs.Cmds) > 1 {
 		log.Warn().Msg("too many commands in zapscript, using first")
 	}
@@ -164,22 +214,24 @@ func HandleRunScript(env requests.RequestEnv) (any, error) {
 		if err != nil {
 			return nil, fmt.Errorf("error unmarshalling evaluate params: %w", err)
 		}
-		// TODO: this will timeout on large downloads
-		t.Text, err = InstallRunMedia(env.Config, env.Platform, args)
-		if err != nil {
-			return nil, fmt.Errorf("error installing and running media: %w", err)
-		}
+		// media is downloaded by the launch worker, so it can be cancelled
+		// with the launch and doesn't hold up the request
+		t.Install = &args
 	default:
-		return "", fmt.Errorf("unsupported cmd: %s", cmdName)
+		return nil, WrapError(
+			ErrInvalidZapScript,
+			fmt.Errorf("unsupported cmd: %s", cmdName),
+			map[string]string{"cmd": cmdName},
+		)
 	}
 
 	t.ScanTime = time.Now()
 	t.FromAPI = true
+	// clients without the unsafe scope are always treated as untrusted,
+	// and any client can ask to be
+	t.Unsafe = t.Unsafe || !env.HasScope(models.ScopeUnsafe)
 
-	env.State.SetActiveCard(t)
-	env.TokenQueue <- t
-
-	return nil, nil
+	return runToken(env, t, zsrp.Wait)
 }
 
 func HandleRunRest(
@@ -213,166 +265,130 @@ func HandleRunRest(
 		}
 
 		st.SetActiveCard(t)
-		itq <- t
+		select {
+		case itq <- t:
+		case <-r.Context().Done():
+		case <-st.GetContext().Done():
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
 	}
 }
 
 func HandleStop(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received stop request")
+	env.State.CancelLaunch()
 	return nil, env.Platform.KillLauncher()
 }
 
-func InstallRunMedia(
-	cfg *config.Instance,
-	pl platforms.Platform,
-	launchArgs zapScriptModels.CmdLaunchArgs,
-) (string, error) {
-	if pl.Id() != platforms.PlatformIDMister {
-		return "", errors.New("media install only supported for mister")
-	}
-
-	if launchArgs.URL == nil {
-		return "", errors.New("media download url is empty")
-	} else if launchArgs.System == nil {
-		return "", errors.New("media system is empty")
-	}
-
-	system, err := systemdefs.LookupSystem(*launchArgs.System)
-	if err != nil {
-		return "", fmt.Errorf("error getting system: %w", err)
-	}
-
-	var launchers []platforms.Launcher
-	for _, l := range pl.Launchers() {
-		if l.SystemID == system.ID {
-			launchers = append(launchers, l)
-		}
-	}
-
-	if len(launchers) == 0 {
-		return "", fmt.Errorf("no launchers for system: %s", system.ID)
-	}
+func HandleRunQueue(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received run queue request")
+	return env.State.LaunchQueue(), nil
+}
 
-	// just use the first launcher for now
-	launcher := launchers[0]
+// HandleRunResolve reports what running a token would do, without
+// launching anything: the mapping it matched, and the command, args and
+// media each of its ZapScript commands resolved to.
+func HandleRunResolve(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received run resolve request")
 
-	if launcher.Folders == nil {
-		return "", errors.New("no folders for launcher")
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
 	}
 
-	// just use the first folder for now
-	folder := launcher.Folders[0]
-
-	name := filepath.Base(*launchArgs.URL)
-
-	// roots := pl.RootDirs(cfg)
-
-	// if len(roots) == 0 {
-	// 	return "", errors.New("no root dirs")
-	// }
-
-	// root := roots[0]
-
-	root := "/media/fat/games" // TODO: this is hardcoded for now
+	var t tokens.Token
 
-	path := filepath.Join(root, folder, name)
+	var params models.RunResolveParams
+	err := json.Unmarshal(env.Params, &params)
+	if err == nil {
+		if params.Type != nil {
+			t.Type = *params.Type
+		}
 
-	log.Debug().Msgf("media path: %s", path)
+		if params.UID != nil {
+			t.UID = *params.UID
+		}
 
-	// check if the file already exists
-	if _, err := os.Stat(path); err == nil {
-		if launchArgs.PreNotice != nil && *launchArgs.PreNotice != "" {
-			hide, delay, err := pl.ShowNotice(cfg, widgetModels.NoticeArgs{
-				Text: *launchArgs.PreNotice,
-			})
-			if err != nil {
-				return "", fmt.Errorf("error showing pre-notice: %w", err)
-			}
+		if params.Text != nil {
+			t.Text = norm.NFC.String(*params.Text)
+		}
 
-			if delay > 0 {
-				log.Debug().Msgf("delaying pre-notice: %d", delay)
-				time.Sleep(delay)
-			}
+		if params.Data != nil {
+			t.Data = strings.ToLower(*params.Data)
+			t.Data = strings.ReplaceAll(t.Data, " ", "")
 
-			err = hide()
-			if err != nil {
-				return "", fmt.Errorf("error hiding pre-notice: %w", err)
+			if _, err := hex.DecodeString(t.Data); err != nil {
+				return nil, ErrInvalidParams
 			}
 		}
-		return path, nil
-	} else if !os.IsNotExist(err) {
-		return "", fmt.Errorf("error checking file: %w", err)
-	}
-
-	// download the file
-	log.Info().Msgf("downloading media: %s", *launchArgs.URL)
-
-	itemDisplay := *launchArgs.URL
-	if launchArgs.Name != nil && *launchArgs.Name != "" {
-		itemDisplay = *launchArgs.Name
-	}
-	loadingText := fmt.Sprintf("Downloading %s...", itemDisplay)
-
-	hideLoader, err := pl.ShowLoader(cfg, widgetModels.NoticeArgs{
-		Text: loadingText,
-	})
-	if err != nil {
-		return "", fmt.Errorf("error showing loading dialog: %w", err)
-	}
 
-	resp, err := http.Get(*launchArgs.URL)
-	if err != nil {
-		return "", fmt.Errorf("error getting url: %w", err)
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Error().Err(err).Msgf("closing body")
+		if params.UID == nil && params.Text == nil && params.Data == nil {
+			return nil, ErrInvalidParams
 		}
-	}(resp.Body)
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("invalid status code: %d", resp.StatusCode)
-	}
-
-	file, err := os.Create(path)
-	if err != nil {
-		return "", fmt.Errorf("error creating file: %w", err)
-	}
-	defer func(File *os.File) {
-		err := File.Close()
+	} else {
+		var text string
+		err := json.Unmarshal(env.Params, &text)
 		if err != nil {
-			log.Error().Err(err).Msgf("closing file")
+			return nil, ErrInvalidParams
+		} else if text == "" {
+			return nil, ErrMissingParams
 		}
-	}(file)
 
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("error copying file: %w", err)
+		t.Text = norm.NFC.String(text)
 	}
 
-	err = hideLoader()
-	if err != nil {
-		return "", fmt.Errorf("error hiding loading dialog: %w", err)
+	resp := models.RunResolveResponse{
+		ZapScript: t.Text,
+		Commands:  make([]models.ResolveCommandResponse, 0),
 	}
 
-	if launchArgs.PreNotice != nil && *launchArgs.PreNotice != "" {
-		hide, delay, err := pl.ShowNotice(cfg, widgetModels.NoticeArgs{
-			Text: *launchArgs.PreNotice,
-		})
-		if err != nil {
-			return "", fmt.Errorf("error showing pre-notice: %w", err)
+	match, mapped := mappings.Get(
+		env.Config,
+		env.Database,
+		env.Platform,
+		t,
+		env.State.GetActiveTokens(),
+	)
+	if mapped {
+		resp.ZapScript = match.Mapping.Override
+		resp.Mapping = &models.ResolveMappingResponse{
+			Source:    match.Source,
+			MappingID: match.Mapping.Id,
+			Label:     match.Mapping.Label,
+			Type:      match.Mapping.Type,
+			Match:     match.Mapping.Match,
+			Pattern:   match.Mapping.Pattern,
+			ZapScript: match.Mapping.Override,
 		}
+	}
 
-		if delay > 0 {
-			log.Debug().Msgf("delaying pre-notice: %d", delay)
-			time.Sleep(delay)
-		}
+	if resp.ZapScript == "" {
+		return resp, nil
+	}
 
-		err = hide()
+	for _, cmd := range strings.Split(resp.ZapScript, "||") {
+		res, err := zapscript.ResolveToken(
+			env.State.GetContext(),
+			env.Platform,
+			env.Config,
+			cmd,
+		)
+
+		rc := models.ResolveCommandResponse{
+			Text:      res.Text,
+			ZapLink:   res.ZapLink,
+			Download:  res.Download,
+			Cmd:       res.Cmd,
+			Args:      res.Args,
+			NamedArgs: res.NamedArgs,
+			Path:      res.Path,
+			Launcher:  res.Launcher,
+		}
 		if err != nil {
-			return "", fmt.Errorf("error hiding pre-notice: %w", err)
+			rc.Error = err.Error()
 		}
+
+		resp.Commands = append(resp.Commands, rc)
 	}
 
-	return path, nil
+	return resp, nil
 }
@@ -0,0 +1,225 @@
+package methods
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRunEnv returns a request env with a token queue, and a function to
+// receive the next token added to it.
+func testRunEnv(t *testing.T, params string) (requests.RequestEnv, <-chan tokens.Token) {
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+
+	tq := make(chan tokens.Token, 1)
+	return requests.RequestEnv{
+		State:      st,
+		TokenQueue: tq,
+		Params:     json.RawMessage(params),
+		Scopes:     models.AllScopes,
+	}, tq
+}
+
+func TestHandleRunAsync(t *testing.T) {
+	env, tq := testRunEnv(t, `{"text":"**launch.system:snes"}`)
+
+	resp, err := HandleRun(env)
+	require.NoError(t, err)
+
+	run, ok := resp.(models.RunResponse)
+	require.True(t, ok)
+	assert.NotZero(t, run.RunID)
+
+	token := <-tq
+	assert.Equal(t, run.RunID, token.RunID)
+	assert.Equal(t, "**launch.system:snes", token.Text)
+	assert.Nil(t, token.Result)
+}
+
+func TestHandleRunWait(t *testing.T) {
+	env, tq := testRunEnv(t, `{"text":"snes/game.sfc","wait":true}`)
+
+	go func() {
+		token := <-tq
+		token.Result <- tokens.LaunchResult{
+			RunID:        token.RunID,
+			Path:         "/media/fat/games/SNES/game.sfc",
+			Launcher:     "SNES",
+			MediaChanged: true,
+		}
+	}()
+
+	resp, err := HandleRun(env)
+	require.NoError(t, err)
+
+	res, ok := resp.(models.LaunchResultResponse)
+	require.True(t, ok)
+	assert.NotZero(t, res.RunID)
+	assert.True(t, res.Success)
+	assert.Equal(t, "/media/fat/games/SNES/game.sfc", res.Path)
+	assert.Equal(t, "SNES", res.Launcher)
+	assert.True(t, res.MediaChanged)
+}
+
+func TestHandleRunWaitFailed(t *testing.T) {
+	env, tq := testRunEnv(t, `{"text":"snes/missing.sfc","wait":true}`)
+
+	go func() {
+		token := <-tq
+		token.Result <- tokens.LaunchResult{
+			RunID: token.RunID,
+			Err:   errors.New("file not found"),
+		}
+	}()
+
+	_, err := HandleRun(env)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, models.ErrorCodeLaunchFailed, apiErr.Code)
+	assert.ErrorContains(t, err, "file not found")
+
+	run, ok := apiErr.Data.(models.RunResponse)
+	require.True(t, ok)
+	assert.NotZero(t, run.RunID)
+}
+
+func TestHandleRunWaitStopped(t *testing.T) {
+	env, tq := testRunEnv(t, `{"text":"snes/game.sfc","wait":true}`)
+
+	go func() {
+		<-tq
+		env.State.StopService()
+	}()
+
+	_, err := HandleRun(env)
+	assert.Error(t, err)
+}
+
+func TestHandleRunScriptInstall(t *testing.T) {
+	var requested atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested.Store(true)
+	}))
+	defer srv.Close()
+
+	env, tq := testRunEnv(t, `{"zapscript":1,"cmds":[{"cmd":"launch","args":`+
+		`{"system":"SNES","url":"`+srv.URL+`/game.sfc"}}]}`)
+
+	_, err := HandleRunScript(env)
+	require.NoError(t, err)
+
+	// the download is left to the launch worker
+	token := <-tq
+	require.NotNil(t, token.Install)
+	require.NotNil(t, token.Install.URL)
+	assert.Equal(t, srv.URL+"/game.sfc", *token.Install.URL)
+	assert.Empty(t, token.Text)
+	assert.False(t, requested.Load())
+}
+
+func TestHandleRunUnsafe(t *testing.T) {
+	launchOnly := []string{models.ScopeRead, models.ScopeLaunch}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		params string
+		unsafe bool
+	}{
+		{
+			name:   "launch_only",
+			scopes: launchOnly,
+			params: `{"text":"**execute:touch /tmp/zaparoo"}`,
+			unsafe: true,
+		},
+		{
+			name:   "launch_only_string",
+			scopes: launchOnly,
+			params: `"**execute:touch /tmp/zaparoo"`,
+			unsafe: true,
+		},
+		{
+			name:   "unsafe_scope",
+			scopes: models.AllScopes,
+			params: `{"text":"**execute:touch /tmp/zaparoo"}`,
+		},
+		{
+			name:   "unsafe_scope_restricted",
+			scopes: models.AllScopes,
+			params: `{"text":"**execute:touch /tmp/zaparoo","unsafe":true}`,
+			unsafe: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, tq := testRunEnv(t, tt.params)
+			env.Scopes = tt.scopes
+
+			_, err := HandleRun(env)
+			require.NoError(t, err)
+
+			token := <-tq
+			assert.Equal(t, tt.unsafe, token.Unsafe)
+		})
+	}
+}
+
+func TestHandleRunUnsafeExecute(t *testing.T) {
+	env, tq := testRunEnv(t, `{"text":"**execute:touch /tmp/zaparoo"}`)
+	env.Scopes = []string{models.ScopeRead, models.ScopeLaunch}
+
+	_, err := HandleRun(env)
+	require.NoError(t, err)
+	token := <-tq
+
+	defaults := config.BaseDefaults
+	defaults.ZapScript.AllowExecute = []string{".*"}
+	cfg, err := config.NewConfig(t.TempDir(), defaults)
+	require.NoError(t, err)
+
+	_, err = zapscript.LaunchToken(
+		context.Background(),
+		testPlatform{},
+		cfg,
+		playlists.PlaylistController{},
+		token,
+		token.Text,
+		1,
+		0,
+		nil,
+		nil,
+	)
+	assert.ErrorContains(t, err, "cannot be run from a remote source")
+}
+
+func TestHandleRunScriptUnsafe(t *testing.T) {
+	env, tq := testRunEnv(t, `{"zapscript":1,"cmds":[{"cmd":"evaluate","args":`+
+		`{"zapscript":"**execute:touch /tmp/zaparoo"}}]}`)
+	env.Scopes = []string{models.ScopeRead, models.ScopeLaunch}
+
+	_, err := HandleRunScript(env)
+	require.NoError(t, err)
+
+	token := <-tq
+	assert.True(t, token.Unsafe)
+}
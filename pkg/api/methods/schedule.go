@@ -0,0 +1,216 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/schedule"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+func scheduledJobResponse(env requests.RequestEnv, j schedule.Job) models.ScheduledJobResponse {
+	resp := models.ScheduledJobResponse{
+		Id:        j.ID,
+		Label:     j.Label,
+		Source:    j.Source,
+		Enabled:   j.Enabled,
+		Schedule:  j.Schedule,
+		ZapScript: j.ZapScript,
+		CatchUp:   j.CatchUp,
+	}
+
+	if j.Enabled && j.Spec != nil {
+		next := j.Spec.Next(time.Now())
+		if !next.IsZero() {
+			resp.NextRun = &next
+		}
+	}
+
+	status, err := env.Database.GetJobStatus(j.ID)
+	if err != nil {
+		log.Error().Err(err).Msgf("error getting job status: %s", j.ID)
+	} else if !status.LastRun.IsZero() {
+		resp.LastRun = &status.LastRun
+		resp.LastSuccess = status.Success
+		resp.LastError = status.Error
+	}
+
+	return resp
+}
+
+func validateScheduledJob(j database.ScheduledJob) error {
+	if j.ZapScript == "" {
+		return errors.New("missing zapscript")
+	}
+
+	_, err := schedule.Parse(j.Schedule)
+	if err != nil {
+		return err
+	}
+
+	if !utils.Contains(database.AllowedCatchUps, j.CatchUp) {
+		return errors.New("invalid catch up")
+	}
+
+	return nil
+}
+
+func HandleSchedule(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received schedule request")
+
+	jobs, err := schedule.Jobs(env.Config, env.Database)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting scheduled jobs")
+		return nil, errors.New("error getting scheduled jobs")
+	}
+
+	resp := models.ScheduleResponse{
+		Jobs: make([]models.ScheduledJobResponse, 0, len(jobs)),
+	}
+
+	for _, j := range jobs {
+		resp.Jobs = append(resp.Jobs, scheduledJobResponse(env, j))
+	}
+
+	return resp, nil
+}
+
+func HandleAddScheduledJob(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received add scheduled job request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.AddScheduledJobParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	j := database.ScheduledJob{
+		Label:     params.Label,
+		Enabled:   params.Enabled == nil || *params.Enabled,
+		Schedule:  params.Schedule,
+		ZapScript: params.ZapScript,
+		CatchUp:   strings.ToLower(params.CatchUp),
+	}
+	if j.CatchUp == "" {
+		j.CatchUp = database.CatchUpSkip
+	}
+
+	err = validateScheduledJob(j)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid params")
+		return nil, WrapError(ErrInvalidParams, err, nil)
+	}
+
+	id, err := env.Database.AddScheduledJob(j)
+	if err != nil {
+		return nil, err
+	}
+	j.Id = id
+
+	spec, _ := schedule.Parse(j.Schedule)
+	return scheduledJobResponse(env, schedule.Job{
+		ID:        j.Id,
+		Label:     j.Label,
+		Source:    schedule.JobSourceDatabase,
+		Enabled:   j.Enabled,
+		Schedule:  j.Schedule,
+		ZapScript: j.ZapScript,
+		CatchUp:   j.CatchUp,
+		Spec:      spec,
+	}), nil
+}
+
+func HandleUpdateScheduledJob(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received update scheduled job request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.UpdateScheduledJobParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	if schedule.IsConfigJob(params.Id) {
+		return nil, WrapError(ErrNotAllowed, errors.New("job is set in config file"), nil)
+	}
+
+	j, err := env.Database.GetScheduledJob(params.Id)
+	if errors.Is(err, database.ErrJobNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if params.Label != nil {
+		j.Label = *params.Label
+	}
+
+	if params.Enabled != nil {
+		j.Enabled = *params.Enabled
+	}
+
+	if params.Schedule != nil {
+		j.Schedule = *params.Schedule
+	}
+
+	if params.ZapScript != nil {
+		j.ZapScript = *params.ZapScript
+	}
+
+	if params.CatchUp != nil {
+		j.CatchUp = strings.ToLower(*params.CatchUp)
+	}
+
+	err = validateScheduledJob(j)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid params")
+		return nil, WrapError(ErrInvalidParams, err, nil)
+	}
+
+	err = env.Database.UpdateScheduledJob(params.Id, j)
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func HandleDeleteScheduledJob(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received delete scheduled job request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.DeleteScheduledJobParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	if schedule.IsConfigJob(params.Id) {
+		return nil, WrapError(ErrNotAllowed, errors.New("job is set in config file"), nil)
+	}
+
+	err = env.Database.DeleteScheduledJob(params.Id)
+	if errors.Is(err, database.ErrJobNotFound) {
+		return nil, WrapError(ErrNotFound, err, nil)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,317 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultStatsLimit = 10
+	maxStatsLimit     = 1000
+	statsDateFormat   = "2006-01-02"
+)
+
+type statsQuery struct {
+	since time.Time
+	until time.Time
+	limit int
+}
+
+func parseStatsParams(env requests.RequestEnv) (statsQuery, error) {
+	q := statsQuery{
+		limit: defaultStatsLimit,
+	}
+
+	if len(env.Params) == 0 {
+		return q, nil
+	}
+
+	var params models.StatsParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return q, ErrInvalidParams
+	}
+
+	if params.Since != nil {
+		q.since = *params.Since
+	}
+
+	if params.Until != nil {
+		q.until = *params.Until
+	}
+
+	if !q.since.IsZero() && !q.until.IsZero() && !q.until.After(q.since) {
+		return q, ErrInvalidParams
+	}
+
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxStatsLimit {
+			return q, ErrInvalidParams
+		}
+		q.limit = *params.Limit
+	}
+
+	return q, nil
+}
+
+func (q statsQuery) sessions(db *database.Database) ([]database.Session, error) {
+	sessions, err := db.GetSessions(q.since, q.until)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting play sessions")
+		return nil, errors.New("error getting play sessions")
+	}
+	return sessions, nil
+}
+
+func (q statsQuery) history(db *database.Database) ([]database.HistoryEntry, error) {
+	entries, err := db.GetAllHistory()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting history")
+		return nil, errors.New("error getting history")
+	}
+
+	filtered := make([]database.HistoryEntry, 0, len(entries))
+	for _, e := range entries {
+		if !q.since.IsZero() && e.Time.Before(q.since) {
+			continue
+		}
+		if !q.until.IsZero() && !e.Time.Before(q.until) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+
+	return filtered, nil
+}
+
+func playtime(s database.Session) int64 {
+	return int64(s.Duration().Seconds())
+}
+
+// statsMedia returns the most played media by total playtime.
+func statsMedia(sessions []database.Session, limit int) []models.StatsMedia {
+	index := make(map[string]int)
+	media := make([]models.StatsMedia, 0)
+
+	for _, s := range sessions {
+		key := s.SystemID + "/" + s.MediaPath
+		i, ok := index[key]
+		if !ok {
+			i = len(media)
+			index[key] = i
+			media = append(media, models.StatsMedia{
+				SystemID:   s.SystemID,
+				SystemName: s.SystemName,
+				MediaPath:  s.MediaPath,
+			})
+		}
+
+		media[i].MediaName = s.MediaName
+		media[i].Sessions++
+		media[i].Playtime += playtime(s)
+		if s.Start.After(media[i].LastPlayed) {
+			media[i].LastPlayed = s.Start
+		}
+	}
+
+	sort.SliceStable(media, func(i, j int) bool {
+		return media[i].Playtime > media[j].Playtime
+	})
+
+	if len(media) > limit {
+		media = media[:limit]
+	}
+
+	return media
+}
+
+// statsSystems returns the total playtime of each system, most played
+// first.
+func statsSystems(sessions []database.Session) []models.StatsSystem {
+	index := make(map[string]int)
+	systems := make([]models.StatsSystem, 0)
+
+	for _, s := range sessions {
+		i, ok := index[s.SystemID]
+		if !ok {
+			i = len(systems)
+			index[s.SystemID] = i
+			systems = append(systems, models.StatsSystem{
+				SystemID:   s.SystemID,
+				SystemName: s.SystemName,
+			})
+		}
+
+		systems[i].Sessions++
+		systems[i].Playtime += playtime(s)
+	}
+
+	sort.SliceStable(systems, func(i, j int) bool {
+		return systems[i].Playtime > systems[j].Playtime
+	})
+
+	return systems
+}
+
+// statsTokens returns the most scanned tokens. Tokens are matched by UID
+// if they have one, otherwise by their text.
+func statsTokens(entries []database.HistoryEntry, limit int) []models.StatsToken {
+	index := make(map[string]int)
+	ts := make([]models.StatsToken, 0)
+
+	for _, e := range entries {
+		key := "text:" + e.Text
+		if e.UID != "" {
+			key = "uid:" + database.NormalizeID(e.UID)
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(ts)
+			index[key] = i
+			ts = append(ts, models.StatsToken{
+				Type: e.Type,
+				UID:  e.UID,
+			})
+		}
+
+		ts[i].Text = e.Text
+		ts[i].Scans++
+		if e.Time.After(ts[i].LastScanned) {
+			ts[i].LastScanned = e.Time
+		}
+	}
+
+	sort.SliceStable(ts, func(i, j int) bool {
+		return ts[i].Scans > ts[j].Scans
+	})
+
+	if len(ts) > limit {
+		ts = ts[:limit]
+	}
+
+	return ts
+}
+
+// statsDaily returns the total playtime and scans of each day with any
+// activity, in the given time zone, oldest first. Sessions count towards
+// the day they started on.
+func statsDaily(
+	sessions []database.Session,
+	entries []database.HistoryEntry,
+	loc *time.Location,
+) []models.StatsDay {
+	index := make(map[string]int)
+	days := make([]models.StatsDay, 0)
+
+	day := func(t time.Time) *models.StatsDay {
+		date := t.In(loc).Format(statsDateFormat)
+		i, ok := index[date]
+		if !ok {
+			i = len(days)
+			index[date] = i
+			days = append(days, models.StatsDay{Date: date})
+		}
+		return &days[i]
+	}
+
+	for _, s := range sessions {
+		d := day(s.Start)
+		d.Sessions++
+		d.Playtime += playtime(s)
+	}
+
+	for _, e := range entries {
+		day(e.Time).Scans++
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Date < days[j].Date
+	})
+
+	return days
+}
+
+func HandleStatsMedia(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received stats media request")
+
+	q, err := parseStatsParams(env)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := q.sessions(env.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.StatsMediaResponse{
+		Media: statsMedia(sessions, q.limit),
+	}, nil
+}
+
+func HandleStatsSystems(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received stats systems request")
+
+	q, err := parseStatsParams(env)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := q.sessions(env.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.StatsSystemsResponse{
+		Systems: statsSystems(sessions),
+	}, nil
+}
+
+func HandleStatsTokens(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received stats tokens request")
+
+	q, err := parseStatsParams(env)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := q.history(env.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.StatsTokensResponse{
+		Tokens: statsTokens(entries, q.limit),
+	}, nil
+}
+
+func HandleStatsDaily(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received stats daily request")
+
+	q, err := parseStatsParams(env)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := q.sessions(env.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := q.history(env.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.StatsDailyResponse{
+		Days: statsDaily(sessions, entries, time.Local),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package methods
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+var statsStart = time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+
+func testSession(system string, path string, start time.Duration, length time.Duration) database.Session {
+	return database.Session{
+		Start:      statsStart.Add(start),
+		End:        statsStart.Add(start + length),
+		SystemID:   system,
+		SystemName: system + " name",
+		MediaPath:  path,
+		MediaName:  path + " name",
+	}
+}
+
+func TestStatsMedia(t *testing.T) {
+	sessions := []database.Session{
+		testSession("SNES", "mario.sfc", 0, 10*time.Minute),
+		testSession("SNES", "zelda.sfc", time.Hour, 30*time.Minute),
+		testSession("SNES", "mario.sfc", 2*time.Hour, 5*time.Minute),
+		// same path on another system is different media
+		testSession("NES", "mario.sfc", 3*time.Hour, 20*time.Minute),
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []models.StatsMedia
+	}{
+		{
+			name:  "all",
+			limit: 10,
+			want: []models.StatsMedia{
+				{
+					SystemID: "SNES", SystemName: "SNES name",
+					MediaPath: "zelda.sfc", MediaName: "zelda.sfc name",
+					Sessions: 1, Playtime: 1800, LastPlayed: statsStart.Add(time.Hour),
+				},
+				{
+					SystemID: "NES", SystemName: "NES name",
+					MediaPath: "mario.sfc", MediaName: "mario.sfc name",
+					Sessions: 1, Playtime: 1200, LastPlayed: statsStart.Add(3 * time.Hour),
+				},
+				{
+					SystemID: "SNES", SystemName: "SNES name",
+					MediaPath: "mario.sfc", MediaName: "mario.sfc name",
+					Sessions: 2, Playtime: 900, LastPlayed: statsStart.Add(2 * time.Hour),
+				},
+			},
+		},
+		{
+			name:  "limit",
+			limit: 1,
+			want: []models.StatsMedia{
+				{
+					SystemID: "SNES", SystemName: "SNES name",
+					MediaPath: "zelda.sfc", MediaName: "zelda.sfc name",
+					Sessions: 1, Playtime: 1800, LastPlayed: statsStart.Add(time.Hour),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statsMedia(sessions, tt.limit))
+		})
+	}
+
+	assert.Empty(t, statsMedia(nil, 10))
+}
+
+func TestStatsSystems(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []database.Session
+		want     []models.StatsSystem
+	}{
+		{
+			name: "empty",
+			want: []models.StatsSystem{},
+		},
+		{
+			name: "most_played_first",
+			sessions: []database.Session{
+				testSession("NES", "mario.nes", 0, 10*time.Minute),
+				testSession("SNES", "mario.sfc", time.Hour, 10*time.Minute),
+				testSession("SNES", "zelda.sfc", 2*time.Hour, 10*time.Minute),
+			},
+			want: []models.StatsSystem{
+				{SystemID: "SNES", SystemName: "SNES name", Sessions: 2, Playtime: 1200},
+				{SystemID: "NES", SystemName: "NES name", Sessions: 1, Playtime: 600},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statsSystems(tt.sessions))
+		})
+	}
+}
+
+func TestStatsTokens(t *testing.T) {
+	entries := []database.HistoryEntry{
+		{Time: statsStart, Type: "NTAG", UID: "04:AA:BB", Text: "old text"},
+		{Time: statsStart.Add(time.Hour), Type: "NTAG", UID: "04aabb", Text: "new text"},
+		{Time: statsStart.Add(2 * time.Hour), Text: "**launch.random:snes"},
+		{Time: statsStart.Add(3 * time.Hour), UID: "04ccdd", Text: "**launch.random:snes"},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []models.StatsToken
+	}{
+		{
+			name:  "matched_by_uid_then_text",
+			limit: 10,
+			want: []models.StatsToken{
+				{Type: "NTAG", UID: "04:AA:BB", Text: "new text", Scans: 2, LastScanned: statsStart.Add(time.Hour)},
+				{Text: "**launch.random:snes", Scans: 1, LastScanned: statsStart.Add(2 * time.Hour)},
+				{UID: "04ccdd", Text: "**launch.random:snes", Scans: 1, LastScanned: statsStart.Add(3 * time.Hour)},
+			},
+		},
+		{
+			name:  "limit",
+			limit: 1,
+			want: []models.StatsToken{
+				{Type: "NTAG", UID: "04:AA:BB", Text: "new text", Scans: 2, LastScanned: statsStart.Add(time.Hour)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statsTokens(entries, tt.limit))
+		})
+	}
+}
+
+func TestStatsDaily(t *testing.T) {
+	// statsStart is 20:00 UTC, which is already the next day at UTC+10
+	sessions := []database.Session{
+		testSession("SNES", "mario.sfc", 0, 10*time.Minute),
+		testSession("SNES", "mario.sfc", -12*time.Hour, 5*time.Minute),
+	}
+	entries := []database.HistoryEntry{
+		{Time: statsStart},
+		{Time: statsStart.Add(-12 * time.Hour)},
+		{Time: statsStart.Add(-24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want []models.StatsDay
+	}{
+		{
+			name: "utc",
+			loc:  time.UTC,
+			want: []models.StatsDay{
+				{Date: "2025-02-28", Scans: 1},
+				{Date: "2025-03-01", Sessions: 2, Playtime: 900, Scans: 2},
+			},
+		},
+		{
+			name: "utc_plus_10",
+			loc:  time.FixedZone("UTC+10", 10*60*60),
+			want: []models.StatsDay{
+				{Date: "2025-03-01", Sessions: 1, Playtime: 300, Scans: 2},
+				{Date: "2025-03-02", Sessions: 1, Playtime: 600, Scans: 1},
+			},
+		},
+		{
+			name: "utc_minus_10",
+			loc:  time.FixedZone("UTC-10", -10*60*60),
+			want: []models.StatsDay{
+				{Date: "2025-02-28", Sessions: 1, Playtime: 300, Scans: 2},
+				{Date: "2025-03-01", Sessions: 1, Playtime: 600, Scans: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, statsDaily(sessions, entries, tt.loc))
+		})
+	}
+
+	assert.Empty(t, statsDaily(nil, nil, time.UTC))
+}
@@ -1,6 +1,9 @@
 package methods
 
 import (
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/certs"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
@@ -9,8 +12,39 @@ import (
 
 func HandleVersion(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received version request")
+
+	apiVersion := env.ApiVersion
+	if apiVersion == "" {
+		apiVersion = models.ApiVersionLatest
+	}
+
 	return models.VersionResponse{
-		Version:  config.AppVersion,
-		Platform: env.Platform.Id(),
+		Version:     config.AppVersion,
+		Platform:    env.Platform.Id(),
+		ApiVersion:  apiVersion,
+		ApiVersions: models.ApiVersions,
+	}, nil
+}
+
+func HandleCertificate(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received certificate request")
+
+	if !env.Config.ApiTls() {
+		return models.CertificateResponse{
+			Tls: false,
+		}, nil
+	}
+
+	cert, err := certs.Load(env.Platform, env.Config)
+	if err != nil {
+		log.Error().Err(err).Msg("error loading certificate")
+		return nil, errors.New("error loading certificate")
+	}
+
+	return models.CertificateResponse{
+		Tls:         true,
+		SelfSigned:  cert.SelfSigned,
+		Fingerprint: cert.Fingerprint(),
+		NotAfter:    &cert.Leaf.NotAfter,
 	}, nil
 }
@@ -14,12 +14,20 @@ const (
 	NotificationStopped             = "media.stopped"
 	NotificationStarted             = "media.started"
 	NotificationMediaIndexing       = "media.indexing"
+	NotificationLaunchResult        = "launch.result"
+	NotificationLaunchMapping       = "launch.mapping"
+	NotificationLaunchCmdStarted    = "launch.command.started"
+	NotificationLaunchCmdFinished   = "launch.command.finished"
+	NotificationLaunchFailed        = "launch.failed"
+	NotificationPlaylistChanged     = "playlist.changed"
 )
 
 const (
 	MethodLaunch            = "launch" // DEPRECATED
 	MethodRun               = "run"
 	MethodRunScript         = "run.script"
+	MethodRunQueue          = "run.queue"
+	MethodRunResolve        = "run.resolve"
 	MethodStop              = "stop"
 	MethodTokens            = "tokens"
 	MethodMedia             = "media"
@@ -34,6 +42,7 @@ const (
 	MethodClients           = "clients"
 	MethodClientsNew        = "clients.new"
 	MethodClientsDelete     = "clients.delete"
+	MethodClientsSecret     = "clients.secret"
 	MethodSystems           = "systems"
 	MethodHistory           = "tokens.history"
 	MethodMappings          = "mappings"
@@ -43,8 +52,81 @@ const (
 	MethodMappingsReload    = "mappings.reload"
 	MethodReadersWrite      = "readers.write"
 	MethodVersion           = "version"
+	MethodCertificate       = "certificate"
+	MethodMethods           = "methods"
+	MethodAudit             = "audit"
+	MethodLimitsOverride    = "limits.override"
+	MethodSchedule          = "schedule"
+	MethodScheduleNew       = "schedule.new"
+	MethodScheduleUpdate    = "schedule.update"
+	MethodScheduleDelete    = "schedule.delete"
+	MethodStatsMedia        = "stats.media"
+	MethodStatsSystems      = "stats.systems"
+	MethodStatsTokens       = "stats.tokens"
+	MethodStatsDaily        = "stats.daily"
+
+	MethodNotificationsSubscribe   = "notifications.subscribe"
+	MethodNotificationsUnsubscribe = "notifications.unsubscribe"
+)
+
+// Methods sent by Core to connected clients, which clients may implement.
+const (
+	// ClientMethodConfirm asks a client to confirm running a ZapScript
+	// command which is blocked for remote sources.
+	ClientMethodConfirm = "confirm"
 )
 
+// Client permission scopes. Each API method requires at most one scope,
+// methods with no scope can be used by every client.
+const (
+	// ScopeRead allows reading tokens, media, history and settings.
+	ScopeRead = "read"
+	// ScopeLaunch allows running tokens and ZapScript, and stopping media.
+	ScopeLaunch = "launch"
+	// ScopeWrite allows writing to readers and updating the active media.
+	ScopeWrite = "write"
+	// ScopeAdmin allows changing settings, mappings and the media database.
+	ScopeAdmin = "admin"
+	// ScopeUnsafe allows running ZapScript commands which are blocked for
+	// remote sources.
+	ScopeUnsafe = "unsafe"
+)
+
+var AllScopes = []string{
+	ScopeRead,
+	ScopeLaunch,
+	ScopeWrite,
+	ScopeAdmin,
+	ScopeUnsafe,
+}
+
+// DefaultScopes are given to new clients registered with no scopes. They
+// can launch but not change anything, other scopes must be asked for when
+// the client is registered.
+var DefaultScopes = []string{
+	ScopeRead,
+	ScopeLaunch,
+}
+
+// LegacyScopes are given to clients registered before scopes were added,
+// which have none stored. Those clients could already use every method
+// except unsafe commands, so they keep that access rather than having apps
+// which were paired before an upgrade stop working. Delete and register
+// the client again to restrict it.
+var LegacyScopes = []string{
+	ScopeRead,
+	ScopeLaunch,
+	ScopeWrite,
+	ScopeAdmin,
+}
+
+// MqttCommand is a message sent to the MQTT bridge's command topic. Request
+// is a JSON-RPC request or batch, the same as sent to the API.
+type MqttCommand struct {
+	Secret  string          `json:"secret"`
+	Request json.RawMessage `json:"request"`
+}
+
 type Notification struct {
 	Method string
 	Params json.RawMessage
@@ -57,9 +139,45 @@ type RequestObject struct {
 	Params  json.RawMessage `json:"params,omitempty"`
 }
 
+// Supported API versions, each with its own method table. Clients connect
+// to a version through its URL path, /api always uses the latest.
+const (
+	ApiVersion0      = "v0"
+	ApiVersion0_1    = "v0.1"
+	ApiVersionLatest = ApiVersion0_1
+)
+
+var ApiVersions = []string{
+	ApiVersion0,
+	ApiVersion0_1,
+}
+
+// Standard JSON-RPC error codes.
+const (
+	ErrorCodeParseError     = -32700
+	ErrorCodeInvalidRequest = -32600
+	ErrorCodeMethodNotFound = -32601
+	ErrorCodeInvalidParams  = -32602
+	ErrorCodeInternalError  = -32603
+)
+
+// Application error codes returned by API methods. These are stable and
+// may be used by clients to branch on the type of error.
+const (
+	ErrorCodeGeneric          = 1
+	ErrorCodeNotFound         = 2
+	ErrorCodeNotAllowed       = 3
+	ErrorCodeReaderBusy       = 4
+	ErrorCodeIndexing         = 5
+	ErrorCodeInvalidZapScript = 6
+	ErrorCodeLaunchFailed     = 7
+	ErrorCodeLimitReached     = 8
+)
+
 type ErrorObject struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
 }
 
 type ResponseObject struct {
@@ -77,3 +195,19 @@ type ResponseErrorObject struct {
 	ID      uuid.UUID    `json:"id"`
 	Error   *ErrorObject `json:"error"`
 }
+
+// EncryptedEnvelope wraps a JSON-RPC payload sent over an encrypted
+// WebSocket session. Data is the base64 encoded AES-GCM nonce followed by
+// the sealed payload. Seq starts at 1 for each direction of a session, must
+// increase with every message and is authenticated as additional data.
+type EncryptedEnvelope struct {
+	Seq  uint64 `json:"seq"`
+	Data string `json:"data"`
+}
+
+// EncryptedSessionStart is the first message sent by the server on an
+// encrypted WebSocket session, in plaintext. Salt is the base64 encoded
+// HKDF salt used with the client secret to derive the session keys.
+type EncryptedSessionStart struct {
+	Salt string `json:"salt"`
+}
@@ -1,6 +1,11 @@
 package models
 
-import "github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+import (
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+	"github.com/google/uuid"
+)
 
 type SearchParams struct {
 	Query      string    `json:"query"`
@@ -18,6 +23,14 @@ type RunParams struct {
 	Text   *string `json:"text"`
 	Data   *string `json:"data"`
 	Unsafe bool    `json:"unsafe"`
+	Wait   bool    `json:"wait"`
+}
+
+type RunResolveParams struct {
+	Type *string `json:"type"`
+	UID  *string `json:"uid"`
+	Text *string `json:"text"`
+	Data *string `json:"data"`
 }
 
 type RunScriptParams struct {
@@ -25,6 +38,7 @@ type RunScriptParams struct {
 	Name      *string               `json:"name"`
 	Cmds      []models.ZapScriptCmd `json:"cmds"`
 	Unsafe    bool                  `json:"unsafe"`
+	Wait      bool                  `json:"wait"`
 }
 
 type AddMappingParams struct {
@@ -65,13 +79,67 @@ type UpdateSettingsParams struct {
 }
 
 type NewClientParams struct {
-	Name string `json:"name"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
 }
 
 type DeleteClientParams struct {
 	Id string `json:"id"`
 }
 
+type ClientSecretParams struct {
+	Id string `json:"id"`
+}
+
+type AuditParams struct {
+	Limit    *int       `json:"limit"`
+	Method   *string    `json:"method"`
+	ClientID *string    `json:"clientId"`
+	Since    *time.Time `json:"since"`
+}
+
+// StatsParams limits stats to a period of time. Limit is not used by the
+// daily stats.
+type StatsParams struct {
+	Since *time.Time `json:"since"`
+	Until *time.Time `json:"until"`
+	Limit *int       `json:"limit"`
+}
+
+type AddScheduledJobParams struct {
+	Label     string `json:"label"`
+	Enabled   *bool  `json:"enabled"`
+	Schedule  string `json:"schedule"`
+	ZapScript string `json:"zapscript"`
+	CatchUp   string `json:"catchUp"`
+}
+
+type UpdateScheduledJobParams struct {
+	Id        string  `json:"id"`
+	Label     *string `json:"label"`
+	Enabled   *bool   `json:"enabled"`
+	Schedule  *string `json:"schedule"`
+	ZapScript *string `json:"zapscript"`
+	CatchUp   *string `json:"catchUp"`
+}
+
+type DeleteScheduledJobParams struct {
+	Id string `json:"id"`
+}
+
+type LimitsOverrideParams struct {
+	Pin string `json:"pin"`
+}
+
+type NotificationsSubscribeParams struct {
+	Methods []string `json:"methods"`
+}
+
+type ConfirmParams struct {
+	Cmd  string `json:"cmd"`
+	Text string `json:"text"`
+}
+
 type MediaStartedParams struct {
 	SystemID   string `json:"systemId"`
 	SystemName string `json:"systemName"`
@@ -79,6 +147,69 @@ type MediaStartedParams struct {
 	MediaName  string `json:"mediaName"`
 }
 
+// LaunchToken identifies the token a launch notification is about. RunID is
+// only set for tokens run from the API.
+type LaunchToken struct {
+	RunID  *uuid.UUID `json:"runId,omitempty"`
+	Type   string     `json:"type,omitempty"`
+	UID    string     `json:"uid,omitempty"`
+	Text   string     `json:"text,omitempty"`
+	Data   string     `json:"data,omitempty"`
+	Source string     `json:"source,omitempty"`
+}
+
+type LaunchMappingParams struct {
+	Token LaunchToken `json:"token"`
+	// Source is where the matching mapping came from: database, config or
+	// platform.
+	Source    string `json:"source"`
+	MappingID string `json:"mappingId,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Match     string `json:"match,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	ZapScript string `json:"zapscript"`
+}
+
+type LaunchCmdStartedParams struct {
+	Token   LaunchToken `json:"token"`
+	Index   int         `json:"index"`
+	Total   int         `json:"total"`
+	Command string      `json:"command"`
+}
+
+type LaunchCmdFinishedParams struct {
+	Token           LaunchToken `json:"token"`
+	Index           int         `json:"index"`
+	Total           int         `json:"total"`
+	Command         string      `json:"command"`
+	Success         bool        `json:"success"`
+	Error           string      `json:"error,omitempty"`
+	MediaChanged    bool        `json:"mediaChanged"`
+	PlaylistChanged bool        `json:"playlistChanged"`
+	Path            string      `json:"path,omitempty"`
+}
+
+type LaunchFailedParams struct {
+	Token LaunchToken `json:"token"`
+	Error string      `json:"error"`
+}
+
+type PlaylistMediaParams struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// PlaylistChangedParams is the new state of the active playlist. All fields
+// are empty if the playlist was cleared.
+type PlaylistChangedParams struct {
+	ID      string               `json:"id,omitempty"`
+	Index   int                  `json:"index"`
+	Playing bool                 `json:"playing"`
+	Current *PlaylistMediaParams `json:"current,omitempty"`
+	Media   int                  `json:"media"`
+}
+
 type UpdateActiveMediaParams struct {
 	SystemID  string `json:"systemId"`
 	MediaPath string `json:"mediaPath"`
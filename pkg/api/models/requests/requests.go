@@ -2,6 +2,11 @@ package requests
 
 import (
 	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
@@ -10,13 +15,31 @@ import (
 	"github.com/google/uuid"
 )
 
+// LimitsOverrideFunc turns off playtime limits if the PIN is correct and
+// returns the time the override ends.
+type LimitsOverrideFunc func(pin string) (time.Time, error)
+
 type RequestEnv struct {
-	Platform   platforms.Platform
-	Config     *config.Instance
-	State      *state.State
-	Database   *database.Database
-	TokenQueue chan<- tokens.Token
-	IsLocal    bool
-	ID         uuid.UUID
-	Params     json.RawMessage
+	Platform       platforms.Platform
+	Config         *config.Instance
+	State          *state.State
+	Database       *database.Database
+	TokenQueue     chan<- tokens.Token
+	IsLocal        bool
+	Address        string
+	Client         *database.Client
+	Scopes         []string
+	Subscriptions  *notifications.Subscriptions
+	Sessions       *sessions.Manager
+	LimitsOverride LimitsOverrideFunc
+	SessionID      uuid.UUID
+	ApiVersion     string
+	ID             uuid.UUID
+	Params         json.RawMessage
+}
+
+// HasScope returns true if the request was made with the given permission
+// scope.
+func (env RequestEnv) HasScope(scope string) bool {
+	return slices.Contains(env.Scopes, scope)
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/schema"
 	"github.com/google/uuid"
 	"time"
 )
@@ -42,6 +43,7 @@ type HistoryReponseEntry struct {
 	UID     string    `json:"uid"`
 	Text    string    `json:"text"`
 	Data    string    `json:"data"`
+	Source  string    `json:"source,omitempty"`
 	Success bool      `json:"success"`
 }
 
@@ -70,6 +72,7 @@ type TokenResponse struct {
 	Text     string    `json:"text"`
 	Data     string    `json:"data"`
 	ScanTime time.Time `json:"scanTime"`
+	Reader   string    `json:"reader,omitempty"`
 }
 
 type IndexingStatusResponse struct {
@@ -95,8 +98,10 @@ type ActiveMedia struct {
 }
 
 type VersionResponse struct {
-	Version  string `json:"version"`
-	Platform string `json:"platform"`
+	Version     string   `json:"version"`
+	Platform    string   `json:"platform"`
+	ApiVersion  string   `json:"apiVersion"`
+	ApiVersions []string `json:"apiVersions"`
 }
 
 type MediaResponse struct {
@@ -109,9 +114,192 @@ type TokensResponse struct {
 	Last   *TokenResponse  `json:"last,omitempty"`
 }
 
+// ClientSummaryResponse is a registered client as listed by the clients
+// method. Secrets are only returned when a client is first registered.
+type ClientSummaryResponse struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Address string    `json:"address"`
+	Scopes  []string  `json:"scopes"`
+}
+
 type ClientResponse struct {
 	ID      uuid.UUID `json:"id"`
 	Name    string    `json:"name"`
 	Address string    `json:"address"`
 	Secret  string    `json:"secret"`
+	Scopes  []string  `json:"scopes"`
+}
+
+type LaunchJobResponse struct {
+	RunID   *uuid.UUID `json:"runId,omitempty"`
+	Type    string     `json:"type,omitempty"`
+	UID     string     `json:"uid,omitempty"`
+	Text    string     `json:"text,omitempty"`
+	Source  string     `json:"source,omitempty"`
+	Queued  time.Time  `json:"queued"`
+	Started *time.Time `json:"started,omitempty"`
+}
+
+type RunQueueResponse struct {
+	Current *LaunchJobResponse  `json:"current"`
+	Pending []LaunchJobResponse `json:"pending"`
+}
+
+type AuditResponseEntry struct {
+	Time         time.Time  `json:"time"`
+	Method       string     `json:"method"`
+	ClientID     *uuid.UUID `json:"clientId,omitempty"`
+	ClientName   string     `json:"clientName,omitempty"`
+	Address      string     `json:"address"`
+	Local        bool       `json:"local"`
+	ParamsDigest string     `json:"paramsDigest,omitempty"`
+	Ok           bool       `json:"ok"`
+	ErrorCode    int        `json:"errorCode,omitempty"`
+}
+
+type AuditResponse struct {
+	Entries []AuditResponseEntry `json:"entries"`
+}
+
+type StatsMedia struct {
+	SystemID   string    `json:"systemId"`
+	SystemName string    `json:"systemName"`
+	MediaPath  string    `json:"mediaPath"`
+	MediaName  string    `json:"mediaName"`
+	Sessions   int       `json:"sessions"`
+	Playtime   int64     `json:"playtime"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+type StatsMediaResponse struct {
+	Media []StatsMedia `json:"media"`
+}
+
+type StatsSystem struct {
+	SystemID   string `json:"systemId"`
+	SystemName string `json:"systemName"`
+	Sessions   int    `json:"sessions"`
+	Playtime   int64  `json:"playtime"`
+}
+
+type StatsSystemsResponse struct {
+	Systems []StatsSystem `json:"systems"`
+}
+
+type StatsToken struct {
+	Type        string    `json:"type"`
+	UID         string    `json:"uid"`
+	Text        string    `json:"text"`
+	Scans       int       `json:"scans"`
+	LastScanned time.Time `json:"lastScanned"`
+}
+
+type StatsTokensResponse struct {
+	Tokens []StatsToken `json:"tokens"`
+}
+
+type StatsDay struct {
+	Date     string `json:"date"`
+	Sessions int    `json:"sessions"`
+	Playtime int64  `json:"playtime"`
+	Scans    int    `json:"scans"`
+}
+
+type StatsDailyResponse struct {
+	Days []StatsDay `json:"days"`
+}
+
+type ScheduledJobResponse struct {
+	Id          string     `json:"id"`
+	Label       string     `json:"label"`
+	Source      string     `json:"source"`
+	Enabled     bool       `json:"enabled"`
+	Schedule    string     `json:"schedule"`
+	ZapScript   string     `json:"zapscript"`
+	CatchUp     string     `json:"catchUp"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastSuccess bool       `json:"lastSuccess"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+type ScheduleResponse struct {
+	Jobs []ScheduledJobResponse `json:"jobs"`
+}
+
+type LimitsOverrideResponse struct {
+	Until time.Time `json:"until"`
+}
+
+type NotificationsSubscriptionsResponse struct {
+	Methods  []string `json:"methods"`
+	Excluded []string `json:"excluded"`
+}
+
+type RunResponse struct {
+	RunID uuid.UUID `json:"runId"`
+}
+
+type LaunchResultResponse struct {
+	RunID        uuid.UUID `json:"runId"`
+	Success      bool      `json:"success"`
+	Path         string    `json:"path,omitempty"`
+	Launcher     string    `json:"launcher,omitempty"`
+	MediaChanged bool      `json:"mediaChanged"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type ResolveMappingResponse struct {
+	// Source is where the matching mapping came from: database, config or
+	// platform.
+	Source    string `json:"source"`
+	MappingID string `json:"mappingId,omitempty"`
+	Label     string `json:"label,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Match     string `json:"match,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	ZapScript string `json:"zapscript"`
+}
+
+type ResolveCommandResponse struct {
+	Text      string            `json:"text"`
+	ZapLink   bool              `json:"zapLink"`
+	Download  string            `json:"download,omitempty"`
+	Cmd       string            `json:"cmd"`
+	Args      string            `json:"args"`
+	NamedArgs map[string]string `json:"namedArgs"`
+	Path      string            `json:"path,omitempty"`
+	Launcher  string            `json:"launcher,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+type RunResolveResponse struct {
+	Mapping   *ResolveMappingResponse  `json:"mapping,omitempty"`
+	ZapScript string                   `json:"zapscript"`
+	Commands  []ResolveCommandResponse `json:"commands"`
+}
+
+type ConfirmResponse struct {
+	Confirmed bool `json:"confirmed"`
+}
+
+type CertificateResponse struct {
+	Tls         bool       `json:"tls"`
+	SelfSigned  bool       `json:"selfSigned"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	NotAfter    *time.Time `json:"notAfter,omitempty"`
+}
+
+type MethodResponse struct {
+	Name       string         `json:"name"`
+	Deprecated bool           `json:"deprecated"`
+	ReplacedBy string         `json:"replacedBy,omitempty"`
+	Scope      string         `json:"scope,omitempty"`
+	Params     *schema.Schema `json:"params,omitempty"`
+	Result     *schema.Schema `json:"result,omitempty"`
+}
+
+type MethodsResponse struct {
+	Methods []MethodResponse `json:"methods"`
 }
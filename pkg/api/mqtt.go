@@ -0,0 +1,388 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	mqttDefaultTopicPrefix     = "zaparoo"
+	mqttDefaultDiscoveryPrefix = "homeassistant"
+	mqttStatusOnline           = "online"
+	mqttStatusOffline          = "offline"
+	mqttConnectTimeout         = 10 * time.Second
+	mqttDisconnectWait         = 250 // milliseconds
+)
+
+// mqttDefaultScopes are the scopes of MQTT commands if none are set in the
+// config. Anyone able to publish to the broker can send commands, so they
+// can't change settings by default.
+var mqttDefaultScopes = []string{
+	models.ScopeRead,
+	models.ScopeLaunch,
+}
+
+// mqttStateMethods are the notifications which change the retained state
+// published by the bridge.
+var mqttStateMethods = []string{
+	models.NotificationStarted,
+	models.NotificationStopped,
+	models.NotificationTokensAdded,
+	models.NotificationTokensRemoved,
+	models.NotificationReadersConnected,
+	models.NotificationReadersDisconnected,
+}
+
+// mqttBridge forwards API notifications to an MQTT broker and runs API
+// requests received on its command topic. All topics are under
+// <topic_prefix>/<device_id>:
+//
+//   - events/<method>: every API notification, as a JSON-RPC notification
+//   - command: JSON-RPC requests, the same as sent to the API, wrapped in
+//     a models.MqttCommand with the secret set in the config
+//   - response: responses to requests sent on the command topic
+//   - state/media, state/token, state/readers: retained current state
+//   - status: retained online/offline availability
+type mqttBridge struct {
+	client          mqtt.Client
+	version         *apiVersion
+	platform        platforms.Platform
+	cfg             *config.Instance
+	st              *state.State
+	inTokenQueue    chan<- tokens.Token
+	db              *database.Database
+	limitsOverride  requests.LimitsOverrideFunc
+	broker          string
+	secret          string
+	scopes          []string
+	topic           string
+	deviceId        string
+	discovery       bool
+	discoveryPrefix string
+	// commands tracks running command handlers, so the token queue isn't
+	// closed while one may still send to it.
+	commands sync.WaitGroup
+	// done is closed once the bridge has disconnected and all commands
+	// have finished.
+	done chan struct{}
+}
+
+func (b *mqttBridge) publish(topic string, retained bool, payload any) {
+	var data []byte
+	switch v := payload.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		data, err = json.Marshal(v)
+		if err != nil {
+			log.Error().Err(err).Msgf("marshalling mqtt payload: %s", topic)
+			return
+		}
+	}
+
+	// publishing is async, errors are logged by the client
+	b.client.Publish(topic, 1, retained, data)
+}
+
+// publishState publishes the current active media, active token and
+// connected readers as retained messages.
+func (b *mqttBridge) publishState() {
+	media := b.st.ActiveMedia()
+	if media != nil {
+		b.publish(b.topic+"/state/media", true, media)
+	} else {
+		b.publish(b.topic+"/state/media", true, "{}")
+	}
+
+	active := b.st.GetActiveCard()
+	if !active.ScanTime.IsZero() {
+		b.publish(b.topic+"/state/token", true, models.TokenResponse{
+			Type:     active.Type,
+			UID:      active.UID,
+			Text:     active.Text,
+			Data:     active.Data,
+			ScanTime: active.ScanTime,
+		})
+	} else {
+		b.publish(b.topic+"/state/token", true, "{}")
+	}
+
+	rs := make([]models.ReaderResponse, 0)
+	for _, device := range b.st.ListReaders() {
+		ps := strings.SplitN(device, ":", 2)
+		r := models.ReaderResponse{
+			Connected: true,
+			Driver:    ps[0],
+		}
+		if len(ps) > 1 {
+			r.Path = ps[1]
+		}
+		rs = append(rs, r)
+	}
+	b.publish(b.topic+"/state/readers", true, rs)
+}
+
+// publishDiscovery publishes Home Assistant MQTT discovery configs for the
+// bridge's state topics, so the Core instance shows up as a device.
+func (b *mqttBridge) publishDiscovery() {
+	id := strings.ReplaceAll(b.deviceId, "-", "")
+	device := map[string]any{
+		"identifiers":  []string{"zaparoo_" + id},
+		"name":         "Zaparoo Core (" + b.platform.Id() + ")",
+		"manufacturer": "Zaparoo",
+		"model":        b.platform.Id(),
+		"sw_version":   config.AppVersion,
+	}
+
+	sensors := []struct {
+		key      string
+		name     string
+		topic    string
+		template string
+		icon     string
+	}{
+		{
+			key:      "media",
+			name:     "Active Media",
+			topic:    b.topic + "/state/media",
+			template: "{{ value_json.mediaName | default('None') }}",
+			icon:     "mdi:gamepad-variant",
+		},
+		{
+			key:      "system",
+			name:     "Active System",
+			topic:    b.topic + "/state/media",
+			template: "{{ value_json.systemName | default('None') }}",
+			icon:     "mdi:console",
+		},
+		{
+			key:      "token",
+			name:     "Active Token",
+			topic:    b.topic + "/state/token",
+			template: "{{ value_json.text | default(value_json.uid) | default('None') }}",
+			icon:     "mdi:nfc",
+		},
+		{
+			key:      "readers",
+			name:     "Connected Readers",
+			topic:    b.topic + "/state/readers",
+			template: "{{ value_json | count }}",
+			icon:     "mdi:nfc-variant",
+		},
+	}
+
+	for _, s := range sensors {
+		uid := fmt.Sprintf("zaparoo_%s_%s", id, s.key)
+		b.publish(
+			fmt.Sprintf("%s/sensor/%s/config", b.discoveryPrefix, uid),
+			true,
+			map[string]any{
+				"name":                  s.name,
+				"unique_id":             uid,
+				"object_id":             uid,
+				"state_topic":           s.topic,
+				"value_template":        s.template,
+				"json_attributes_topic": s.topic,
+				"availability_topic":    b.topic + "/status",
+				"icon":                  s.icon,
+				"device":                device,
+			},
+		)
+	}
+}
+
+// notify publishes a notification to its event topic and updates the
+// retained state if the notification changes it.
+func (b *mqttBridge) notify(notif models.Notification) {
+	if !b.client.IsConnectionOpen() {
+		return
+	}
+
+	b.publish(b.topic+"/events/"+notif.Method, false, b.version.notification(notif))
+
+	for _, m := range mqttStateMethods {
+		if m == notif.Method {
+			b.publishState()
+			break
+		}
+	}
+}
+
+// mqttScopes returns the scopes set for MQTT commands in the config,
+// ignoring any which don't exist.
+func mqttScopes(mc config.Mqtt) []string {
+	if len(mc.Scopes) == 0 {
+		return mqttDefaultScopes
+	}
+
+	scopes := make([]string, 0, len(mc.Scopes))
+	for _, s := range mc.Scopes {
+		if !slices.Contains(models.AllScopes, s) {
+			log.Warn().Msgf("ignoring invalid mqtt scope: %s", s)
+			continue
+		}
+		scopes = append(scopes, s)
+	}
+
+	return scopes
+}
+
+// handleCommand runs a command received on the command topic and publishes
+// the response. Commands without the correct secret are dropped.
+func (b *mqttBridge) handleCommand(payload []byte) {
+	log.Debug().Msgf("received mqtt command: %s", payload)
+
+	var cmd models.MqttCommand
+	err := json.Unmarshal(payload, &cmd)
+	if err != nil {
+		log.Warn().Err(err).Msg("invalid mqtt command")
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cmd.Secret), []byte(b.secret)) != 1 {
+		log.Warn().Msg("dropping mqtt command with invalid secret")
+		return
+	}
+
+	env := requests.RequestEnv{
+		Platform:       b.platform,
+		Config:         b.cfg,
+		State:          b.st,
+		Database:       b.db,
+		TokenQueue:     b.inTokenQueue,
+		IsLocal:        false,
+		Address:        "mqtt:" + b.broker,
+		Scopes:         b.scopes,
+		ApiVersion:     b.version.name,
+		LimitsOverride: b.limitsOverride,
+	}
+
+	resp, ok := processMessage(b.version.methods, env, cmd.Request)
+	if !ok {
+		return
+	}
+
+	b.publish(b.topic+"/response", false, resp)
+}
+
+// startMqtt connects to the MQTT broker in the config and returns a bridge
+// ready to be sent notifications. The connection is retried in the
+// background if the broker is unavailable, and closed when the service
+// context is cancelled.
+func startMqtt(
+	version *apiVersion,
+	platform platforms.Platform,
+	cfg *config.Instance,
+	st *state.State,
+	inTokenQueue chan<- tokens.Token,
+	db *database.Database,
+	limitsOverride requests.LimitsOverrideFunc,
+) (*mqttBridge, error) {
+	mc := cfg.Mqtt()
+	if mc.Broker == "" {
+		return nil, fmt.Errorf("mqtt broker not set")
+	}
+
+	deviceId := cfg.DeviceId()
+	if deviceId == "" {
+		return nil, fmt.Errorf("device id not set")
+	}
+
+	prefix := mc.TopicPrefix
+	if prefix == "" {
+		prefix = mqttDefaultTopicPrefix
+	}
+
+	b := &mqttBridge{
+		version:         version,
+		platform:        platform,
+		cfg:             cfg,
+		st:              st,
+		inTokenQueue:    inTokenQueue,
+		db:              db,
+		limitsOverride:  limitsOverride,
+		broker:          mc.Broker,
+		secret:          mc.Secret,
+		scopes:          mqttScopes(mc),
+		done:            make(chan struct{}),
+		topic:           strings.TrimSuffix(prefix, "/") + "/" + deviceId,
+		deviceId:        deviceId,
+		discovery:       mc.Discovery,
+		discoveryPrefix: mc.DiscoveryPrefix,
+	}
+	if b.discoveryPrefix == "" {
+		b.discoveryPrefix = mqttDefaultDiscoveryPrefix
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(mc.Broker).
+		SetClientID(config.AppName+"-"+deviceId).
+		SetUsername(mc.Username).
+		SetPassword(mc.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOrderMatters(false).
+		SetWill(b.topic+"/status", mqttStatusOffline, 1, true)
+
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		log.Info().Msgf("connected to mqtt broker: %s", mc.Broker)
+
+		if b.secret != "" {
+			token := c.Subscribe(b.topic+"/command", 1, func(c mqtt.Client, msg mqtt.Message) {
+				b.commands.Add(1)
+				defer b.commands.Done()
+				b.handleCommand(msg.Payload())
+			})
+			if token.WaitTimeout(mqttConnectTimeout) && token.Error() != nil {
+				log.Error().Err(token.Error()).Msg("subscribing to mqtt command topic")
+			}
+		} else {
+			log.Warn().Msg("mqtt secret not set, commands are disabled")
+		}
+
+		b.publish(b.topic+"/status", true, mqttStatusOnline)
+		if b.discovery {
+			b.publishDiscovery()
+		}
+		b.publishState()
+	})
+
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		log.Warn().Err(err).Msg("lost connection to mqtt broker")
+	})
+
+	b.client = mqtt.NewClient(opts)
+	b.client.Connect()
+
+	go func() {
+		<-st.GetContext().Done()
+		log.Debug().Msg("closing mqtt bridge via context cancellation")
+		if b.client.IsConnectionOpen() {
+			b.client.Publish(b.topic+"/status", 1, true, mqttStatusOffline).
+				WaitTimeout(time.Second)
+		}
+		b.client.Disconnect(mqttDisconnectWait)
+		b.commands.Wait()
+		close(b.done)
+	}()
+
+	return b, nil
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mqttPublished struct {
+	topic    string
+	retained bool
+	payload  []byte
+}
+
+// testMqttClient records published messages. Any other call panics on the
+// nil embedded interface.
+type testMqttClient struct {
+	mqtt.Client
+	mu        sync.Mutex
+	published []mqttPublished
+}
+
+func (c *testMqttClient) IsConnectionOpen() bool {
+	return true
+}
+
+func (c *testMqttClient) Publish(topic string, _ byte, retained bool, payload any) mqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, _ := payload.([]byte)
+	c.published = append(c.published, mqttPublished{
+		topic:    topic,
+		retained: retained,
+		payload:  data,
+	})
+	return nil
+}
+
+func (c *testMqttClient) topics() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ts := make([]string, 0, len(c.published))
+	for _, p := range c.published {
+		ts = append(ts, p.topic)
+	}
+	return ts
+}
+
+func testMqttBridge(t *testing.T, scopes []string) (*mqttBridge, *testMqttClient) {
+	var m MethodMap
+	require.NoError(t, m.AddMethod(models.MethodVersion, func(requests.RequestEnv) (any, error) {
+		return "version", nil
+	}))
+	require.NoError(t, m.AddMethod(models.MethodSettingsUpdate, func(requests.RequestEnv) (any, error) {
+		return "updated", nil
+	}))
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+
+	client := &testMqttClient{}
+	return &mqttBridge{
+		client: client,
+		version: &apiVersion{
+			name:         models.ApiVersionLatest,
+			methods:      &m,
+			notification: defaultNotification,
+		},
+		st:     st,
+		secret: "secret",
+		scopes: mqttScopes(config.Mqtt{Scopes: scopes}),
+		topic:  "zaparoo/device",
+	}, client
+}
+
+func testMqttCommand(t *testing.T, secret string, method string) ([]byte, uuid.UUID) {
+	id := uuid.New()
+	req, err := json.Marshal(models.RequestObject{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Method:  method,
+	})
+	require.NoError(t, err)
+
+	cmd, err := json.Marshal(models.MqttCommand{
+		Secret:  secret,
+		Request: req,
+	})
+	require.NoError(t, err)
+
+	return cmd, id
+}
+
+func TestMqttCommand(t *testing.T) {
+	b, client := testMqttBridge(t, nil)
+
+	cmd, id := testMqttCommand(t, "secret", models.MethodVersion)
+	b.handleCommand(cmd)
+
+	require.Len(t, client.published, 1)
+	assert.Equal(t, "zaparoo/device/response", client.published[0].topic)
+	assert.False(t, client.published[0].retained)
+
+	var resp models.ResponseObject
+	require.NoError(t, json.Unmarshal(client.published[0].payload, &resp))
+	assert.Equal(t, id, resp.ID)
+	assert.Equal(t, "version", resp.Result)
+	assert.Nil(t, resp.Error)
+}
+
+func TestMqttCommandSecret(t *testing.T) {
+	b, client := testMqttBridge(t, nil)
+
+	for _, secret := range []string{"", "wrong", "secret2"} {
+		cmd, _ := testMqttCommand(t, secret, models.MethodVersion)
+		b.handleCommand(cmd)
+	}
+
+	// requests sent without the command wrapper are dropped too
+	b.handleCommand([]byte(`{"jsonrpc":"2.0","id":"` + uuid.NewString() + `","method":"version"}`))
+	b.handleCommand([]byte("not json"))
+
+	assert.Empty(t, client.published)
+}
+
+func TestMqttCommandScopes(t *testing.T) {
+	assert.Equal(t, mqttDefaultScopes, mqttScopes(config.Mqtt{}))
+	assert.Equal(t,
+		[]string{models.ScopeRead, models.ScopeAdmin},
+		mqttScopes(config.Mqtt{Scopes: []string{models.ScopeRead, "root", models.ScopeAdmin}}),
+	)
+
+	b, client := testMqttBridge(t, nil)
+	cmd, _ := testMqttCommand(t, "secret", models.MethodSettingsUpdate)
+	b.handleCommand(cmd)
+
+	require.Len(t, client.published, 1)
+	var resp models.ResponseErrorObject
+	require.NoError(t, json.Unmarshal(client.published[0].payload, &resp))
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, models.ErrorCodeNotAllowed, resp.Error.Code)
+
+	b, client = testMqttBridge(t, []string{models.ScopeAdmin})
+	b.handleCommand(cmd)
+
+	require.Len(t, client.published, 1)
+	var ok models.ResponseObject
+	require.NoError(t, json.Unmarshal(client.published[0].payload, &ok))
+	assert.Nil(t, ok.Error)
+	assert.Equal(t, "updated", ok.Result)
+}
+
+func TestMqttNotify(t *testing.T) {
+	b, client := testMqttBridge(t, nil)
+
+	b.notify(models.Notification{
+		Method: models.NotificationMediaIndexing,
+		Params: json.RawMessage(`{"indexing":true}`),
+	})
+	require.Equal(t, []string{"zaparoo/device/events/media.indexing"}, client.topics())
+
+	var notif models.RequestObject
+	require.NoError(t, json.Unmarshal(client.published[0].payload, &notif))
+	assert.Equal(t, models.NotificationMediaIndexing, notif.Method)
+	assert.Nil(t, notif.ID)
+	assert.JSONEq(t, `{"indexing":true}`, string(notif.Params))
+
+	// state changes also update the retained state topics
+	b.notify(models.Notification{Method: models.NotificationTokensRemoved})
+	assert.Equal(t, []string{
+		"zaparoo/device/events/media.indexing",
+		"zaparoo/device/events/tokens.removed",
+		"zaparoo/device/state/media",
+		"zaparoo/device/state/token",
+		"zaparoo/device/state/readers",
+	}, client.topics())
+
+	for _, p := range client.published[2:] {
+		assert.True(t, p.retained, p.topic)
+	}
+}
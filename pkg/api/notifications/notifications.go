@@ -51,3 +51,27 @@ func ReadersAdded(ns chan<- models.Notification, payload models.ReaderResponse)
 func ReadersRemoved(ns chan<- models.Notification, payload models.ReaderResponse) {
 	sendNotification(ns, models.NotificationReadersDisconnected, payload)
 }
+
+func LaunchResult(ns chan<- models.Notification, payload models.LaunchResultResponse) {
+	sendNotification(ns, models.NotificationLaunchResult, payload)
+}
+
+func LaunchMapping(ns chan<- models.Notification, payload models.LaunchMappingParams) {
+	sendNotification(ns, models.NotificationLaunchMapping, payload)
+}
+
+func LaunchCmdStarted(ns chan<- models.Notification, payload models.LaunchCmdStartedParams) {
+	sendNotification(ns, models.NotificationLaunchCmdStarted, payload)
+}
+
+func LaunchCmdFinished(ns chan<- models.Notification, payload models.LaunchCmdFinishedParams) {
+	sendNotification(ns, models.NotificationLaunchCmdFinished, payload)
+}
+
+func LaunchFailed(ns chan<- models.Notification, payload models.LaunchFailedParams) {
+	sendNotification(ns, models.NotificationLaunchFailed, payload)
+}
+
+func PlaylistChanged(ns chan<- models.Notification, payload models.PlaylistChangedParams) {
+	sendNotification(ns, models.NotificationPlaylistChanged, payload)
+}
@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+)
+
+const AllMethods = "*"
+
+// Subscriptions is the set of notification methods a single API session
+// wants to receive. New sessions are subscribed to all notifications.
+type Subscriptions struct {
+	mu       sync.RWMutex
+	patterns []string
+	excluded []string
+}
+
+func NewSubscriptions() *Subscriptions {
+	return &Subscriptions{
+		patterns: []string{AllMethods},
+	}
+}
+
+// ValidPattern returns true if a pattern is a method name or a method
+// prefix ending in a * wildcard, like readers.*.
+func ValidPattern(pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+
+	name := strings.TrimSuffix(pattern, "*")
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func patternMatches(pattern string, method string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(method, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == method
+}
+
+func anyMatches(patterns []string, method string) bool {
+	return bestMatch(patterns, method) >= 0
+}
+
+// specificity ranks how narrow a pattern is. Exact method names rank above
+// any wildcard, and longer wildcard prefixes rank above shorter ones.
+func specificity(pattern string) int {
+	if strings.HasSuffix(pattern, "*") {
+		return len(pattern) - 1
+	}
+	return len(pattern) + 1
+}
+
+// bestMatch returns the specificity of the most specific pattern matching
+// a method, or -1 if none match.
+func bestMatch(patterns []string, method string) int {
+	best := -1
+	for _, p := range patterns {
+		if patternMatches(p, method) && specificity(p) > best {
+			best = specificity(p)
+		}
+	}
+	return best
+}
+
+// Subscribe adds the given patterns to the set, and removes any excluded
+// patterns they cover. Excluded patterns broader than a new pattern are
+// kept, the more specific subscription takes precedence when matching.
+func (s *Subscriptions) Subscribe(patterns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	excluded := make([]string, 0, len(s.excluded))
+	for _, v := range s.excluded {
+		covered := false
+		for _, p := range patterns {
+			if patternMatches(p, strings.TrimSuffix(v, "*")) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			excluded = append(excluded, v)
+		}
+	}
+	s.excluded = excluded
+
+	for _, p := range patterns {
+		if !utils.Contains(s.patterns, p) {
+			s.patterns = append(s.patterns, p)
+		}
+	}
+}
+
+// Unsubscribe removes the given patterns from the set. Patterns which
+// weren't subscribed to directly, but are covered by a wildcard, are added
+// to the excluded list instead. If no patterns are given, the session is
+// unsubscribed from all notifications.
+func (s *Subscriptions) Unsubscribe(patterns []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(patterns) == 0 {
+		s.patterns = nil
+		s.excluded = nil
+		return
+	}
+
+	kept := make([]string, 0, len(s.patterns))
+	for _, v := range s.patterns {
+		if !utils.Contains(patterns, v) {
+			kept = append(kept, v)
+		}
+	}
+	s.patterns = kept
+
+	for _, p := range patterns {
+		if anyMatches(s.patterns, strings.TrimSuffix(p, "*")) &&
+			!utils.Contains(s.excluded, p) {
+			s.excluded = append(s.excluded, p)
+		}
+	}
+}
+
+// Patterns returns copies of the current subscribed and excluded patterns.
+func (s *Subscriptions) Patterns() ([]string, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ps := make([]string, len(s.patterns))
+	copy(ps, s.patterns)
+	es := make([]string, len(s.excluded))
+	copy(es, s.excluded)
+
+	return ps, es
+}
+
+// Matches returns true if a notification method should be sent to the
+// session. When both a subscribed and an excluded pattern match, the most
+// specific one wins.
+func (s *Subscriptions) Matches(method string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub := bestMatch(s.patterns, method)
+	return sub >= 0 && sub > bestMatch(s.excluded, method)
+}
@@ -0,0 +1,115 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriptionsMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		subscribe   []string
+		unsubscribe []string
+		method      string
+		expected    bool
+	}{
+		{
+			name:     "default_all",
+			method:   "media.indexing",
+			expected: true,
+		},
+		{
+			name:        "unsubscribe_from_default",
+			unsubscribe: []string{"media.indexing"},
+			method:      "media.indexing",
+			expected:    false,
+		},
+		{
+			name:        "unsubscribe_keeps_others",
+			unsubscribe: []string{"media.indexing"},
+			method:      "media.started",
+			expected:    true,
+		},
+		{
+			name:        "prefix_subscription",
+			unsubscribe: []string{AllMethods},
+			subscribe:   []string{"readers.*"},
+			method:      "readers.added",
+			expected:    true,
+		},
+		{
+			name:        "prefix_subscription_other",
+			unsubscribe: []string{AllMethods},
+			subscribe:   []string{"readers.*"},
+			method:      "tokens.added",
+			expected:    false,
+		},
+		{
+			name:        "unsubscribe_prefix",
+			unsubscribe: []string{"readers.*"},
+			method:      "readers.removed",
+			expected:    false,
+		},
+		{
+			name:        "resubscribe_inside_excluded_prefix",
+			unsubscribe: []string{"readers.*"},
+			subscribe:   []string{"readers.added"},
+			method:      "readers.added",
+			expected:    true,
+		},
+		{
+			name:        "resubscribe_inside_excluded_prefix_other",
+			unsubscribe: []string{"readers.*"},
+			subscribe:   []string{"readers.added"},
+			method:      "readers.removed",
+			expected:    false,
+		},
+		{
+			name:        "resubscribe_prefix_over_excluded",
+			unsubscribe: []string{"readers.added"},
+			subscribe:   []string{"readers.*"},
+			method:      "readers.added",
+			expected:    true,
+		},
+		{
+			name:        "resubscribe_excluded",
+			unsubscribe: []string{"media.indexing"},
+			subscribe:   []string{"media.indexing"},
+			method:      "media.indexing",
+			expected:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := NewSubscriptions()
+			if tt.unsubscribe != nil {
+				subs.Unsubscribe(tt.unsubscribe)
+			}
+			if tt.subscribe != nil {
+				subs.Subscribe(tt.subscribe)
+			}
+			assert.Equal(t, tt.expected, subs.Matches(tt.method))
+		})
+	}
+}
+
+func TestSubscriptionsUnsubscribeAll(t *testing.T) {
+	subs := NewSubscriptions()
+	subs.Unsubscribe(nil)
+
+	ms, es := subs.Patterns()
+	assert.Empty(t, ms)
+	assert.Empty(t, es)
+	assert.False(t, subs.Matches("tokens.added"))
+}
+
+func TestValidPattern(t *testing.T) {
+	assert.True(t, ValidPattern("readers.added"))
+	assert.True(t, ValidPattern("readers.*"))
+	assert.True(t, ValidPattern("*"))
+	assert.False(t, ValidPattern(""))
+	assert.False(t, ValidPattern("readers.*.added"))
+	assert.False(t, ValidPattern("Readers"))
+}
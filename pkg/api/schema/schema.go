@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schema is a subset of JSON Schema, enough to describe the API's params
+// and result objects.
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// Null returns a schema matching only null, used for methods which don't
+// return a result.
+func Null() *Schema {
+	return &Schema{Type: "null"}
+}
+
+// OneOf returns a schema matching any of the given schemas.
+func OneOf(ss ...*Schema) *Schema {
+	return &Schema{OneOf: ss}
+}
+
+// Generate returns a schema for the JSON encoding of a value's type. Struct
+// fields are named from their json tags. Fields which are pointers, bools or
+// tagged omitempty are optional, all others are listed as required. A
+// missing bool is decoded the same as false, so they're never required.
+func Generate(v any) *Schema {
+	return generate(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+// generate builds the schema for a type. Parents holds the struct types
+// currently being generated, so recursive types are described as any value
+// instead of looping forever.
+func generate(t reflect.Type, parents map[reflect.Type]bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return generate(t.Elem(), parents)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{
+			Type:  "array",
+			Items: generate(t.Elem(), parents),
+		}
+	case reflect.Map:
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: generate(t.Elem(), parents),
+		}
+	case reflect.Struct:
+		if parents[t] {
+			return &Schema{}
+		}
+		parents[t] = true
+		defer delete(parents, t)
+
+		s := &Schema{
+			Type:       "object",
+			Properties: make(map[string]*Schema),
+		}
+		addFields(s, t, parents)
+		return s
+	default:
+		// interfaces and anything else can't be described statically
+		return &Schema{}
+	}
+}
+
+func addFields(s *Schema, t reflect.Type, parents map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(s, f.Type, parents)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s.Properties[name] = generate(f.Type, parents)
+
+		optional := f.Type.Kind() == reflect.Pointer ||
+			f.Type.Kind() == reflect.Bool ||
+			strings.Contains(","+opts+",", ",omitempty,")
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
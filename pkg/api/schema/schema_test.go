@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	type nested struct {
+		Name string `json:"name"`
+	}
+
+	type params struct {
+		ID       uuid.UUID         `json:"id"`
+		Time     time.Time         `json:"time"`
+		Count    int               `json:"count"`
+		Enabled  bool              `json:"enabled"`
+		Label    *string           `json:"label"`
+		Tags     []string          `json:"tags,omitempty"`
+		Headers  map[string]string `json:"headers,omitempty"`
+		Items    []nested          `json:"items"`
+		Ignored  string            `json:"-"`
+		internal string
+	}
+
+	s := Generate(params{})
+
+	assert.Equal(t, "object", s.Type)
+	assert.Equal(t, []string{"id", "time", "count", "items"}, s.Required)
+	assert.Len(t, s.Properties, 8)
+	assert.Equal(t, &Schema{Type: "string", Format: "uuid"}, s.Properties["id"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, s.Properties["time"])
+	assert.Equal(t, "integer", s.Properties["count"].Type)
+	assert.Equal(t, "string", s.Properties["label"].Type)
+	assert.Equal(t, "string", s.Properties["tags"].Items.Type)
+	assert.Equal(t, "string", s.Properties["headers"].AdditionalProperties.Type)
+	assert.Equal(t, "object", s.Properties["items"].Items.Type)
+	assert.Equal(t, []string{"name"}, s.Properties["items"].Items.Required)
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"slices"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+)
+
+// methodScopes is the permission scope required to call each API method. An
+// empty scope means the method can be used by every client.
+var methodScopes = map[string]string{
+	// run
+	models.MethodLaunch:     models.ScopeLaunch,
+	models.MethodRun:        models.ScopeLaunch,
+	models.MethodRunScript:  models.ScopeLaunch,
+	models.MethodRunQueue:   models.ScopeRead,
+	models.MethodRunResolve: models.ScopeRead,
+	models.MethodStop:       models.ScopeLaunch,
+	// tokens
+	models.MethodTokens:  models.ScopeRead,
+	models.MethodHistory: models.ScopeRead,
+	// media
+	models.MethodMedia:             models.ScopeRead,
+	models.MethodMediaGenerate:     models.ScopeAdmin,
+	models.MethodMediaIndex:        models.ScopeAdmin,
+	models.MethodMediaSearch:       models.ScopeRead,
+	models.MethodMediaActive:       models.ScopeRead,
+	models.MethodMediaActiveUpdate: models.ScopeWrite,
+	// settings
+	models.MethodSettings:       models.ScopeRead,
+	models.MethodSettingsUpdate: models.ScopeAdmin,
+	models.MethodSettingsReload: models.ScopeAdmin,
+	// systems
+	models.MethodSystems: models.ScopeRead,
+	// mappings
+	models.MethodMappings:       models.ScopeRead,
+	models.MethodMappingsNew:    models.ScopeAdmin,
+	models.MethodMappingsDelete: models.ScopeAdmin,
+	models.MethodMappingsUpdate: models.ScopeAdmin,
+	models.MethodMappingsReload: models.ScopeAdmin,
+	// readers
+	models.MethodReadersWrite: models.ScopeWrite,
+	// clients
+	models.MethodClients:       models.ScopeAdmin,
+	models.MethodClientsNew:    models.ScopeAdmin,
+	models.MethodClientsDelete: models.ScopeAdmin,
+	models.MethodClientsSecret: models.ScopeAdmin,
+	// notifications
+	models.MethodNotificationsSubscribe:   "",
+	models.MethodNotificationsUnsubscribe: "",
+	// stats
+	models.MethodStatsMedia:   models.ScopeRead,
+	models.MethodStatsSystems: models.ScopeRead,
+	models.MethodStatsTokens:  models.ScopeRead,
+	models.MethodStatsDaily:   models.ScopeRead,
+	// schedule
+	models.MethodSchedule:       models.ScopeRead,
+	models.MethodScheduleNew:    models.ScopeAdmin,
+	models.MethodScheduleUpdate: models.ScopeAdmin,
+	models.MethodScheduleDelete: models.ScopeAdmin,
+	// limits
+	models.MethodLimitsOverride: models.ScopeAdmin,
+	// utils
+	models.MethodAudit:       models.ScopeAdmin,
+	models.MethodCertificate: "",
+	models.MethodVersion:     "",
+	models.MethodMethods:     "",
+}
+
+// requestScopes returns the permission scopes of a request. Requests from a
+// registered client have the client's scopes, and local requests with no
+// client have every scope.
+func requestScopes(isLocal bool, client *database.Client) []string {
+	if client != nil {
+		return methods.ClientScopes(*client)
+	}
+
+	if isLocal {
+		return models.AllScopes
+	}
+
+	return nil
+}
+
+// checkScope returns an error if a request isn't allowed to call a method.
+// Methods missing from the scopes table are treated as admin only.
+func checkScope(scopes []string, method string) error {
+	scope, ok := methodScopes[method]
+	if !ok {
+		scope = models.ScopeAdmin
+	}
+
+	if scope == "" {
+		return nil
+	}
+
+	if slices.Contains(scopes, scope) {
+		return nil
+	}
+
+	return methods.WrapError(
+		methods.ErrNotAllowed,
+		nil,
+		map[string]string{"scope": scope},
+	)
+}
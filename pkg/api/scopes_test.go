@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethodScopes(t *testing.T) {
+	methodMap := NewMethodMap()
+	for _, name := range methodMap.ListMethods() {
+		_, ok := methodScopes[name]
+		assert.True(t, ok, "missing method scope: %s", name)
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		method  string
+		allowed bool
+	}{
+		{
+			name:    "unscoped method",
+			method:  models.MethodVersion,
+			allowed: true,
+		},
+		{
+			name:    "launch allowed",
+			scopes:  []string{models.ScopeLaunch},
+			method:  models.MethodRun,
+			allowed: true,
+		},
+		{
+			name:    "settings update denied",
+			scopes:  []string{models.ScopeRead, models.ScopeLaunch},
+			method:  models.MethodSettingsUpdate,
+			allowed: false,
+		},
+		{
+			name:    "mappings delete denied",
+			scopes:  []string{models.ScopeLaunch},
+			method:  models.MethodMappingsDelete,
+			allowed: false,
+		},
+		{
+			name:    "unknown method is admin",
+			scopes:  []string{models.ScopeRead},
+			method:  "custom",
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkScope(tt.scopes, tt.method)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, methods.ErrNotAllowed))
+			}
+		})
+	}
+}
+
+func TestRequestScopes(t *testing.T) {
+	assert.Equal(t, models.AllScopes, requestScopes(true, nil))
+	assert.Nil(t, requestScopes(false, nil))
+
+	legacy := &database.Client{}
+	assert.Equal(t, models.LegacyScopes, requestScopes(false, legacy))
+	assert.NotContains(t, requestScopes(false, legacy), models.ScopeUnsafe)
+
+	kid := &database.Client{Scopes: []string{models.ScopeLaunch}}
+	assert.Equal(t, []string{models.ScopeLaunch}, requestScopes(true, kid))
+}
@@ -2,11 +2,17 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/certs"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
 	"github.com/ZaparooProject/zaparoo-core/pkg/assets"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
@@ -17,6 +23,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
@@ -30,30 +37,42 @@ import (
 )
 
 var JSONRPCErrorParseError = models.ErrorObject{
-	Code:    -32700,
+	Code:    models.ErrorCodeParseError,
 	Message: "Parse error",
 }
 var JSONRPCErrorInvalidRequest = models.ErrorObject{
-	Code:    -32600,
+	Code:    models.ErrorCodeInvalidRequest,
 	Message: "Invalid Request",
 }
 var JSONRPCErrorMethodNotFound = models.ErrorObject{
-	Code:    -32601,
+	Code:    models.ErrorCodeMethodNotFound,
 	Message: "Method not found",
 }
 var JSONRPCErrorInvalidParams = models.ErrorObject{
-	Code:    -32602,
+	Code:    models.ErrorCodeInvalidParams,
 	Message: "Invalid params",
 }
 var JSONRPCErrorInternalError = models.ErrorObject{
-	Code:    -32603,
+	Code:    models.ErrorCodeInternalError,
 	Message: "Internal error",
 }
 
-func makeJSONRPCError(code int, message string) models.ErrorObject {
+// makeJSONRPCError converts an error returned by a method into an error
+// object. Typed method errors keep their code and data, any other error is
+// returned with the generic error code.
+func makeJSONRPCError(err error) models.ErrorObject {
+	var methodErr *methods.Error
+	if errors.As(err, &methodErr) {
+		return models.ErrorObject{
+			Code:    methodErr.Code,
+			Message: methodErr.Error(),
+			Data:    methodErr.Data,
+		}
+	}
+
 	return models.ErrorObject{
-		Code:    code,
-		Message: message,
+		Code:    models.ErrorCodeGeneric,
+		Message: err.Error(),
 	}
 }
 
@@ -107,10 +126,12 @@ func NewMethodMap() *MethodMap {
 
 	defaultMethods := map[string]func(requests.RequestEnv) (any, error){
 		// run
-		models.MethodLaunch:    methods.HandleRun,
-		models.MethodRun:       methods.HandleRun,
-		models.MethodRunScript: methods.HandleRunScript,
-		models.MethodStop:      methods.HandleStop,
+		models.MethodLaunch:     methods.HandleRun,
+		models.MethodRun:        methods.HandleRun,
+		models.MethodRunScript:  methods.HandleRunScript,
+		models.MethodRunQueue:   methods.HandleRunQueue,
+		models.MethodRunResolve: methods.HandleRunResolve,
+		models.MethodStop:       methods.HandleStop,
 		// tokens
 		models.MethodTokens:  methods.HandleTokens,
 		models.MethodHistory: methods.HandleHistory,
@@ -135,8 +156,30 @@ func NewMethodMap() *MethodMap {
 		models.MethodMappingsReload: methods.HandleReloadMappings,
 		// readers
 		models.MethodReadersWrite: methods.HandleReaderWrite,
+		// clients
+		models.MethodClients:       methods.HandleClients,
+		models.MethodClientsNew:    methods.HandleNewClient,
+		models.MethodClientsDelete: methods.HandleDeleteClient,
+		models.MethodClientsSecret: methods.HandleClientSecret,
+		// notifications
+		models.MethodNotificationsSubscribe:   methods.HandleNotificationsSubscribe,
+		models.MethodNotificationsUnsubscribe: methods.HandleNotificationsUnsubscribe,
+		// stats
+		models.MethodStatsMedia:   methods.HandleStatsMedia,
+		models.MethodStatsSystems: methods.HandleStatsSystems,
+		models.MethodStatsTokens:  methods.HandleStatsTokens,
+		models.MethodStatsDaily:   methods.HandleStatsDaily,
+		// schedule
+		models.MethodSchedule:       methods.HandleSchedule,
+		models.MethodScheduleNew:    methods.HandleAddScheduledJob,
+		models.MethodScheduleUpdate: methods.HandleUpdateScheduledJob,
+		models.MethodScheduleDelete: methods.HandleDeleteScheduledJob,
+		// limits
+		models.MethodLimitsOverride: methods.HandleLimitsOverride,
 		// utils
-		models.MethodVersion: methods.HandleVersion,
+		models.MethodCertificate: methods.HandleCertificate,
+		models.MethodVersion:     methods.HandleVersion,
+		models.MethodAudit:       methods.HandleAudit,
 	}
 
 	for name, fn := range defaultMethods {
@@ -146,6 +189,11 @@ func NewMethodMap() *MethodMap {
 		}
 	}
 
+	err := m.AddMethod(models.MethodMethods, handleMethods(&m))
+	if err != nil {
+		log.Error().Err(err).Msgf("error adding default method: %s", models.MethodMethods)
+	}
+
 	return &m
 }
 
@@ -165,58 +213,167 @@ func handleRequest(methodMap *MethodMap, env requests.RequestEnv, req models.Req
 		return nil, &JSONRPCErrorInvalidRequest
 	}
 
+	err := checkScope(env.Scopes, req.Method)
+	if err != nil {
+		log.Warn().Err(err).Str("method", req.Method).Msg("request missing scope")
+		rpcError := makeJSONRPCError(err)
+		return nil, &rpcError
+	}
+
 	env.ID = *req.ID
 	env.Params = req.Params
 
 	resp, err := fn(env)
 	if err != nil {
 		log.Error().Err(err).Msg("error handling request")
-		// TODO: return error object from methods
-		rpcError := makeJSONRPCError(1, err.Error())
+		rpcError := makeJSONRPCError(err)
 		return nil, &rpcError
 	}
 	return resp, nil
 
 }
 
-// sendWSResponse marshals a method result and sends it to the client.
-func sendWSResponse(session *melody.Session, id uuid.UUID, result any) error {
-	log.Debug().Interface("result", result).Msg("sending response")
+// makeResponseObject builds the JSON-RPC response object sent back to the
+// client for a handled request.
+func makeResponseObject(id uuid.UUID, result any, rpcError *models.ErrorObject) any {
+	if rpcError != nil {
+		log.Debug().Int("code", rpcError.Code).Str("message", rpcError.Message).Msg("sending error")
+		return models.ResponseErrorObject{
+			JSONRPC: "2.0",
+			ID:      id,
+			Error:   rpcError,
+		}
+	}
 
-	resp := models.ResponseObject{
+	log.Debug().Interface("result", result).Msg("sending response")
+	return models.ResponseObject{
 		JSONRPC: "2.0",
 		ID:      id,
 		Result:  result,
 	}
+}
 
-	data, err := json.Marshal(resp)
+// sendWSResponse marshals a response payload and sends it to the client.
+func sendWSResponse(session *melody.Session, payload any) error {
+	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("error marshalling response: %w", err)
 	}
 
-	return session.Write(data)
+	return writeSession(session, data)
 }
 
-// sendWSError sends a JSON-RPC error object response to the client.
-func sendWSError(session *melody.Session, id uuid.UUID, error models.ErrorObject) error {
-	log.Debug().Int("code", error.Code).Str("message", error.Message).Msg("sending error")
+// clientIp returns the parsed IP address of a remote address string.
+func clientIp(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
 
-	resp := models.ResponseErrorObject{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error:   &error,
+// requestSecret returns the client secret sent with an HTTP request, if any.
+// Secrets are read from a bearer Authorization header, or from the secret
+// query parameter for browser WebSocket clients which can't set headers.
+func requestSecret(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return r.URL.Query().Get("secret")
+}
+
+// authenticateRequest checks if an HTTP request is allowed to access the API.
+// Loopback requests are always allowed, all other requests must include the
+// secret of a registered client. Returns the matching client, if one was
+// given, and whether access is allowed.
+func authenticateRequest(db *database.Database, r *http.Request) (*database.Client, bool) {
+	isLocal := clientIp(r.RemoteAddr).IsLoopback()
+
+	secret := requestSecret(r)
+	if secret == "" {
+		return nil, isLocal
 	}
 
-	data, err := json.Marshal(resp)
+	c, err := db.GetClientBySecret(secret)
 	if err != nil {
-		return fmt.Errorf("error marshalling error response: %w", err)
+		log.Warn().Err(err).Msgf("invalid client secret from: %s", r.RemoteAddr)
+		return nil, false
+	}
+
+	address := clientIp(r.RemoteAddr).String()
+	if c.Address != address {
+		err := db.UpdateClientAddress(c.ID, address)
+		if err != nil {
+			log.Error().Err(err).Msg("error updating client address")
+		}
+		c.Address = address
 	}
 
-	return session.Write(data)
+	return &c, true
 }
 
-func handleResponse(resp models.ResponseObject) error {
+const (
+	sessionKeyID            = "id"
+	sessionKeyClient        = "client"
+	sessionKeySubscriptions = "subscriptions"
+)
+
+// sessionClient returns the registered client attached to a WebSocket
+// session during the initial upgrade request.
+func sessionClient(session *melody.Session) *database.Client {
+	v, ok := session.Get(sessionKeyClient)
+	if !ok {
+		return nil
+	}
+	c, ok := v.(*database.Client)
+	if !ok {
+		return nil
+	}
+	return c
+}
+
+// sessionSubscriptions returns the notification subscriptions of a
+// WebSocket session.
+func sessionSubscriptions(session *melody.Session) *notifications.Subscriptions {
+	v, ok := session.Get(sessionKeySubscriptions)
+	if !ok {
+		return nil
+	}
+	subs, ok := v.(*notifications.Subscriptions)
+	if !ok {
+		return nil
+	}
+	return subs
+}
+
+// sessionID returns the unique ID assigned to a WebSocket session when it
+// was upgraded.
+func sessionID(session *melody.Session) uuid.UUID {
+	v, ok := session.Get(sessionKeyID)
+	if !ok {
+		return uuid.Nil
+	}
+	id, ok := v.(uuid.UUID)
+	if !ok {
+		return uuid.Nil
+	}
+	return id
+}
+
+// handleResponse forwards a response sent by a client to the request Core
+// is waiting on it for.
+func handleResponse(env requests.RequestEnv, resp models.ResponseObject) error {
 	log.Debug().Interface("response", resp).Msg("received response")
+
+	if env.Sessions == nil || env.SessionID == uuid.Nil {
+		return errors.New("responses are only accepted from websocket sessions")
+	}
+
+	if !env.Sessions.Resolve(env.SessionID, resp) {
+		return fmt.Errorf("no pending request for response: %s", resp.ID)
+	}
+
 	return nil
 }
 
@@ -232,12 +389,25 @@ func handleApp(w http.ResponseWriter, r *http.Request) {
 	http.StripPrefix("/app", http.FileServer(http.FS(appFs))).ServeHTTP(w, r)
 }
 
+// NotificationHandler is passed every notification broadcast by the API
+// server, for services which forward them outside of the API. Handlers are
+// called from the broadcast loop and must not block.
+type NotificationHandler func(models.Notification)
+
 // broadcastNotifications consumes and broadcasts all incoming API
-// notifications to all connected clients.
+// notifications to all connected clients. Notifications are written to each
+// session individually so encrypted sessions can be sealed with their own
+// key, and only sent to sessions subscribed to the notification method.
+// Each session is sent the notification in the format of the API version it
+// connected with. Event stream clients are sent the latest version's format
+// and notification handlers are passed every notification as is.
 func broadcastNotifications(
 	state *state.State,
 	session *melody.Melody,
+	versions map[string]*apiVersion,
+	streams *eventStreams,
 	notifications <-chan models.Notification,
+	handlers []NotificationHandler,
 ) {
 	for {
 		select {
@@ -245,35 +415,73 @@ func broadcastNotifications(
 			log.Debug().Msg("closing HTTP server via context cancellation")
 			return
 		case notif := <-notifications:
-			req := models.RequestObject{
-				JSONRPC: "2.0",
-				Method:  notif.Method,
-				Params:  notif.Params,
+			for _, h := range handlers {
+				h(notif)
 			}
 
-			data, err := json.Marshal(req)
+			data, err := versions[models.ApiVersionLatest].marshal(notif)
 			if err != nil {
 				log.Error().Err(err).Msg("marshalling notification request")
 				continue
 			}
 
-			// TODO: this will not work with encryption
-			err = session.Broadcast(data)
+			// each version's format is only marshalled once per notification
+			encoded := map[string][]byte{
+				models.ApiVersionLatest: data,
+			}
+
+			streams.broadcast(event{
+				method: notif.Method,
+				data:   data,
+			})
+
+			sessions, err := session.Sessions()
 			if err != nil {
-				log.Error().Err(err).Msg("broadcasting notification")
+				log.Error().Err(err).Msg("listing sessions for notification")
+				continue
+			}
+
+			for _, s := range sessions {
+				if s.IsClosed() {
+					continue
+				}
+
+				subs := sessionSubscriptions(s)
+				if subs != nil && !subs.Matches(notif.Method) {
+					continue
+				}
+
+				v := sessionVersion(versions, s)
+				vdata, ok := encoded[v.name]
+				if !ok {
+					vdata, err = v.marshal(notif)
+					if err != nil {
+						log.Error().Err(err).Msgf("marshalling %s notification request", v.name)
+						continue
+					}
+					encoded[v.name] = vdata
+				}
+
+				err := writeSession(s, vdata)
+				if err != nil {
+					log.Error().Err(err).Msg("broadcasting notification")
+				}
 			}
 		}
 	}
 }
 
+// processRequestObject handles a single JSON-RPC object sent by a client.
+// Returns the response object to send back and whether a response should be
+// sent at all, notifications and responses are never replied to.
 func processRequestObject(
 	methodMap *MethodMap,
 	env requests.RequestEnv,
 	msg []byte,
-) (uuid.UUID, any, *models.ErrorObject) {
+) (any, bool) {
 	if !json.Valid(msg) {
 		log.Error().Msg("request payload is not valid JSON")
-		return uuid.Nil, nil, &JSONRPCErrorParseError
+		return makeResponseObject(uuid.Nil, nil, &JSONRPCErrorParseError), true
 	}
 
 	// try parse a request first, which has a method field
@@ -286,51 +494,94 @@ func processRequestObject(
 			id = *req.ID
 		}
 		log.Error().Str("version", req.JSONRPC).Msg("unsupported JSON-RPC version")
-		return id, nil, &JSONRPCErrorInvalidRequest
+		return makeResponseObject(id, nil, &JSONRPCErrorInvalidRequest), true
 	}
 
 	if err == nil && req.Method != "" {
 		if req.ID == nil {
 			// request is notification, we don't do anything with these yet
 			log.Info().Interface("req", req).Msg("received notification, ignoring")
-			return uuid.Nil, nil, nil
+			return nil, false
 		}
 
 		// request is a request
 		resp, rpcError := handleRequest(methodMap, env, req)
-		if rpcError != nil {
-			return *req.ID, nil, rpcError
-		} else {
-			return *req.ID, resp, nil
-		}
+		auditRequest(env, req, rpcError)
+		return makeResponseObject(*req.ID, resp, rpcError), true
 	}
 
 	// otherwise try parse a response, which has an id field
 	var resp models.ResponseObject
 	err = json.Unmarshal(msg, &resp)
 	if err == nil && resp.ID != uuid.Nil {
-		err := handleResponse(resp)
+		err := handleResponse(env, resp)
 		if err != nil {
 			log.Error().Err(err).Msg("error handling response")
-			return resp.ID, nil, &JSONRPCErrorInternalError
 		}
-		return resp.ID, nil, nil
+		return nil, false
 	}
 
 	// can't identify the message
-	return uuid.Nil, nil, &JSONRPCErrorInvalidRequest
+	return makeResponseObject(uuid.Nil, nil, &JSONRPCErrorInvalidRequest), true
+}
+
+// processMessage handles a raw message sent by a client, which may be a
+// single JSON-RPC object or a batch array of them. Batch responses are
+// returned in the same order as the requests, with no entries for
+// notifications. Returns the payload to send back and whether there is
+// anything to send.
+func processMessage(
+	methodMap *MethodMap,
+	env requests.RequestEnv,
+	msg []byte,
+) (any, bool) {
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return processRequestObject(methodMap, env, msg)
+	}
+
+	if !json.Valid(trimmed) {
+		log.Error().Msg("batch payload is not valid JSON")
+		return makeResponseObject(uuid.Nil, nil, &JSONRPCErrorParseError), true
+	}
+
+	var batch []json.RawMessage
+	err := json.Unmarshal(trimmed, &batch)
+	if err != nil || len(batch) == 0 {
+		log.Error().Msg("invalid batch request")
+		return makeResponseObject(uuid.Nil, nil, &JSONRPCErrorInvalidRequest), true
+	}
+
+	log.Debug().Int("size", len(batch)).Msg("received batch request")
+
+	resps := make([]any, 0, len(batch))
+	for _, item := range batch {
+		resp, ok := processRequestObject(methodMap, env, item)
+		if ok {
+			resps = append(resps, resp)
+		}
+	}
+
+	if len(resps) == 0 {
+		return nil, false
+	}
+
+	return resps, true
 }
 
 // handleWSMessage parses all incoming WS requests, identifies what type of
 // JSON-RPC object they may be and forwards them to the appropriate function
 // to handle that type of message.
 func handleWSMessage(
-	methodMap *MethodMap,
+	versions map[string]*apiVersion,
 	platform platforms.Platform,
 	cfg *config.Instance,
 	state *state.State,
 	inTokenQueue chan<- tokens.Token,
 	db *database.Database,
+	sess *sessions.Manager,
+	limitsOverride requests.LimitsOverrideFunc,
+	wg *sync.WaitGroup,
 ) func(
 	session *melody.Session,
 	msg []byte,
@@ -348,39 +599,95 @@ func handleWSMessage(
 			return
 		}
 
-		rawIp := strings.SplitN(session.Request.RemoteAddr, ":", 2)
-		clientIp := net.ParseIP(rawIp[0])
-		env := requests.RequestEnv{
-			Platform:   platform,
-			Config:     cfg,
-			State:      state,
-			Database:   db,
-			TokenQueue: inTokenQueue,
-			IsLocal:    clientIp.IsLoopback(),
+		if sc := sessionEncryption(session); sc != nil {
+			var err error
+			msg, err = sc.read(msg)
+			if err != nil {
+				log.Warn().Err(err).Msgf("closing session, failed to decrypt message from: %s", session.Request.RemoteAddr)
+				err := session.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("closing session")
+				}
+				return
+			}
+
+			if sc.authenticate() {
+				addSession(sess, session)
+			}
 		}
 
-		id, resp, rpcError := processRequestObject(methodMap, env, msg)
-		if rpcError != nil {
-			err := sendWSError(session, id, *rpcError)
+		client := sessionClient(session)
+		if client != nil {
+			// check the client hasn't been revoked since connecting
+			stored, err := db.GetClient(client.ID)
 			if err != nil {
-				log.Error().Err(err).Msg("error sending error response")
+				log.Warn().Err(err).Msgf("closing session for revoked client: %s", client.ID)
+				err := session.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("closing session")
+				}
+				return
 			}
-		} else {
-			err := sendWSResponse(session, id, resp)
+
+			// encrypted sessions only prove the client secret once a
+			// message has been decrypted, so the address is updated here
+			address := clientIp(session.Request.RemoteAddr).String()
+			if stored.Address != address {
+				err := db.UpdateClientAddress(client.ID, address)
+				if err != nil {
+					log.Error().Err(err).Msg("error updating client address")
+				}
+			}
+		}
+
+		version := sessionVersion(versions, session)
+		isLocal := clientIp(session.Request.RemoteAddr).IsLoopback()
+
+		env := requests.RequestEnv{
+			Platform:       platform,
+			Config:         cfg,
+			State:          state,
+			Database:       db,
+			TokenQueue:     inTokenQueue,
+			IsLocal:        isLocal,
+			Address:        clientIp(session.Request.RemoteAddr).String(),
+			Client:         client,
+			Scopes:         requestScopes(isLocal, client),
+			Subscriptions:  sessionSubscriptions(session),
+			Sessions:       sess,
+			LimitsOverride: limitsOverride,
+			SessionID:      sessionID(session),
+			ApiVersion:     version.name,
+		}
+
+		// requests are handled outside the read loop so a slow method, or one
+		// waiting on a response from this same client, doesn't stop any
+		// further messages being read from the session
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, ok := processMessage(version.methods, env, msg)
+			if !ok {
+				return
+			}
+
+			err := sendWSResponse(session, resp)
 			if err != nil {
 				log.Error().Err(err).Msg("error sending response")
 			}
-		}
+		}()
 	}
 }
 
 func handlePostRequest(
-	methodMap *MethodMap,
+	version *apiVersion,
 	platform platforms.Platform,
 	cfg *config.Instance,
 	state *state.State,
 	inTokenQueue chan<- tokens.Token,
 	db *database.Database,
+	limitsOverride requests.LimitsOverrideFunc,
 ) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Type") != "application/json" {
@@ -388,6 +695,12 @@ func handlePostRequest(
 			return
 		}
 
+		client, ok := authenticateRequest(db, r)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to read request body")
@@ -395,55 +708,188 @@ func handlePostRequest(
 			return
 		}
 
-		rawIp := strings.SplitN(r.RemoteAddr, ":", 2)
-		clientIp := net.ParseIP(rawIp[0])
+		isLocal := clientIp(r.RemoteAddr).IsLoopback()
 		env := requests.RequestEnv{
-			Platform:   platform,
-			Config:     cfg,
-			State:      state,
-			Database:   db,
-			TokenQueue: inTokenQueue,
-			IsLocal:    clientIp.IsLoopback(),
+			Platform:       platform,
+			Config:         cfg,
+			State:          state,
+			Database:       db,
+			TokenQueue:     inTokenQueue,
+			IsLocal:        isLocal,
+			Address:        clientIp(r.RemoteAddr).String(),
+			Client:         client,
+			Scopes:         requestScopes(isLocal, client),
+			ApiVersion:     version.name,
+			LimitsOverride: limitsOverride,
 		}
 
-		var respBody []byte
-		id, resp, rpcError := processRequestObject(methodMap, env, body)
-		if rpcError != nil {
-			errorResp := models.ResponseErrorObject{
-				JSONRPC: "2.0",
-				ID:      id,
-				Error:   rpcError,
-			}
-			respBody, err = json.Marshal(errorResp)
-			if err != nil {
-				log.Error().Err(err).Msg("error marshalling error response")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+		resp, ok := processMessage(version.methods, env, body)
+		if !ok {
+			// nothing to reply with for notifications
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		respBody, err := json.Marshal(resp)
+		if err != nil {
+			log.Error().Err(err).Msg("error marshalling response")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(respBody)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to write response")
+		}
+	}
+}
+
+// encryptedSessionKeys looks up the client requesting an encrypted session
+// and returns the session keys for it. Encrypted sessions only send the
+// client ID, the secret is never sent over the network and possession of it
+// is proven by successfully decrypting messages.
+func encryptedSessionKeys(db *database.Database, r *http.Request) (map[string]any, bool) {
+	id, err := uuid.Parse(r.URL.Query().Get("client"))
+	if err != nil {
+		log.Warn().Err(err).Msgf("invalid client ID from: %s", r.RemoteAddr)
+		return nil, false
+	}
+
+	c, err := db.GetClient(id)
+	if err != nil {
+		log.Warn().Err(err).Msgf("unknown client ID from: %s", r.RemoteAddr)
+		return nil, false
+	}
+
+	sc, err := newSessionCrypto(c.Secret)
+	if err != nil {
+		log.Error().Err(err).Msg("error creating session encryption")
+		return nil, false
+	}
+
+	return map[string]any{
+		sessionKeyClient:     &c,
+		sessionKeyEncryption: sc,
+	}, true
+}
+
+// handleWSRequest authenticates and upgrades an incoming WebSocket request.
+// Sessions opened with a client query parameter and no secret are upgraded
+// in encrypted mode.
+func handleWSRequest(
+	db *database.Database,
+	session *melody.Melody,
+	version string,
+) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var keys map[string]any
+
+		if r.URL.Query().Has("client") && requestSecret(r) == "" {
+			var ok bool
+			keys, ok = encryptedSessionKeys(db, r)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
 		} else {
-			resp := models.ResponseObject{
-				JSONRPC: "2.0",
-				ID:      id,
-				Result:  resp,
-			}
-			respBody, err = json.Marshal(resp)
-			if err != nil {
-				log.Error().Err(err).Msg("error marshalling response")
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			client, ok := authenticateRequest(db, r)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
+
+			keys = make(map[string]any)
+			if client != nil {
+				keys[sessionKeyClient] = client
+			}
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		_, err = w.Write(respBody)
+		keys[sessionKeyID] = uuid.New()
+		keys[sessionKeySubscriptions] = notifications.NewSubscriptions()
+		keys[sessionKeyVersion] = version
+
+		err := session.HandleRequestWithKeys(w, r, keys)
 		if err != nil {
-			log.Error().Err(err).Msg("failed to write error response")
+			log.Error().Err(err).Msgf("handling websocket request: %s", version)
 		}
 	}
 }
 
-// Start starts the API web server and blocks until it shuts down.
+// addSession adds a WebSocket session to the sessions manager, so Core can
+// send requests to it.
+func addSession(sess *sessions.Manager, session *melody.Session) {
+	client := sessionClient(session)
+	isLocal := clientIp(session.Request.RemoteAddr).IsLoopback()
+
+	s := sessions.Session{
+		ID:        sessionID(session),
+		Address:   session.Request.RemoteAddr,
+		Scopes:    requestScopes(isLocal, client),
+		Connected: time.Now(),
+	}
+
+	if c := client; c != nil {
+		s.ClientID = &c.ID
+		s.ClientName = c.Name
+	}
+
+	sess.Add(s, func(data []byte) error {
+		return writeSession(session, data)
+	})
+	log.Debug().Msgf("session connected: %s", s.ID)
+}
+
+// registerSession adds a newly connected plaintext WebSocket session to the
+// sessions manager. Encrypted sessions are only added once the first
+// message from the client has been decrypted, until then nothing proves it
+// knows the client secret.
+func registerSession(sess *sessions.Manager) func(*melody.Session) {
+	return func(session *melody.Session) {
+		if sessionEncryption(session) == nil {
+			addSession(sess, session)
+			return
+		}
+
+		err := startSession(session)
+		if err != nil {
+			log.Error().Err(err).Msgf("error starting encrypted session: %s", sessionID(session))
+		}
+	}
+}
+
+// unregisterSession removes a disconnected WebSocket session from the
+// sessions manager.
+func unregisterSession(sess *sessions.Manager) func(*melody.Session) {
+	return func(session *melody.Session) {
+		id := sessionID(session)
+		sess.Remove(id)
+		log.Debug().Msgf("session disconnected: %s", id)
+	}
+}
+
+// postRoutes adds the HTTP POST API endpoint of every API version. /api
+// always uses the latest version.
+func postRoutes(
+	r chi.Router,
+	versions map[string]*apiVersion,
+	platform platforms.Platform,
+	cfg *config.Instance,
+	state *state.State,
+	inTokenQueue chan<- tokens.Token,
+	db *database.Database,
+	limitsOverride requests.LimitsOverrideFunc,
+) {
+	latest := versions[models.ApiVersionLatest]
+	r.Post("/api", handlePostRequest(latest, platform, cfg, state, inTokenQueue, db, limitsOverride))
+	for _, v := range versions {
+		r.Post("/api/"+v.name, handlePostRequest(v, platform, cfg, state, inTokenQueue, db, limitsOverride))
+	}
+}
+
+// Start starts the API web server, which runs until the service context is
+// cancelled. The returned channel is closed once the server has shut down
+// and nothing started by it can send to the token queue.
 func Start(
 	platform platforms.Platform,
 	cfg *config.Instance,
@@ -451,62 +897,192 @@ func Start(
 	inTokenQueue chan<- tokens.Token,
 	db *database.Database,
 	notifications <-chan models.Notification,
-) {
+	sess *sessions.Manager,
+	limitsOverride requests.LimitsOverrideFunc,
+	handlers ...NotificationHandler,
+) (<-chan struct{}, error) {
+	// nothing is started until the server is able to listen, so a bad
+	// certificate or address stops the service instead of leaving it
+	// running without an API
+	tlsConfig, err := apiTlsConfig(platform, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error loading tls certificate: %w", err)
+	}
+
+	listeners, err := listenAddresses(cfg.ApiListen(), cfg.ApiPort())
+	if err != nil {
+		return nil, fmt.Errorf("error starting http server: %w", err)
+	}
+
 	r := chi.NewRouter()
 
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.NoCache)
-	r.Use(middleware.Timeout(config.ApiRequestTimeout))
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*", "http://*", "capacitor://*"},
-		AllowedMethods: []string{"GET"},
-		AllowedHeaders: []string{"Accept"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type"},
 		ExposedHeaders: []string{},
 	}))
 
-	methodMap := NewMethodMap()
+	versions := newApiVersions()
+	latest := versions[models.ApiVersionLatest]
+	streams := newEventStreams()
+
+	var bridge *mqttBridge
+	if cfg.Mqtt().Enabled {
+		bridge, err = startMqtt(latest, platform, cfg, state, inTokenQueue, db, limitsOverride)
+		if err != nil {
+			log.Error().Err(err).Msg("error starting mqtt bridge")
+		} else {
+			handlers = append(handlers, bridge.notify)
+		}
+	}
+
+	go pruneAudit(cfg, state, db)
 
 	session := melody.New()
 	session.Upgrader.CheckOrigin = func(r *http.Request) bool { return true }
-	go broadcastNotifications(state, session, notifications)
+	go broadcastNotifications(state, session, versions, streams, notifications, handlers)
 
-	r.Get("/api", func(w http.ResponseWriter, r *http.Request) {
-		err := session.HandleRequest(w, r)
-		if err != nil {
-			log.Error().Err(err).Msg("handling websocket request: latest")
-		}
+	session.HandleConnect(registerSession(sess))
+	session.HandleDisconnect(unregisterSession(sess))
+	var wsRequests sync.WaitGroup
+	session.HandleMessage(handleWSMessage(versions, platform, cfg, state, inTokenQueue, db, sess, limitsOverride, &wsRequests))
+
+	// event streams are long-lived, so they're kept out of the request
+	// timeout applied to all other routes
+	r.Get("/api/events", handleEventsRequest(db, state, streams))
+
+	// run and run.script requests in wait mode block until the launch has
+	// finished, so POST requests get a longer timeout to cover them
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(config.ApiWaitRequestTimeout))
+		postRoutes(r, versions, platform, cfg, state, inTokenQueue, db, limitsOverride)
 	})
-	r.Post("/api", handlePostRequest(methodMap, platform, cfg, state, inTokenQueue, db))
 
-	r.Get("/api/v0", func(w http.ResponseWriter, r *http.Request) {
-		err := session.HandleRequest(w, r)
-		if err != nil {
-			log.Error().Err(err).Msg("handling websocket request: v0")
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(config.ApiRequestTimeout))
+
+		r.Get("/api", handleWSRequest(db, session, latest.name))
+		for _, v := range versions {
+			r.Get("/api/"+v.name, handleWSRequest(db, session, v.name))
 		}
+
+		runRest := auditRest(cfg, db, methods.HandleRunRest(cfg, state, inTokenQueue))
+		r.Get("/l/*", runRest) // DEPRECATED
+		r.Get("/r/*", runRest)
+		r.Get("/run/*", runRest)
+
+		r.Get("/app/*", handleApp)
+		r.Get("/app", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/app/", http.StatusFound)
+		})
 	})
-	r.Post("/api/v0", handlePostRequest(methodMap, platform, cfg, state, inTokenQueue, db))
 
-	r.Get("/api/v0.1", func(w http.ResponseWriter, r *http.Request) {
-		err := session.HandleRequest(w, r)
+	srv := &http.Server{
+		Handler:   r,
+		TLSConfig: tlsConfig,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for _, l := range listeners {
+			wg.Add(1)
+			go func(l net.Listener) {
+				defer wg.Done()
+				log.Info().Msgf("api listening on: %s", l.Addr())
+
+				var err error
+				if srv.TLSConfig != nil {
+					err = srv.ServeTLS(l, "", "")
+				} else {
+					err = srv.Serve(l)
+				}
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Error().Err(err).Msgf("error serving http: %s", l.Addr())
+				}
+			}(l)
+		}
+
+		<-state.GetContext().Done()
+		shutdownServer(srv, session)
+		wg.Wait()
+
+		// anything below can still be sending tokens to the input queue
+		wsRequests.Wait()
+		if bridge != nil {
+			<-bridge.done
+		}
+
+		log.Info().Msg("api server stopped")
+	}()
+
+	return done, nil
+}
+
+// apiTlsConfig returns the TLS config of the API server, or nil if TLS is
+// disabled.
+func apiTlsConfig(platform platforms.Platform, cfg *config.Instance) (*tls.Config, error) {
+	if !cfg.ApiTls() {
+		return nil, nil
+	}
+
+	cert, err := certs.Load(platform, cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msgf("tls certificate fingerprint: %s", cert.Fingerprint())
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert.Certificate},
+	}, nil
+}
+
+// listenAddresses opens a listener on the API port for each bind address.
+// With no addresses, the server listens on all interfaces. Any listeners
+// already opened are closed if one of them fails.
+func listenAddresses(addrs []string, port int) ([]net.Listener, error) {
+	if len(addrs) == 0 {
+		addrs = []string{""}
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
 		if err != nil {
-			log.Error().Err(err).Msg("handling websocket request: v0.1")
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("error listening on %s: %w", addr, err)
 		}
-	})
-	r.Post("/api/v0.1", handlePostRequest(methodMap, platform, cfg, state, inTokenQueue, db))
+		listeners = append(listeners, l)
+	}
 
-	session.HandleMessage(handleWSMessage(methodMap, platform, cfg, state, inTokenQueue, db))
+	return listeners, nil
+}
 
-	r.Get("/l/*", methods.HandleRunRest(cfg, state, inTokenQueue)) // DEPRECATED
-	r.Get("/r/*", methods.HandleRunRest(cfg, state, inTokenQueue))
-	r.Get("/run/*", methods.HandleRunRest(cfg, state, inTokenQueue))
+// shutdownServer closes all WebSocket sessions, which aren't tracked by the
+// HTTP server once upgraded, then drains remaining connections until the
+// shutdown timeout.
+func shutdownServer(srv *http.Server, session *melody.Melody) {
+	log.Info().Msg("shutting down api server")
 
-	r.Get("/app/*", handleApp)
-	r.Get("/app", func(w http.ResponseWriter, r *http.Request) {
-		http.Redirect(w, r, "/app/", http.StatusFound)
-	})
+	err := session.Close()
+	if err != nil {
+		log.Warn().Err(err).Msg("error closing websocket sessions")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ApiShutdownTimeout)
+	defer cancel()
 
-	err := http.ListenAndServe(":"+strconv.Itoa(cfg.ApiPort()), r)
+	err = srv.Shutdown(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("error starting http server")
+		log.Warn().Err(err).Msg("error shutting down http server")
+		_ = srv.Close()
 	}
 }
@@ -0,0 +1,327 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMethodMap(t *testing.T) *MethodMap {
+	var m MethodMap
+	require.NoError(t, m.AddMethod("echo", func(env requests.RequestEnv) (any, error) {
+		return string(env.Params), nil
+	}))
+	require.NoError(t, m.AddMethod("fail", func(env requests.RequestEnv) (any, error) {
+		return nil, errors.New("failed")
+	}))
+	return &m
+}
+
+var testEnv = requests.RequestEnv{
+	Scopes: models.AllScopes,
+}
+
+func TestProcessMessageBatch(t *testing.T) {
+	methodMap := testMethodMap(t)
+	id1, id2 := uuid.New(), uuid.New()
+
+	batch := `[
+		{"jsonrpc":"2.0","id":"` + id1.String() + `","method":"echo","params":"a"},
+		{"jsonrpc":"2.0","method":"echo","params":"notification"},
+		{"jsonrpc":"2.0","id":"` + id2.String() + `","method":"fail"},
+		{"foo":"bar"}
+	]`
+
+	resp, ok := processMessage(methodMap, testEnv, []byte(batch))
+	require.True(t, ok)
+
+	resps, ok := resp.([]any)
+	require.True(t, ok)
+	require.Len(t, resps, 3)
+
+	first, ok := resps[0].(models.ResponseObject)
+	require.True(t, ok)
+	assert.Equal(t, id1, first.ID)
+	assert.Equal(t, `"a"`, first.Result)
+
+	second, ok := resps[1].(models.ResponseErrorObject)
+	require.True(t, ok)
+	assert.Equal(t, id2, second.ID)
+
+	third, ok := resps[2].(models.ResponseErrorObject)
+	require.True(t, ok)
+	assert.Equal(t, JSONRPCErrorInvalidRequest.Code, third.Error.Code)
+}
+
+func TestProcessMessageBatchInvalid(t *testing.T) {
+	methodMap := testMethodMap(t)
+
+	tests := map[string]struct {
+		msg  string
+		code int
+	}{
+		"empty_batch":   {msg: `[]`, code: JSONRPCErrorInvalidRequest.Code},
+		"invalid_json":  {msg: `[{"jsonrpc":"2.0"`, code: JSONRPCErrorParseError.Code},
+		"invalid_items": {msg: `[1]`, code: JSONRPCErrorInvalidRequest.Code},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, ok := processMessage(methodMap, testEnv, []byte(tc.msg))
+			require.True(t, ok)
+
+			if errResp, ok := resp.(models.ResponseErrorObject); ok {
+				assert.Equal(t, tc.code, errResp.Error.Code)
+				return
+			}
+
+			resps, ok := resp.([]any)
+			require.True(t, ok)
+			require.Len(t, resps, 1)
+			errResp, ok := resps[0].(models.ResponseErrorObject)
+			require.True(t, ok)
+			assert.Equal(t, tc.code, errResp.Error.Code)
+		})
+	}
+}
+
+func TestProcessMessageBatchNotificationsOnly(t *testing.T) {
+	methodMap := testMethodMap(t)
+
+	batch := `[
+		{"jsonrpc":"2.0","method":"echo"},
+		{"jsonrpc":"2.0","method":"echo","params":"b"}
+	]`
+
+	_, ok := processMessage(methodMap, testEnv, []byte(batch))
+	assert.False(t, ok)
+}
+
+func TestMakeJSONRPCError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		code int
+	}{
+		"untyped":        {err: errors.New("failed"), code: models.ErrorCodeGeneric},
+		"missing_params": {err: methods.ErrMissingParams, code: models.ErrorCodeInvalidParams},
+		"not_allowed":    {err: methods.ErrNotAllowed, code: models.ErrorCodeNotAllowed},
+		"wrapped": {
+			err:  fmt.Errorf("writing: %w", methods.ErrReaderBusy),
+			code: models.ErrorCodeReaderBusy,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.code, makeJSONRPCError(tc.err).Code)
+		})
+	}
+}
+
+func TestMakeJSONRPCErrorData(t *testing.T) {
+	data := map[string]string{"cmd": "foo"}
+	err := methods.WrapError(methods.ErrInvalidZapScript, errors.New("unsupported cmd: foo"), data)
+
+	rpcErr := makeJSONRPCError(err)
+	assert.Equal(t, models.ErrorCodeInvalidZapScript, rpcErr.Code)
+	assert.Equal(t, "invalid ZapScript: unsupported cmd: foo", rpcErr.Message)
+	assert.Equal(t, data, rpcErr.Data)
+	assert.ErrorIs(t, err, methods.ErrInvalidZapScript)
+}
+
+func TestListenAddresses(t *testing.T) {
+	ls, err := listenAddresses([]string{"127.0.0.1"}, 0)
+	require.NoError(t, err)
+	require.Len(t, ls, 1)
+	defer ls[0].Close()
+
+	addr, ok := ls[0].Addr().(*net.TCPAddr)
+	require.True(t, ok)
+	assert.True(t, addr.IP.IsLoopback())
+
+	_, err = listenAddresses([]string{"127.0.0.1", "not an address"}, 0)
+	assert.Error(t, err)
+}
+
+func TestMethodSpecs(t *testing.T) {
+	methodMap := NewMethodMap()
+	for _, name := range methodMap.ListMethods() {
+		_, ok := methodSpecs[name]
+		assert.True(t, ok, "missing method spec: %s", name)
+	}
+
+	resp, err := handleMethods(methodMap)(requests.RequestEnv{})
+	require.NoError(t, err)
+
+	ms, ok := resp.(models.MethodsResponse)
+	require.True(t, ok)
+	for _, m := range ms.Methods {
+		if m.Name == models.MethodLaunch || m.Name == models.MethodMediaIndex {
+			assert.True(t, m.Deprecated, m.Name)
+		}
+	}
+}
+
+type testPlatform struct {
+	platforms.Platform
+	dataDir string
+}
+
+func (p testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func (p testPlatform) Id() string {
+	return "test"
+}
+
+func testDatabase(t *testing.T) *database.Database {
+	db, err := database.Open(testPlatform{dataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db
+}
+
+func TestAuthenticateRequest(t *testing.T) {
+	db := testDatabase(t)
+
+	c, err := db.AddClient("phone", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		query      string
+		wantOk     bool
+		wantClient bool
+	}{
+		{
+			name:       "local_no_secret",
+			remoteAddr: "127.0.0.1:50000",
+			wantOk:     true,
+		},
+		{
+			name:       "remote_no_secret",
+			remoteAddr: "192.168.1.20:50000",
+		},
+		{
+			name:       "remote_bearer",
+			remoteAddr: "192.168.1.20:50000",
+			header:     "Bearer " + c.Secret,
+			wantOk:     true,
+			wantClient: true,
+		},
+		{
+			name:       "remote_query",
+			remoteAddr: "192.168.1.21:50000",
+			query:      "?secret=" + c.Secret,
+			wantOk:     true,
+			wantClient: true,
+		},
+		{
+			name:       "remote_wrong_secret",
+			remoteAddr: "192.168.1.20:50000",
+			header:     "Bearer wrong",
+		},
+		{
+			name:       "local_wrong_secret",
+			remoteAddr: "127.0.0.1:50000",
+			header:     "Bearer wrong",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api"+tt.query, nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+
+			client, ok := authenticateRequest(db, r)
+			assert.Equal(t, tt.wantOk, ok)
+			if !tt.wantClient {
+				assert.Nil(t, client)
+				return
+			}
+
+			require.NotNil(t, client)
+			assert.Equal(t, c.ID, client.ID)
+
+			stored, err := db.GetClient(c.ID)
+			require.NoError(t, err)
+			assert.Equal(t, clientIp(tt.remoteAddr).String(), stored.Address)
+		})
+	}
+}
+
+func TestStartShutdown(t *testing.T) {
+	defaults := config.BaseDefaults
+	defaults.Service.ApiPort = 0
+	defaults.Service.ApiListen = []string{"127.0.0.1"}
+	cfg, err := config.NewConfig(t.TempDir(), defaults)
+	require.NoError(t, err)
+
+	st, ns := state.NewState(nil)
+	itq := make(chan tokens.Token)
+
+	done, err := Start(testPlatform{dataDir: t.TempDir()}, cfg, st, itq, testDatabase(t), ns, sessions.NewManager(), nil)
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+		require.Fail(t, "api stopped before service")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	st.StopService()
+
+	select {
+	case <-done:
+	case <-time.After(config.ApiShutdownTimeout + time.Second):
+		require.Fail(t, "api didn't stop")
+	}
+
+	// nothing can send to the token queue once the api has stopped
+	close(itq)
+}
+
+func TestStartTlsError(t *testing.T) {
+	dir := t.TempDir()
+	defaults := config.BaseDefaults
+	defaults.Service.ApiPort = 0
+	defaults.Service.ApiListen = []string{"127.0.0.1"}
+	defaults.Service.Tls = true
+	defaults.Service.TlsCert = filepath.Join(dir, "missing.crt")
+	defaults.Service.TlsKey = filepath.Join(dir, "missing.key")
+	cfg, err := config.NewConfig(dir, defaults)
+	require.NoError(t, err)
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+
+	done, err := Start(testPlatform{dataDir: t.TempDir()}, cfg, st, make(chan tokens.Token), testDatabase(t), ns, sessions.NewManager(), nil)
+	assert.ErrorContains(t, err, "error loading tls certificate")
+	assert.Nil(t, done)
+}
@@ -0,0 +1,221 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionClosed   = errors.New("session closed before response")
+)
+
+// Session is a connected API client which Core can send requests to.
+type Session struct {
+	ID         uuid.UUID
+	ClientID   *uuid.UUID
+	ClientName string
+	Address    string
+	Scopes     []string
+	Connected  time.Time
+	send       func([]byte) error
+}
+
+// ClientError is an error object returned by a client in response to a
+// request sent by Core.
+type ClientError struct {
+	Code    int
+	Message string
+}
+
+func (e *ClientError) Error() string {
+	return fmt.Sprintf("client error %d: %s", e.Code, e.Message)
+}
+
+type pendingRequest struct {
+	sessionID uuid.UUID
+	resp      chan models.ResponseObject
+}
+
+// Manager tracks all connected WebSocket sessions and any requests Core
+// has sent to them which are waiting for a response.
+type Manager struct {
+	mu       sync.RWMutex
+	sessions map[uuid.UUID]Session
+	pending  map[uuid.UUID]pendingRequest
+}
+
+func NewManager() *Manager {
+	return &Manager{
+		sessions: make(map[uuid.UUID]Session),
+		pending:  make(map[uuid.UUID]pendingRequest),
+	}
+}
+
+// Add registers a new session. The send function must write a raw payload
+// to the session, handling any transport encryption.
+func (m *Manager) Add(s Session, send func([]byte) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.send = send
+	m.sessions[s.ID] = s
+}
+
+// Remove unregisters a session and fails any requests still waiting on a
+// response from it.
+func (m *Manager) Remove(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, id)
+
+	for reqID, p := range m.pending {
+		if p.sessionID == id {
+			close(p.resp)
+			delete(m.pending, reqID)
+		}
+	}
+}
+
+// List returns all currently connected sessions.
+func (m *Manager) List() []Session {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ss := make([]Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		ss = append(ss, s)
+	}
+
+	return ss
+}
+
+// Latest returns the most recently connected session of a registered
+// client which was granted the given scope.
+func (m *Manager) Latest(scope string) (Session, bool) {
+	var latest Session
+	found := false
+	for _, s := range m.List() {
+		if s.ClientID == nil || !slices.Contains(s.Scopes, scope) {
+			continue
+		}
+		if !found || s.Connected.After(latest.Connected) {
+			latest = s
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// Request sends a JSON-RPC request to a session and blocks until the client
+// responds, the context is cancelled or the request times out. If the
+// context has no deadline, the default API request timeout is used.
+func (m *Manager) Request(
+	ctx context.Context,
+	sessionID uuid.UUID,
+	method string,
+	params any,
+) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.ApiRequestTimeout)
+		defer cancel()
+	}
+
+	id := uuid.New()
+	req := models.RequestObject{
+		JSONRPC: "2.0",
+		ID:      &id,
+		Method:  method,
+	}
+
+	if params != nil {
+		ps, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling params: %w", err)
+		}
+		req.Params = ps
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %w", err)
+	}
+
+	respCh := make(chan models.ResponseObject, 1)
+
+	m.mu.Lock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrSessionNotFound
+	}
+	m.pending[id] = pendingRequest{
+		sessionID: sessionID,
+		resp:      respCh,
+	}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, id)
+		m.mu.Unlock()
+	}()
+
+	log.Debug().Str("method", method).Msgf("sending request to session: %s", sessionID)
+	err = s.send(data)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+
+	select {
+	case resp, ok := <-respCh:
+		if !ok {
+			return nil, ErrSessionClosed
+		}
+
+		if resp.Error != nil {
+			return nil, &ClientError{
+				Code:    resp.Error.Code,
+				Message: resp.Error.Message,
+			}
+		}
+
+		result, err := json.Marshal(resp.Result)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling result: %w", err)
+		}
+
+		return result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Resolve delivers a response sent by a session to the pending request with
+// the same ID. Returns false if no request is waiting on it, or if the
+// response came from a different session than the request was sent to.
+func (m *Manager) Resolve(sessionID uuid.UUID, resp models.ResponseObject) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pending[resp.ID]
+	if !ok || p.sessionID != sessionID {
+		return false
+	}
+
+	delete(m.pending, resp.ID)
+	p.resp <- resp
+
+	return true
+}
@@ -0,0 +1,182 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addResponder registers a session which replies to every request it's
+// sent with the response returned by reply. A nil response isn't sent.
+func addResponder(
+	m *Manager,
+	s Session,
+	reply func(models.RequestObject) *models.ResponseObject,
+) {
+	m.Add(s, func(data []byte) error {
+		var req models.RequestObject
+		err := json.Unmarshal(data, &req)
+		if err != nil {
+			return err
+		}
+
+		resp := reply(req)
+		if resp != nil {
+			go m.Resolve(s.ID, *resp)
+		}
+
+		return nil
+	})
+}
+
+func TestRequest(t *testing.T) {
+	m := NewManager()
+	s := Session{ID: uuid.New()}
+
+	addResponder(m, s, func(req models.RequestObject) *models.ResponseObject {
+		assert.Equal(t, models.ClientMethodConfirm, req.Method)
+		return &models.ResponseObject{
+			JSONRPC: "2.0",
+			ID:      *req.ID,
+			Result:  models.ConfirmResponse{Confirmed: true},
+		}
+	})
+
+	result, err := m.Request(context.Background(), s.ID, models.ClientMethodConfirm, models.ConfirmParams{
+		Cmd: "execute",
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"confirmed":true}`, string(result))
+	assert.Empty(t, m.pending)
+}
+
+func TestRequestClientError(t *testing.T) {
+	m := NewManager()
+	s := Session{ID: uuid.New()}
+
+	addResponder(m, s, func(req models.RequestObject) *models.ResponseObject {
+		return &models.ResponseObject{
+			JSONRPC: "2.0",
+			ID:      *req.ID,
+			Error: &models.ErrorObject{
+				Code:    1,
+				Message: "denied",
+			},
+		}
+	})
+
+	_, err := m.Request(context.Background(), s.ID, models.ClientMethodConfirm, nil)
+	var clientErr *ClientError
+	require.ErrorAs(t, err, &clientErr)
+	assert.Equal(t, "denied", clientErr.Message)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	m := NewManager()
+	s := Session{ID: uuid.New()}
+	addResponder(m, s, func(models.RequestObject) *models.ResponseObject {
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := m.Request(ctx, s.ID, models.ClientMethodConfirm, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, m.pending)
+}
+
+func TestRequestSessionClosed(t *testing.T) {
+	m := NewManager()
+	s := Session{ID: uuid.New()}
+	addResponder(m, s, func(models.RequestObject) *models.ResponseObject {
+		go m.Remove(s.ID)
+		return nil
+	})
+
+	_, err := m.Request(context.Background(), s.ID, models.ClientMethodConfirm, nil)
+	assert.ErrorIs(t, err, ErrSessionClosed)
+}
+
+func TestRequestSessionNotFound(t *testing.T) {
+	m := NewManager()
+	_, err := m.Request(context.Background(), uuid.New(), models.ClientMethodConfirm, nil)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestResolveOtherSession(t *testing.T) {
+	m := NewManager()
+	s := Session{ID: uuid.New()}
+	other := uuid.New()
+
+	resolved := make(chan bool, 1)
+	addResponder(m, s, func(req models.RequestObject) *models.ResponseObject {
+		// a different session can't answer a request it wasn't sent
+		resolved <- m.Resolve(other, models.ResponseObject{
+			JSONRPC: "2.0",
+			ID:      *req.ID,
+			Result:  models.ConfirmResponse{Confirmed: true},
+		})
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := m.Request(ctx, s.ID, models.ClientMethodConfirm, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, <-resolved)
+	assert.False(t, m.Resolve(s.ID, models.ResponseObject{ID: uuid.New()}))
+}
+
+func TestLatest(t *testing.T) {
+	m := NewManager()
+	now := time.Now()
+	clientA, clientB := uuid.New(), uuid.New()
+
+	anonymous := Session{
+		ID:        uuid.New(),
+		Scopes:    models.AllScopes,
+		Connected: now.Add(time.Minute),
+	}
+	older := Session{
+		ID:        uuid.New(),
+		ClientID:  &clientA,
+		Scopes:    []string{models.ScopeUnsafe},
+		Connected: now,
+	}
+	newer := Session{
+		ID:        uuid.New(),
+		ClientID:  &clientB,
+		Scopes:    []string{models.ScopeUnsafe},
+		Connected: now.Add(time.Second),
+	}
+	noScope := Session{
+		ID:        uuid.New(),
+		ClientID:  &clientB,
+		Scopes:    []string{models.ScopeRead},
+		Connected: now.Add(time.Hour),
+	}
+
+	_, ok := m.Latest(models.ScopeUnsafe)
+	assert.False(t, ok)
+
+	for _, s := range []Session{anonymous, older, newer, noScope} {
+		m.Add(s, func([]byte) error { return nil })
+	}
+
+	s, ok := m.Latest(models.ScopeUnsafe)
+	require.True(t, ok)
+	assert.Equal(t, newer.ID, s.ID)
+
+	m.Remove(newer.ID)
+	s, ok = m.Latest(models.ScopeUnsafe)
+	require.True(t, ok)
+	assert.Equal(t, older.ID, s.ID)
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/olahol/melody"
+	"github.com/rs/zerolog/log"
+)
+
+const sessionKeyVersion = "version"
+
+// apiVersion is a single version of the API, with its own method table and
+// notification format. Breaking changes to a method or notification go in
+// a new version, so clients connected to an older version keep working.
+type apiVersion struct {
+	name         string
+	methods      *MethodMap
+	notification func(models.Notification) models.RequestObject
+}
+
+// methodOverrides are the changes each API version makes to the default
+// method table. A nil handler removes the method from that version.
+var methodOverrides = map[string]map[string]func(requests.RequestEnv) (any, error){
+	models.ApiVersion0: {
+		models.MethodLaunch:    runV0(methods.HandleRun),
+		models.MethodRun:       runV0(methods.HandleRun),
+		models.MethodRunScript: runV0(methods.HandleRunScript),
+	},
+	models.ApiVersion0_1: {},
+}
+
+// notificationFormats are the notification formats of API versions which
+// differ from the default JSON-RPC notification.
+var notificationFormats = map[string]func(models.Notification) models.RequestObject{
+	models.ApiVersion0: notificationV0,
+}
+
+// runV0 wraps a run method with the v0 response, which is always null
+// unless the launch was waited on.
+func runV0(fn func(requests.RequestEnv) (any, error)) func(requests.RequestEnv) (any, error) {
+	return func(env requests.RequestEnv) (any, error) {
+		resp, err := fn(env)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := resp.(models.RunResponse); ok {
+			return nil, nil
+		}
+		return resp, nil
+	}
+}
+
+// tokenResponseV0 is the v0 shape of a scanned token, before the reader was
+// added.
+type tokenResponseV0 struct {
+	Type     string    `json:"type"`
+	UID      string    `json:"uid"`
+	Text     string    `json:"text"`
+	Data     string    `json:"data"`
+	ScanTime time.Time `json:"scanTime"`
+}
+
+// notificationV0 formats notifications with the v0 params of any which have
+// since changed.
+func notificationV0(notif models.Notification) models.RequestObject {
+	req := defaultNotification(notif)
+
+	if notif.Method == models.NotificationTokensAdded {
+		var token tokenResponseV0
+		err := json.Unmarshal(notif.Params, &token)
+		if err != nil {
+			log.Error().Err(err).Msgf("error converting v0 notification: %s", notif.Method)
+			return req
+		}
+
+		params, err := json.Marshal(token)
+		if err != nil {
+			log.Error().Err(err).Msgf("error converting v0 notification: %s", notif.Method)
+			return req
+		}
+		req.Params = params
+	}
+
+	return req
+}
+
+func defaultNotification(notif models.Notification) models.RequestObject {
+	return models.RequestObject{
+		JSONRPC: "2.0",
+		Method:  notif.Method,
+		Params:  notif.Params,
+	}
+}
+
+// newVersionMethodMap returns the method table for an API version.
+func newVersionMethodMap(version string) *MethodMap {
+	m := NewMethodMap()
+
+	for name, fn := range methodOverrides[version] {
+		if fn == nil {
+			m.Delete(name)
+			continue
+		}
+		m.Store(name, fn)
+	}
+
+	return m
+}
+
+// newApiVersions builds the method tables for all supported API versions.
+func newApiVersions() map[string]*apiVersion {
+	vs := make(map[string]*apiVersion, len(models.ApiVersions))
+
+	for _, name := range models.ApiVersions {
+		format, ok := notificationFormats[name]
+		if !ok {
+			format = defaultNotification
+		}
+
+		vs[name] = &apiVersion{
+			name:         name,
+			methods:      newVersionMethodMap(name),
+			notification: format,
+		}
+	}
+
+	return vs
+}
+
+// marshal returns the JSON encoding of a notification in this version's
+// format.
+func (v *apiVersion) marshal(notif models.Notification) ([]byte, error) {
+	return json.Marshal(v.notification(notif))
+}
+
+// sessionVersion returns the API version a WebSocket session connected
+// with, defaulting to the latest version.
+func sessionVersion(versions map[string]*apiVersion, session *melody.Session) *apiVersion {
+	if v, ok := session.Get(sessionKeyVersion); ok {
+		if name, ok := v.(string); ok {
+			if av, ok := versions[name]; ok {
+				return av
+			}
+		}
+	}
+
+	log.Warn().Msg("session has no api version, using latest")
+	return versions[models.ApiVersionLatest]
+}
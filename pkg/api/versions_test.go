@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiVersions(t *testing.T) {
+	versions := newApiVersions()
+	require.Len(t, versions, len(models.ApiVersions))
+	require.Contains(t, versions, models.ApiVersionLatest)
+
+	for _, name := range models.ApiVersions {
+		v := versions[name]
+		assert.Equal(t, name, v.name)
+
+		_, ok := v.methods.GetMethod(models.MethodVersion)
+		assert.True(t, ok, name)
+	}
+}
+
+func TestVersionMethodOverrides(t *testing.T) {
+	const version = "test"
+	methodOverrides[version] = map[string]func(requests.RequestEnv) (any, error){
+		models.MethodStop: nil,
+		models.MethodTokens: func(requests.RequestEnv) (any, error) {
+			return "overridden", nil
+		},
+	}
+	defer delete(methodOverrides, version)
+
+	m := newVersionMethodMap(version)
+
+	_, ok := m.GetMethod(models.MethodStop)
+	assert.False(t, ok)
+
+	fn, ok := m.GetMethod(models.MethodTokens)
+	require.True(t, ok)
+	res, err := fn(requests.RequestEnv{})
+	require.NoError(t, err)
+	assert.Equal(t, "overridden", res)
+}
+
+func TestVersionNegotiation(t *testing.T) {
+	cfg, err := config.NewConfig(t.TempDir(), config.BaseDefaults)
+	require.NoError(t, err)
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+
+	itq := make(chan tokens.Token)
+	go func() {
+		for range itq {
+		}
+	}()
+	t.Cleanup(func() {
+		close(itq)
+	})
+
+	r := chi.NewRouter()
+	postRoutes(r, newApiVersions(), testPlatform{}, cfg, st, itq, testDatabase(t), nil)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	post := func(path string, method string, params string) models.ResponseObject {
+		id := uuid.New()
+		body := `{"jsonrpc":"2.0","id":"` + id.String() + `","method":"` + method +
+			`","params":` + params + `}`
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var ro models.ResponseObject
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&ro))
+		require.Equal(t, id, ro.ID)
+		return ro
+	}
+
+	tests := []struct {
+		path    string
+		version string
+		runId   bool
+	}{
+		{"/api", models.ApiVersionLatest, true},
+		{"/api/" + models.ApiVersion0_1, models.ApiVersion0_1, true},
+		{"/api/" + models.ApiVersion0, models.ApiVersion0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			version, ok := post(tt.path, models.MethodVersion, "{}").Result.(map[string]any)
+			require.True(t, ok)
+			assert.Equal(t, tt.version, version["apiVersion"])
+
+			res := post(tt.path, models.MethodRun, `"**launch.system:snes"`).Result
+			if tt.runId {
+				run, ok := res.(map[string]any)
+				require.True(t, ok)
+				assert.NotEmpty(t, run["runId"])
+			} else {
+				assert.Nil(t, res)
+			}
+		})
+	}
+}
+
+func TestVersionNotifications(t *testing.T) {
+	versions := newApiVersions()
+
+	params, err := json.Marshal(models.TokenResponse{
+		UID:    "04aabbcc",
+		Reader: "pn532:/dev/ttyUSB0",
+	})
+	require.NoError(t, err)
+	notif := models.Notification{
+		Method: models.NotificationTokensAdded,
+		Params: params,
+	}
+
+	latest, err := versions[models.ApiVersionLatest].marshal(notif)
+	require.NoError(t, err)
+	assert.Contains(t, string(latest), `"reader"`)
+
+	v0, err := versions[models.ApiVersion0].marshal(notif)
+	require.NoError(t, err)
+	assert.NotContains(t, string(v0), `"reader"`)
+	assert.Contains(t, string(v0), `"uid":"04aabbcc"`)
+	assert.Contains(t, string(v0), `"method":"tokens.added"`)
+
+	// other notifications are unchanged
+	notif = models.Notification{
+		Method: models.NotificationMediaIndexing,
+		Params: json.RawMessage(`{"exists":true}`),
+	}
+	latest, err = versions[models.ApiVersionLatest].marshal(notif)
+	require.NoError(t, err)
+	v0, err = versions[models.ApiVersion0].marshal(notif)
+	require.NoError(t, err)
+	assert.Equal(t, latest, v0)
+}
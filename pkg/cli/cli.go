@@ -9,6 +9,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/client"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
@@ -17,6 +18,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/google/uuid"
+	"github.com/mdp/qrterminal/v3"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -29,8 +31,14 @@ type Flags struct {
 	Api          *string
 	Clients      *bool
 	NewClient    *string
+	ClientScopes *string
 	DeleteClient *string
 	Qr           *bool
+	Fingerprint  *bool
+	Audit        *int
+	Resolve      *string
+	ResolveUID   *string
+	ResolveData  *string
 	Version      *bool
 	Config       *bool
 	ShowLoader   *string
@@ -66,26 +74,56 @@ func SetupFlags() *Flags {
 			"",
 			"send method and params to API and print response",
 		),
-		//Clients: flag.Bool(
-		//	"clients",
-		//	false,
-		//	"list all registered API clients and secrets",
-		//),
-		//NewClient: flag.String(
-		//	"new-client",
-		//	"",
-		//	"register new API client with given display name",
-		//),
-		//DeleteClient: flag.String(
-		//	"delete-client",
-		//	"",
-		//	"revoke access to API for given client ID",
-		//),
-		//Qr: flag.Bool(
-		//	"qr",
-		//	false,
-		//	"output a connection QR code along with client details",
-		//),
+		Clients: flag.Bool(
+			"clients",
+			false,
+			"list all registered API clients",
+		),
+		NewClient: flag.String(
+			"new-client",
+			"",
+			"register new API client with given display name",
+		),
+		ClientScopes: flag.String(
+			"client-scopes",
+			"",
+			"comma separated permission scopes for new-client (read,launch,write,admin,unsafe)",
+		),
+		DeleteClient: flag.String(
+			"delete-client",
+			"",
+			"revoke access to API for given client ID",
+		),
+		Qr: flag.Bool(
+			"qr",
+			false,
+			"output a connection QR code along with client details",
+		),
+		Fingerprint: flag.Bool(
+			"fingerprint",
+			false,
+			"print the API TLS certificate fingerprint",
+		),
+		Audit: flag.Int(
+			"audit",
+			0,
+			"print the given number of most recent API audit log entries",
+		),
+		Resolve: flag.String(
+			"resolve",
+			"",
+			"print what the given ZapScript or token text would launch without running it",
+		),
+		ResolveUID: flag.String(
+			"resolve-uid",
+			"",
+			"print what a token with the given UID would launch without running it",
+		),
+		ResolveData: flag.String(
+			"resolve-data",
+			"",
+			"print what a token with the given hex data would launch without running it",
+		),
 		Version: flag.Bool(
 			"version",
 			false,
@@ -121,6 +159,32 @@ type ConnQr struct {
 	Address string    `json:"addr"`
 }
 
+// printConnQr prints a QR code an app can scan to connect as the client.
+func printConnQr(id uuid.UUID, secret string) {
+	ip, err := utils.GetLocalIp()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error getting local IP: %v\n", err)
+		os.Exit(1)
+	}
+
+	cq := ConnQr{
+		Id:      id,
+		Secret:  secret,
+		Address: ip.String(),
+	}
+	respQr, err := json.Marshal(cq)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error encoding QR code: %v\n", err)
+		os.Exit(1)
+	}
+
+	qrterminal.Generate(
+		string(respQr),
+		qrterminal.L,
+		os.Stdout,
+	)
+}
+
 // Post actions all remaining common flags that require the environment to be
 // set up. Logging is allowed.
 func (f *Flags) Post(cfg *config.Instance, pl platforms.Platform) {
@@ -238,140 +302,273 @@ func (f *Flags) Post(cfg *config.Instance, pl platforms.Platform) {
 		}
 	}
 
+	if *f.Fingerprint {
+		resp, err := client.LocalClient(cfg, models.MethodCertificate, "")
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		var cr models.CertificateResponse
+		err = json.Unmarshal([]byte(resp), &cr)
+		if err != nil {
+			log.Error().Err(err).Msg("error decoding API response")
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+			os.Exit(1)
+		}
+
+		if !cr.Tls {
+			_, _ = fmt.Fprintln(os.Stderr, "TLS is not enabled for the API")
+			os.Exit(1)
+		}
+
+		fmt.Println(cr.Fingerprint)
+		os.Exit(0)
+	}
+
+	if *f.Audit > 0 {
+		data, err := json.Marshal(&models.AuditParams{
+			Limit: f.Audit,
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
+			os.Exit(1)
+		}
+
+		resp, err := client.LocalClient(cfg, models.MethodAudit, string(data))
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		var ar models.AuditResponse
+		err = json.Unmarshal([]byte(resp), &ar)
+		if err != nil {
+			log.Error().Err(err).Msg("error decoding API response")
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+			os.Exit(1)
+		}
+
+		for _, e := range ar.Entries {
+			who := e.Address
+			if e.ClientID != nil {
+				who = fmt.Sprintf("%s (%s, %s)", e.ClientName, e.ClientID, e.Address)
+			} else if e.Local {
+				who = "local"
+			}
+
+			result := "ok"
+			if !e.Ok {
+				result = fmt.Sprintf("error %d", e.ErrorCode)
+			}
+
+			fmt.Printf(
+				"%s  %-24s  %-10s  %s\n",
+				e.Time.Local().Format(time.DateTime),
+				e.Method,
+				result,
+				who,
+			)
+		}
+
+		os.Exit(0)
+	}
+
+	if *f.Resolve != "" || *f.ResolveUID != "" || *f.ResolveData != "" {
+		var params models.RunResolveParams
+		if *f.Resolve != "" {
+			params.Text = f.Resolve
+		}
+		if *f.ResolveUID != "" {
+			params.UID = f.ResolveUID
+		}
+		if *f.ResolveData != "" {
+			params.Data = f.ResolveData
+		}
+
+		data, err := json.Marshal(&params)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
+			os.Exit(1)
+		}
+
+		resp, err := client.LocalClient(cfg, models.MethodRunResolve, string(data))
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		var rr models.RunResolveResponse
+		err = json.Unmarshal([]byte(resp), &rr)
+		if err != nil {
+			log.Error().Err(err).Msg("error decoding API response")
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+			os.Exit(1)
+		}
+
+		if rr.Mapping != nil {
+			fmt.Printf("Mapping:   %s", rr.Mapping.Source)
+			if rr.Mapping.Type != "" {
+				fmt.Printf(" (%s %s: %s)", rr.Mapping.Match, rr.Mapping.Type, rr.Mapping.Pattern)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("ZapScript: %s\n", rr.ZapScript)
+
+		for i, c := range rr.Commands {
+			fmt.Printf("--- Command %d/%d\n", i+1, len(rr.Commands))
+			if c.ZapLink {
+				fmt.Printf("- Zap link: %s\n", c.Text)
+			}
+			if c.Download != "" {
+				fmt.Printf("- Download: %s\n", c.Download)
+			}
+			fmt.Printf("- Command:  %s\n", c.Cmd)
+			fmt.Printf("- Args:     %s\n", c.Args)
+			for k, v := range c.NamedArgs {
+				fmt.Printf("- Arg:      %s=%s\n", k, v)
+			}
+			if c.Path != "" {
+				fmt.Printf("- Path:     %s\n", c.Path)
+			}
+			if c.Launcher != "" {
+				fmt.Printf("- Launcher: %s\n", c.Launcher)
+			}
+			if c.Error != "" {
+				fmt.Printf("- Error:    %s\n", c.Error)
+			}
+		}
+
+		os.Exit(0)
+	}
+
 	// clients
-	//if *f.Clients {
-	//	resp, err := client.LocalClient(cfg, models.MethodClients, "")
-	//	if err != nil {
-	//		log.Error().Err(err).Msg("error calling API")
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
-	//		os.Exit(1)
-	//	}
-	//
-	//	var clients []models.ClientResponse
-	//	err = json.Unmarshal([]byte(resp), &clients)
-	//	if err != nil {
-	//		log.Error().Err(err).Msg("error decoding API response")
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
-	//	}
-	//
-	//	for _, c := range clients {
-	//		fmt.Println("---")
-	//		if c.Name != "" {
-	//			fmt.Printf("- Name:   %s\n", c.Name)
-	//		}
-	//		if c.Address != "" {
-	//			fmt.Printf("- Address: %s\n", c.Address)
-	//		}
-	//		fmt.Printf("- ID:     %s\n", c.ID)
-	//		fmt.Printf("- Secret: %s\n", c.Secret)
-	//
-	//		if *f.Qr {
-	//			ip, err := utils.GetLocalIp()
-	//			if err != nil {
-	//				_, _ = fmt.Fprintf(os.Stderr, "Error getting local IP: %v\n", err)
-	//				os.Exit(1)
-	//			}
-	//
-	//			cq := ConnQr{
-	//				ID:      c.ID,
-	//				Secret:  c.Secret,
-	//				Address: ip.String(),
-	//			}
-	//			respQr, err := json.Marshal(cq)
-	//			if err != nil {
-	//				_, _ = fmt.Fprintf(os.Stderr, "Error encoding QR code: %v\n", err)
-	//				os.Exit(1)
-	//			}
-	//
-	//			qrterminal.Generate(
-	//				string(respQr),
-	//				qrterminal.L,
-	//				os.Stdout,
-	//			)
-	//		}
-	//	}
-	//
-	//	os.Exit(0)
-	//} else if *f.NewClient != "" {
-	//	data, err := json.Marshal(&models.NewClientParams{
-	//		Name: *f.NewClient,
-	//	})
-	//	if err != nil {
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
-	//		os.Exit(1)
-	//	}
-	//
-	//	resp, err := client.LocalClient(
-	//		cfg,
-	//		models.MethodClientsNew,
-	//		string(data),
-	//	)
-	//	if err != nil {
-	//		log.Error().Err(err).Msg("error calling API")
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
-	//		os.Exit(1)
-	//	}
-	//
-	//	var c models.ClientResponse
-	//	err = json.Unmarshal([]byte(resp), &c)
-	//	if err != nil {
-	//		log.Error().Err(err).Msg("error decoding API response")
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
-	//	}
-	//
-	//	fmt.Println("New client registered:")
-	//	fmt.Printf("- ID:     %s\n", c.ID)
-	//	fmt.Printf("- Name:   %s\n", c.Name)
-	//	fmt.Printf("- Secret: %s\n", c.Secret)
-	//
-	//	if *f.Qr {
-	//		ip, err := utils.GetLocalIp()
-	//		if err != nil {
-	//			_, _ = fmt.Fprintf(os.Stderr, "Error getting local IP: %v\n", err)
-	//			os.Exit(1)
-	//		}
-	//
-	//		cq := ConnQr{
-	//			ID:      c.ID,
-	//			Secret:  c.Secret,
-	//			Address: ip.String(),
-	//		}
-	//		respQr, err := json.Marshal(cq)
-	//		if err != nil {
-	//			_, _ = fmt.Fprintf(os.Stderr, "Error encoding QR code: %v\n", err)
-	//			os.Exit(1)
-	//		}
-	//
-	//		qrterminal.Generate(
-	//			string(respQr),
-	//			qrterminal.L,
-	//			os.Stdout,
-	//		)
-	//	}
-	//
-	//	os.Exit(0)
-	//} else if *f.DeleteClient != "" {
-	//	data, err := json.Marshal(&models.DeleteClientParams{
-	//		ID: *f.DeleteClient,
-	//	})
-	//	if err != nil {
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
-	//		os.Exit(1)
-	//	}
-	//
-	//	_, err = client.LocalClient(
-	//		cfg,
-	//		models.MethodClientsDelete,
-	//		string(data),
-	//	)
-	//	if err != nil {
-	//		log.Error().Err(err).Msg("error calling API")
-	//		_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
-	//		os.Exit(1)
-	//	}
-	//
-	//	os.Exit(0)
-	//}
+	if *f.Clients {
+		resp, err := client.LocalClient(cfg, models.MethodClients, "")
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		var clients []models.ClientSummaryResponse
+		err = json.Unmarshal([]byte(resp), &clients)
+		if err != nil {
+			log.Error().Err(err).Msg("error decoding API response")
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+		}
+
+		for _, c := range clients {
+			fmt.Println("---")
+			if c.Name != "" {
+				fmt.Printf("- Name:    %s\n", c.Name)
+			}
+			if c.Address != "" {
+				fmt.Printf("- Address: %s\n", c.Address)
+			}
+			fmt.Printf("- ID:      %s\n", c.ID)
+			fmt.Printf("- Scopes:  %s\n", strings.Join(c.Scopes, ", "))
+
+			if *f.Qr {
+				// secrets aren't listed, so each one is fetched separately
+				data, err := json.Marshal(&models.ClientSecretParams{
+					Id: c.ID.String(),
+				})
+				if err != nil {
+					_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
+					os.Exit(1)
+				}
+
+				resp, err := client.LocalClient(cfg, models.MethodClientsSecret, string(data))
+				if err != nil {
+					log.Error().Err(err).Msg("error calling API")
+					_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+					os.Exit(1)
+				}
+
+				var cs models.ClientResponse
+				err = json.Unmarshal([]byte(resp), &cs)
+				if err != nil {
+					log.Error().Err(err).Msg("error decoding API response")
+					_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+					os.Exit(1)
+				}
+
+				printConnQr(cs.ID, cs.Secret)
+			}
+		}
+
+		os.Exit(0)
+	} else if *f.NewClient != "" {
+		params := models.NewClientParams{
+			Name: *f.NewClient,
+		}
+		if *f.ClientScopes != "" {
+			for _, s := range strings.Split(*f.ClientScopes, ",") {
+				params.Scopes = append(params.Scopes, strings.TrimSpace(s))
+			}
+		}
+
+		data, err := json.Marshal(&params)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
+			os.Exit(1)
+		}
+
+		resp, err := client.LocalClient(
+			cfg,
+			models.MethodClientsNew,
+			string(data),
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		var c models.ClientResponse
+		err = json.Unmarshal([]byte(resp), &c)
+		if err != nil {
+			log.Error().Err(err).Msg("error decoding API response")
+			_, _ = fmt.Fprintf(os.Stderr, "Error decoding API response: %v\n", err)
+		}
+
+		fmt.Println("New client registered:")
+		fmt.Printf("- ID:     %s\n", c.ID)
+		fmt.Printf("- Name:   %s\n", c.Name)
+		fmt.Printf("- Secret: %s\n", c.Secret)
+		fmt.Printf("- Scopes: %s\n", strings.Join(c.Scopes, ", "))
+
+		if *f.Qr {
+			printConnQr(c.ID, c.Secret)
+		}
+
+		os.Exit(0)
+	} else if *f.DeleteClient != "" {
+		data, err := json.Marshal(&models.DeleteClientParams{
+			Id: *f.DeleteClient,
+		})
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error encoding params: %v\n", err)
+			os.Exit(1)
+		}
+
+		_, err = client.LocalClient(
+			cfg,
+			models.MethodClientsDelete,
+			string(data),
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("error calling API")
+			_, _ = fmt.Fprintf(os.Stderr, "Error calling API: %v\n", err)
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
 }
 
 // Setup initializes the user config and logging. Returns a user config object.
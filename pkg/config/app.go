@@ -5,12 +5,29 @@ import "time"
 var AppVersion = "DEVELOPMENT"
 
 const (
-	AppName           = "zaparoo"
-	GamesDbFile       = "games.db"
-	TapToDbFile       = "tapto.db"
-	LogFile           = "core.log"
-	PidFile           = "core.pid"
-	CfgFile           = "config.toml"
-	UserDir           = "user"
-	ApiRequestTimeout = 30 * time.Second
+	AppName            = "zaparoo"
+	GamesDbFile        = "games.db"
+	TapToDbFile        = "tapto.db"
+	LogFile            = "core.log"
+	PidFile            = "core.pid"
+	CfgFile            = "config.toml"
+	UserDir            = "user"
+	ApiRequestTimeout  = 30 * time.Second
+	LaunchWaitTimeout  = 2 * time.Minute
+	ApiShutdownTimeout = 5 * time.Second
+	// ApiWaitRequestTimeout is the request timeout of API routes which can
+	// wait on a launch to finish, which can take up to LaunchWaitTimeout.
+	ApiWaitRequestTimeout = LaunchWaitTimeout + ApiRequestTimeout
+	// DefaultAuditRetention is how long API audit log entries are kept if
+	// no retention is set in the config.
+	DefaultAuditRetention = 30 * 24 * time.Hour
+	// DefaultLimitsGracePeriod is how long media keeps running after a
+	// playtime limit is reached, if no grace period is set in the config.
+	DefaultLimitsGracePeriod = 60 * time.Second
+	// DefaultLimitsOverride is how long playtime limits are overridden for,
+	// if no override time is set in the config.
+	DefaultLimitsOverride = time.Hour
+	// HookShutdownTimeout is how long shutdown waits for service stopped
+	// hooks to finish.
+	HookShutdownTimeout = 10 * time.Second
 )
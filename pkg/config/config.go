@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pelletier/go-toml/v2"
@@ -35,6 +37,12 @@ type Values struct {
 	Service      Service   `toml:"service,omitempty"`
 	Mappings     Mappings  `toml:"mappings,omitempty"`
 	Groovy       Groovy    `toml:"groovy,omitempty"`
+	Webhooks     Webhooks  `toml:"webhooks,omitempty"`
+	Mqtt         Mqtt      `toml:"mqtt,omitempty"`
+	Audit        Audit     `toml:"audit,omitempty"`
+	Limits       Limits    `toml:"limits,omitempty"`
+	Schedule     Schedule  `toml:"schedule,omitempty"`
+	Hooks        Hooks     `toml:"hooks,omitempty"`
 }
 
 type Audio struct {
@@ -57,6 +65,11 @@ type ReadersConnect struct {
 	Driver   string `toml:"driver"`
 	Path     string `toml:"path,omitempty"`
 	IDSource string `toml:"id_source,omitempty"`
+	// ScanMode, ExitDelay and IgnoreSystem override the global scan
+	// settings for tokens read by this reader.
+	ScanMode     string   `toml:"scan_mode,omitempty"`
+	ExitDelay    *float32 `toml:"exit_delay,omitempty"`
+	IgnoreSystem []string `toml:"ignore_system,omitempty"`
 }
 
 func (r ReadersConnect) ConnectionString() string {
@@ -92,9 +105,13 @@ type ZapScript struct {
 
 type Service struct {
 	ApiPort    int      `toml:"api_port"`
+	ApiListen  []string `toml:"api_listen,omitempty"`
 	DeviceId   string   `toml:"device_id"`
 	AllowRun   []string `toml:"allow_run,omitempty,multiline"`
 	allowRunRe []*regexp.Regexp
+	Tls        bool   `toml:"tls"`
+	TlsCert    string `toml:"tls_cert,omitempty"`
+	TlsKey     string `toml:"tls_key,omitempty"`
 }
 
 type MappingsEntry struct {
@@ -113,6 +130,103 @@ type Groovy struct {
 	GmcProxyBeaconInterval string `toml:"gmc_proxy_beacon_interval"`
 }
 
+type Webhooks struct {
+	Target []WebhooksTarget `toml:"target,omitempty"`
+}
+
+type WebhooksTarget struct {
+	URL     string            `toml:"url"`
+	Events  []string          `toml:"events,omitempty"`
+	Headers map[string]string `toml:"headers,omitempty"`
+	Secret  string            `toml:"secret,omitempty"`
+	Retries *int              `toml:"retries,omitempty"`
+}
+
+type Mqtt struct {
+	Enabled         bool   `toml:"enabled"`
+	Broker          string `toml:"broker,omitempty"`
+	Username        string `toml:"username,omitempty"`
+	Password        string `toml:"password,omitempty"`
+	TopicPrefix     string `toml:"topic_prefix,omitempty"`
+	Discovery       bool   `toml:"discovery"`
+	DiscoveryPrefix string `toml:"discovery_prefix,omitempty"`
+	// Secret must be sent with every message on the command topic. Commands
+	// are disabled if it's not set.
+	Secret string `toml:"secret,omitempty"`
+	// Scopes are the API permission scopes of commands. Defaults to read
+	// and launch.
+	Scopes []string `toml:"scopes,omitempty"`
+}
+
+type Audit struct {
+	Enabled       *bool `toml:"enabled,omitempty"`
+	RetentionDays *int  `toml:"retention_days,omitempty"`
+}
+
+type Limits struct {
+	Enabled bool `toml:"enabled"`
+	// Profile is the active limits profile. Rules with no profile always
+	// apply, other rules only apply when their profile is active.
+	Profile string `toml:"profile,omitempty"`
+	// Pin and AdminCard allow limits to be temporarily overridden, by
+	// entering the PIN through the API or scanning a card with a listed
+	// UID.
+	Pin             string       `toml:"pin,omitempty"`
+	AdminCard       []string     `toml:"admin_card,omitempty"`
+	GracePeriod     *int         `toml:"grace_period,omitempty"`
+	OverrideMinutes *int         `toml:"override_minutes,omitempty"`
+	Rule            []LimitsRule `toml:"rule,omitempty"`
+}
+
+type LimitsRule struct {
+	Profile string `toml:"profile,omitempty"`
+	// Systems the rule applies to. A rule with no systems applies to all
+	// media.
+	Systems []string `toml:"systems,omitempty"`
+	// Block blocks the rule's systems entirely.
+	Block bool `toml:"block,omitempty"`
+	// BlockPath is a list of regex patterns of media paths to block.
+	BlockPath []string `toml:"block_path,omitempty,multiline"`
+	// DailyMinutes is the total playtime allowed per day across all the
+	// rule's systems.
+	DailyMinutes *int `toml:"daily_minutes,omitempty"`
+	// AllowedTimes is a list of local time windows when play is allowed,
+	// in the format "HH:MM-HH:MM". Windows may wrap past midnight.
+	AllowedTimes []string `toml:"allowed_times,omitempty"`
+}
+
+type Schedule struct {
+	Job []ScheduleJob `toml:"job,omitempty"`
+}
+
+type ScheduleJob struct {
+	// Name identifies the job, it must be unique.
+	Name string `toml:"name"`
+	// Schedule is a 5 field cron expression, a descriptor like @daily, or
+	// "@every <duration>".
+	Schedule  string `toml:"schedule"`
+	ZapScript string `toml:"zapscript"`
+	Enabled   *bool  `toml:"enabled,omitempty"`
+	// CatchUp is "skip" (default) to ignore runs missed while Core wasn't
+	// running, or "once" to run the job once on start if any were missed.
+	CatchUp string `toml:"catch_up,omitempty"`
+}
+
+type Hooks struct {
+	Event []HooksEvent `toml:"event,omitempty"`
+}
+
+type HooksEvent struct {
+	// On is the name of the event which runs the hook, such as
+	// "media.started" or "service.started".
+	On string `toml:"on"`
+	// System and Driver limit the hook to events for media of the listed
+	// systems, or readers using the listed drivers.
+	System    []string `toml:"system,omitempty"`
+	Driver    []string `toml:"driver,omitempty"`
+	ZapScript string   `toml:"zapscript"`
+}
+
 var BaseDefaults = Values{
 	ConfigSchema: SchemaVersion,
 	Audio: Audio{
@@ -132,6 +246,12 @@ var BaseDefaults = Values{
 		GmcProxyPort:           32106,
 		GmcProxyBeaconInterval: "2s",
 	},
+	Mqtt: Mqtt{
+		Enabled:         false,
+		TopicPrefix:     "zaparoo",
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+	},
 }
 
 type Instance struct {
@@ -325,6 +445,39 @@ func (c *Instance) ReadersScan() ReadersScan {
 	return c.vals.Readers.Scan
 }
 
+// ReaderScan returns the scan settings for a reader, by its connection
+// string. Settings not overridden by the reader's connect entry, or all
+// settings for auto-detected readers, are taken from the global scan
+// settings.
+func (c *Instance) ReaderScan(source string) ReadersScan {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	scan := c.vals.Readers.Scan
+	if scan.Mode == "" {
+		scan.Mode = ScanModeTap
+	}
+
+	for _, rc := range c.vals.Readers.Connect {
+		if rc.ConnectionString() != source {
+			continue
+		}
+
+		if rc.ScanMode != "" {
+			scan.Mode = rc.ScanMode
+		}
+		if rc.ExitDelay != nil {
+			scan.ExitDelay = *rc.ExitDelay
+		}
+		if rc.IgnoreSystem != nil {
+			scan.IgnoreSystem = rc.IgnoreSystem
+		}
+		break
+	}
+
+	return scan
+}
+
 func (c *Instance) TapModeEnabled() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -424,6 +577,28 @@ func (c *Instance) ApiPort() int {
 	return c.vals.Service.ApiPort
 }
 
+// ApiListen returns the addresses the API server binds to. If none are set,
+// it binds to all interfaces.
+func (c *Instance) ApiListen() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Service.ApiListen
+}
+
+func (c *Instance) ApiTls() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Service.Tls
+}
+
+// ApiTlsFiles returns the user provided certificate and key paths for the
+// API server, if set.
+func (c *Instance) ApiTlsFiles() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Service.TlsCert, c.vals.Service.TlsKey
+}
+
 func (c *Instance) IsExecuteAllowed(s string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -547,3 +722,112 @@ func (c *Instance) GmcProxyBeaconInterval() string {
 	defer c.mu.RUnlock()
 	return c.vals.Groovy.GmcProxyBeaconInterval
 }
+
+func (c *Instance) WebhookTargets() []WebhooksTarget {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Webhooks.Target
+}
+
+func (c *Instance) Mqtt() Mqtt {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Mqtt
+}
+
+// AuditEnabled returns true if API requests are recorded in the audit log.
+// The audit log is enabled unless turned off in the config.
+func (c *Instance) AuditEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.vals.Audit.Enabled == nil {
+		return true
+	}
+	return *c.vals.Audit.Enabled
+}
+
+// AuditRetention returns how long audit log entries are kept. A retention
+// of 0 keeps entries forever.
+func (c *Instance) AuditRetention() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	days := c.vals.Audit.RetentionDays
+	if days == nil || *days < 0 {
+		return DefaultAuditRetention
+	}
+	return time.Duration(*days) * 24 * time.Hour
+}
+
+func (c *Instance) Limits() Limits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Limits
+}
+
+// LimitsRules returns the limits rules which apply to the active profile.
+// Returns nil if limits are disabled.
+func (c *Instance) LimitsRules() []LimitsRule {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.vals.Limits.Enabled {
+		return nil
+	}
+
+	var rules []LimitsRule
+	for _, r := range c.vals.Limits.Rule {
+		if r.Profile == "" || strings.EqualFold(r.Profile, c.vals.Limits.Profile) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// LimitsGracePeriod returns how long media can keep running after a limit
+// is reached before it's stopped.
+func (c *Instance) LimitsGracePeriod() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	secs := c.vals.Limits.GracePeriod
+	if secs == nil || *secs < 0 {
+		return DefaultLimitsGracePeriod
+	}
+	return time.Duration(*secs) * time.Second
+}
+
+// LimitsOverrideDuration returns how long limits are overridden for after
+// the PIN is entered or an admin card is scanned.
+func (c *Instance) LimitsOverrideDuration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	mins := c.vals.Limits.OverrideMinutes
+	if mins == nil || *mins <= 0 {
+		return DefaultLimitsOverride
+	}
+	return time.Duration(*mins) * time.Minute
+}
+
+// IsLimitsPin returns true if limits have a PIN set and it matches.
+func (c *Instance) IsLimitsPin(pin string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	want := c.vals.Limits.Pin
+	return want != "" && subtle.ConstantTimeCompare([]byte(want), []byte(pin)) == 1
+}
+
+func (c *Instance) ScheduleJobs() []ScheduleJob {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Schedule.Job
+}
+
+func (c *Instance) HookEvents() []HooksEvent {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Hooks.Event
+}
+
+func (c *Instance) DeviceId() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Service.DeviceId
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderScan(t *testing.T) {
+	delay := float32(0)
+	cfg := &Instance{
+		vals: Values{
+			Readers: Readers{
+				Scan: ReadersScan{
+					Mode:         ScanModeTap,
+					ExitDelay:    5,
+					IgnoreSystem: []string{"DOS"},
+				},
+				Connect: []ReadersConnect{
+					{
+						Driver:   "pn532_uart",
+						Path:     "/dev/ttyUSB0",
+						ScanMode: ScanModeHold,
+					},
+					{
+						Driver:       "file",
+						Path:         "/tmp/scan",
+						ExitDelay:    &delay,
+						IgnoreSystem: []string{},
+					},
+				},
+			},
+		},
+	}
+
+	hold := cfg.ReaderScan("pn532_uart:/dev/ttyUSB0")
+	assert.Equal(t, ScanModeHold, hold.Mode)
+	assert.Equal(t, float32(5), hold.ExitDelay)
+	assert.Equal(t, []string{"DOS"}, hold.IgnoreSystem)
+
+	file := cfg.ReaderScan("file:/tmp/scan")
+	assert.Equal(t, ScanModeTap, file.Mode)
+	assert.Equal(t, float32(0), file.ExitDelay)
+	assert.Empty(t, file.IgnoreSystem)
+
+	detected := cfg.ReaderScan("acr122_pcsc:ACS")
+	assert.Equal(t, cfg.vals.Readers.Scan, detected)
+}
+
+func TestLimitsRules(t *testing.T) {
+	cfg := &Instance{
+		vals: Values{
+			Limits: Limits{
+				Profile: "kids",
+				Rule: []LimitsRule{
+					{Systems: []string{"SNES"}},
+					{Profile: "Kids", Systems: []string{"Genesis"}},
+					{Profile: "guests", Systems: []string{"PSX"}},
+				},
+			},
+		},
+	}
+
+	assert.Nil(t, cfg.LimitsRules())
+
+	cfg.vals.Limits.Enabled = true
+	rules := cfg.LimitsRules()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, []string{"Genesis"}, rules[1].Systems)
+
+	assert.False(t, cfg.IsLimitsPin(""))
+	cfg.vals.Limits.Pin = "1234"
+	assert.True(t, cfg.IsLimitsPin("1234"))
+	assert.False(t, cfg.IsLimitsPin("123"))
+}
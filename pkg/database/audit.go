@@ -0,0 +1,135 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// AuditEntry is a record of a single API request.
+type AuditEntry struct {
+	Time       time.Time  `json:"time"`
+	Method     string     `json:"method"`
+	ClientID   *uuid.UUID `json:"clientId,omitempty"`
+	ClientName string     `json:"clientName,omitempty"`
+	Address    string     `json:"address"`
+	Local      bool       `json:"local"`
+	// ParamsDigest is the hex SHA-256 of the request params, so repeated
+	// requests can be matched without storing the params themselves.
+	ParamsDigest string `json:"paramsDigest,omitempty"`
+	Ok           bool   `json:"ok"`
+	ErrorCode    int    `json:"errorCode,omitempty"`
+}
+
+// AuditQuery filters the entries returned from the audit log. Empty fields
+// are not filtered on.
+type AuditQuery struct {
+	Limit    int
+	Method   string
+	ClientID *uuid.UUID
+	Since    time.Time
+}
+
+// timeKey sorts entries by time, with a sequence number to keep entries
+// recorded at the same time unique.
+func timeKey(t time.Time, seq uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(k[8:], seq)
+	return k
+}
+
+func (d *Database) AddAuditEntry(entry AuditEntry) error {
+	// entries from concurrent requests are batched into a single
+	// transaction, so each request doesn't wait on its own disk sync
+	return d.bdb.Batch(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketAudit))
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(timeKey(entry.Time, seq), data)
+	})
+}
+
+// GetAudit returns entries matching the query from the audit log, newest
+// first.
+func (d *Database) GetAudit(q AuditQuery) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketAudit))
+
+		var since []byte
+		if !q.Since.IsZero() {
+			since = timeKey(q.Since, 0)
+		}
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if q.Limit > 0 && len(entries) >= q.Limit {
+				break
+			}
+
+			if since != nil && bytes.Compare(k, since) < 0 {
+				break
+			}
+
+			var entry AuditEntry
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return err
+			}
+
+			if q.Method != "" && entry.Method != q.Method {
+				continue
+			}
+
+			if q.ClientID != nil &&
+				(entry.ClientID == nil || *entry.ClientID != *q.ClientID) {
+				continue
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// PruneAudit deletes all audit log entries recorded before the given time.
+// Returns the number of entries deleted.
+func (d *Database) PruneAudit(before time.Time) (int, error) {
+	deleted := 0
+
+	err := d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketAudit))
+
+		end := timeKey(before, 0)
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+			err := c.Delete()
+			if err != nil {
+				return err
+			}
+			deleted++
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testDatabase(t *testing.T) *Database {
+	bdb, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = bdb.Close()
+	})
+
+	err = bdb.Update(func(txn *bolt.Tx) error {
+		for _, bucket := range []string{
+			BucketHistory,
+			BucketClients,
+			BucketAudit,
+			BucketSessions,
+			BucketSchedule,
+		} {
+			_, err := txn.CreateBucketIfNotExists([]byte(bucket))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	return &Database{bdb: bdb}
+}
+
+func TestAudit(t *testing.T) {
+	db := testDatabase(t)
+	clientID := uuid.New()
+	now := time.Now()
+
+	entries := []AuditEntry{
+		{Time: now.Add(-48 * time.Hour), Method: "run", Ok: true},
+		{Time: now.Add(-time.Hour), Method: "settings.update", ClientID: &clientID, ErrorCode: 3},
+		{Time: now, Method: "run", ClientID: &clientID, Ok: true},
+		{Time: now, Method: "version", Ok: true},
+	}
+	for _, e := range entries {
+		require.NoError(t, db.AddAuditEntry(e))
+	}
+
+	all, err := db.GetAudit(AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "version", all[0].Method)
+	assert.Equal(t, "run", all[3].Method)
+
+	limited, err := db.GetAudit(AuditQuery{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	runs, err := db.GetAudit(AuditQuery{Method: "run"})
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	client, err := db.GetAudit(AuditQuery{ClientID: &clientID})
+	require.NoError(t, err)
+	require.Len(t, client, 2)
+	assert.Equal(t, "settings.update", client[1].Method)
+
+	recent, err := db.GetAudit(AuditQuery{Since: now.Add(-2 * time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, recent, 3)
+
+	n, err := db.PruneAudit(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	all, err = db.GetAudit(AuditQuery{})
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+const clientSecretLength = 32
+
+var ErrClientNotFound = errors.New("client not found")
+
+// Client is a registered API client which is allowed to access the API
+// from a remote address.
+type Client struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Address string    `json:"address"`
+	Secret  string    `json:"secret"`
+	Created int64     `json:"created"`
+	// Scopes are the permission scopes granted to the client. Clients
+	// registered before scopes were added have none stored.
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func clientKey(id uuid.UUID) []byte {
+	return []byte(fmt.Sprintf("clients:%s", id.String()))
+}
+
+func newClientSecret() (string, error) {
+	b := make([]byte, clientSecretLength)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// AddClient registers a new API client with a generated ID and secret, and
+// the given permission scopes. Returns the newly stored client.
+func (d *Database) AddClient(name string, scopes []string) (Client, error) {
+	secret, err := newClientSecret()
+	if err != nil {
+		return Client{}, fmt.Errorf("error generating client secret: %w", err)
+	}
+
+	c := Client{
+		ID:      uuid.New(),
+		Name:    name,
+		Secret:  secret,
+		Created: time.Now().Unix(),
+		Scopes:  scopes,
+	}
+
+	cd, err := json.Marshal(c)
+	if err != nil {
+		return Client{}, err
+	}
+
+	err = d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketClients))
+		return b.Put(clientKey(c.ID), cd)
+	})
+	if err != nil {
+		return Client{}, err
+	}
+
+	return c, nil
+}
+
+func (d *Database) GetClient(id uuid.UUID) (Client, error) {
+	var c Client
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketClients))
+
+		v := b.Get(clientKey(id))
+		if v == nil {
+			return ErrClientNotFound
+		}
+
+		return json.Unmarshal(v, &c)
+	})
+
+	return c, err
+}
+
+func (d *Database) GetAllClients() ([]Client, error) {
+	var cs = make([]Client, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketClients))
+
+		c := b.Cursor()
+		prefix := []byte("clients:")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var client Client
+			err := json.Unmarshal(v, &client)
+			if err != nil {
+				return err
+			}
+			cs = append(cs, client)
+		}
+
+		return nil
+	})
+
+	return cs, err
+}
+
+// GetClientBySecret returns the registered client matching the given
+// secret. Secrets are compared in constant time.
+func (d *Database) GetClientBySecret(secret string) (Client, error) {
+	if secret == "" {
+		return Client{}, ErrClientNotFound
+	}
+
+	cs, err := d.GetAllClients()
+	if err != nil {
+		return Client{}, err
+	}
+
+	for _, c := range cs {
+		if subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) == 1 {
+			return c, nil
+		}
+	}
+
+	return Client{}, ErrClientNotFound
+}
+
+// UpdateClientAddress stores the last known remote address of a client.
+func (d *Database) UpdateClientAddress(id uuid.UUID, address string) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketClients))
+
+		v := b.Get(clientKey(id))
+		if v == nil {
+			return ErrClientNotFound
+		}
+
+		var c Client
+		err := json.Unmarshal(v, &c)
+		if err != nil {
+			return err
+		}
+
+		c.Address = address
+
+		cd, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(clientKey(id), cd)
+	})
+}
+
+func (d *Database) DeleteClient(id uuid.UUID) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketClients))
+		if b.Get(clientKey(id)) == nil {
+			return ErrClientNotFound
+		}
+		return b.Delete(clientKey(id))
+	})
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testClientsDatabase(t *testing.T) *Database {
+	db := testDatabase(t)
+	err := db.bdb.Update(func(txn *bolt.Tx) error {
+		_, err := txn.CreateBucketIfNotExists([]byte(BucketClients))
+		return err
+	})
+	require.NoError(t, err)
+	return db
+}
+
+func TestClients(t *testing.T) {
+	db := testClientsDatabase(t)
+
+	c, err := db.AddClient("phone", []string{"read"})
+	require.NoError(t, err)
+	assert.Len(t, c.Secret, clientSecretLength*2)
+	assert.Equal(t, []string{"read"}, c.Scopes)
+
+	other, err := db.AddClient("tablet", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, c.Secret, other.Secret)
+
+	got, err := db.GetClient(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, c, got)
+
+	all, err := db.GetAllClients()
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	_, err = db.GetClient(uuid.New())
+	assert.ErrorIs(t, err, ErrClientNotFound)
+
+	require.NoError(t, db.UpdateClientAddress(c.ID, "192.168.1.20"))
+	got, err = db.GetClient(c.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.20", got.Address)
+
+	require.NoError(t, db.DeleteClient(c.ID))
+	_, err = db.GetClient(c.ID)
+	assert.ErrorIs(t, err, ErrClientNotFound)
+	assert.ErrorIs(t, db.DeleteClient(c.ID), ErrClientNotFound)
+	assert.ErrorIs(t, db.UpdateClientAddress(c.ID, "192.168.1.20"), ErrClientNotFound)
+}
+
+func TestGetClientBySecret(t *testing.T) {
+	db := testClientsDatabase(t)
+
+	c, err := db.AddClient("phone", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "match", secret: c.Secret},
+		{name: "mismatch", secret: c.Secret[:len(c.Secret)-1] + "x", wantErr: true},
+		{name: "prefix", secret: c.Secret[:8], wantErr: true},
+		{name: "empty", secret: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.GetClientBySecret(tt.secret)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrClientNotFound)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.ID, got.ID)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
@@ -15,6 +16,9 @@ const (
 	BucketHistory  = "history"
 	BucketMappings = "mappings"
 	BucketClients  = "clients"
+	BucketAudit    = "audit"
+	BucketSessions = "sessions"
+	BucketSchedule = "schedule"
 )
 
 func dbFile(pl platforms.Platform) string {
@@ -45,6 +49,9 @@ func open(pl platforms.Platform, options *bolt.Options) (*bolt.DB, error) {
 			BucketHistory,
 			BucketMappings,
 			BucketClients,
+			BucketAudit,
+			BucketSessions,
+			BucketSchedule,
 		} {
 			_, err := txn.CreateBucketIfNotExists([]byte(bucket))
 			if err != nil {
@@ -86,6 +93,7 @@ type HistoryEntry struct {
 	UID     string    `json:"uid"`
 	Text    string    `json:"text"`
 	Data    string    `json:"data"`
+	Source  string    `json:"source,omitempty"`
 	Success bool      `json:"success"`
 }
 
@@ -107,6 +115,33 @@ func (d *Database) AddHistory(entry HistoryEntry) error {
 	})
 }
 
+// GetAllHistory returns every entry in the token history, oldest first.
+func (d *Database) GetAllHistory() ([]HistoryEntry, error) {
+	entries := make([]HistoryEntry, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketHistory))
+
+		return b.ForEach(func(_, v []byte) error {
+			var entry HistoryEntry
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+
+	// keys include the local time offset, so aren't strictly ordered
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, err
+}
+
 func (d *Database) GetHistory() ([]HistoryEntry, error) {
 	var entries []HistoryEntry
 	i := 0
@@ -3,6 +3,7 @@ package database
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -17,15 +18,25 @@ const (
 	MappingTypeUID   = "uid"
 	MappingTypeText  = "text"
 	MappingTypeData  = "data"
+	MappingTypeCombo = "combo"
 	MatchTypeExact   = "exact"
 	MatchTypePartial = "partial"
 	MatchTypeRegex   = "regex"
 )
 
+// ComboSeparator splits the parts of a combo mapping pattern. Each part is
+// a single token mapping type and pattern separated by a colon, for example:
+// "uid:04aabbcc&&text:**launch.random:snes". The mapping's match type is
+// used for every part.
+const ComboSeparator = "&&"
+
+var ErrMappingNotFound = errors.New("mapping not found")
+
 var AllowedMappingTypes = []string{
 	MappingTypeUID,
 	MappingTypeText,
 	MappingTypeData,
+	MappingTypeCombo,
 }
 
 var AllowedMatchTypes = []string{
@@ -34,6 +45,57 @@ var AllowedMatchTypes = []string{
 	MatchTypeRegex,
 }
 
+type ComboPart struct {
+	Type    string
+	Pattern string
+}
+
+// ParseComboPattern splits a combo mapping pattern into its parts. A combo
+// must have at least two parts and can't contain another combo.
+func ParseComboPattern(pattern string) ([]ComboPart, error) {
+	var parts []ComboPart
+	for _, p := range strings.Split(pattern, ComboSeparator) {
+		ps := strings.SplitN(strings.TrimSpace(p), ":", 2)
+		if len(ps) != 2 || ps[1] == "" {
+			return nil, fmt.Errorf("invalid combo part: %s", p)
+		}
+
+		t := strings.ToLower(ps[0])
+		if t == MappingTypeCombo || !utils.Contains(AllowedMappingTypes, t) {
+			return nil, fmt.Errorf("invalid combo part type: %s", ps[0])
+		}
+
+		parts = append(parts, ComboPart{
+			Type:    t,
+			Pattern: ps[1],
+		})
+	}
+
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("combo must have at least 2 parts")
+	}
+
+	return parts, nil
+}
+
+func validateCombo(m Mapping) error {
+	parts, err := ParseComboPattern(m.Pattern)
+	if err != nil {
+		return err
+	}
+
+	if m.Match == MatchTypeRegex {
+		for _, p := range parts {
+			_, err := regexp.Compile(p.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid regex pattern: %s", p.Pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
 type Mapping struct {
 	Id       string `json:"id"`
 	Added    int64  `json:"added"`
@@ -73,7 +135,12 @@ func (d *Database) AddMapping(m Mapping) error {
 		return fmt.Errorf("missing pattern")
 	}
 
-	if m.Match == MatchTypeRegex {
+	if m.Type == MappingTypeCombo {
+		err := validateCombo(m)
+		if err != nil {
+			return err
+		}
+	} else if m.Match == MatchTypeRegex {
 		_, err := regexp.Compile(m.Pattern)
 		if err != nil {
 			return fmt.Errorf("invalid regex pattern: %s", m.Pattern)
@@ -102,7 +169,7 @@ func (d *Database) GetMapping(id string) (Mapping, error) {
 
 		v := b.Get(mappingKey(id))
 		if v == nil {
-			return fmt.Errorf("mapping not found: %s", id)
+			return fmt.Errorf("%w: %s", ErrMappingNotFound, id)
 		}
 
 		return json.Unmarshal(v, &m)
@@ -114,6 +181,11 @@ func (d *Database) GetMapping(id string) (Mapping, error) {
 func (d *Database) DeleteMapping(id string) error {
 	return d.bdb.Update(func(txn *bolt.Tx) error {
 		b := txn.Bucket([]byte(BucketMappings))
+
+		if b.Get(mappingKey(id)) == nil {
+			return fmt.Errorf("%w: %s", ErrMappingNotFound, id)
+		}
+
 		return b.Delete(mappingKey(id))
 	})
 }
@@ -135,7 +207,12 @@ func (d *Database) UpdateMapping(id string, m Mapping) error {
 		return fmt.Errorf("missing pattern")
 	}
 
-	if m.Match == MatchTypeRegex {
+	if m.Type == MappingTypeCombo {
+		err := validateCombo(m)
+		if err != nil {
+			return err
+		}
+	} else if m.Match == MatchTypeRegex {
 		_, err := regexp.Compile(m.Pattern)
 		if err != nil {
 			return fmt.Errorf("invalid regex pattern: %s", m.Pattern)
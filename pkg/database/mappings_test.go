@@ -0,0 +1,28 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseComboPattern(t *testing.T) {
+	parts, err := ParseComboPattern("uid:04aabb && TEXT:**launch.random:snes")
+	require.NoError(t, err)
+	assert.Equal(t, []ComboPart{
+		{Type: MappingTypeUID, Pattern: "04aabb"},
+		{Type: MappingTypeText, Pattern: "**launch.random:snes"},
+	}, parts)
+
+	for _, pattern := range []string{
+		"uid:04aabb",
+		"uid:04aabb&&",
+		"uid:04aabb&&name:test",
+		"uid:04aabb&&combo:test",
+		"uid:04aabb&&text:",
+	} {
+		_, err := ParseComboPattern(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
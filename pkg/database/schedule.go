@@ -0,0 +1,189 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	CatchUpSkip = "skip"
+	CatchUpOnce = "once"
+)
+
+var AllowedCatchUps = []string{
+	CatchUpSkip,
+	CatchUpOnce,
+}
+
+var ErrJobNotFound = errors.New("scheduled job not found")
+
+// ScheduledJob is a ZapScript run on a cron schedule, added through the
+// API. Jobs from the config file aren't stored here, but their status is.
+type ScheduledJob struct {
+	Id        string `json:"id"`
+	Added     int64  `json:"added"`
+	Label     string `json:"label"`
+	Enabled   bool   `json:"enabled"`
+	Schedule  string `json:"schedule"`
+	ZapScript string `json:"zapscript"`
+	// CatchUp sets what happens to runs missed while Core wasn't running.
+	CatchUp string `json:"catchUp"`
+}
+
+// JobStatus is the outcome of the last run of a scheduled job.
+type JobStatus struct {
+	LastRun time.Time `json:"lastRun"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func jobKey(id string) []byte {
+	return []byte(fmt.Sprintf("jobs:%s", id))
+}
+
+func jobStatusKey(id string) []byte {
+	return []byte(fmt.Sprintf("status:%s", id))
+}
+
+func (d *Database) AddScheduledJob(j ScheduledJob) (string, error) {
+	var id string
+	j.Added = time.Now().Unix()
+
+	err := d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		id = strconv.FormatUint(seq, 10)
+		j.Id = id
+
+		data, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(jobKey(id), data)
+	})
+
+	return id, err
+}
+
+func (d *Database) GetScheduledJob(id string) (ScheduledJob, error) {
+	var j ScheduledJob
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		v := b.Get(jobKey(id))
+		if v == nil {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+
+		return json.Unmarshal(v, &j)
+	})
+
+	return j, err
+}
+
+func (d *Database) UpdateScheduledJob(id string, j ScheduledJob) error {
+	j.Id = id
+
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		if b.Get(jobKey(id)) == nil {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+
+		data, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(jobKey(id), data)
+	})
+}
+
+// DeleteScheduledJob deletes a job and its status.
+func (d *Database) DeleteScheduledJob(id string) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		if b.Get(jobKey(id)) == nil {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, id)
+		}
+
+		err := b.Delete(jobStatusKey(id))
+		if err != nil {
+			return err
+		}
+
+		return b.Delete(jobKey(id))
+	})
+}
+
+func (d *Database) GetAllScheduledJobs() ([]ScheduledJob, error) {
+	jobs := make([]ScheduledJob, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		c := b.Cursor()
+		prefix := []byte("jobs:")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var j ScheduledJob
+			err := json.Unmarshal(v, &j)
+			if err != nil {
+				return err
+			}
+
+			j.Id = strings.TrimPrefix(string(k), string(prefix))
+			jobs = append(jobs, j)
+		}
+
+		return nil
+	})
+
+	return jobs, err
+}
+
+// GetJobStatus returns the status of a scheduled job. A job which has never
+// run has an empty status.
+func (d *Database) GetJobStatus(id string) (JobStatus, error) {
+	var s JobStatus
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		v := b.Get(jobStatusKey(id))
+		if v == nil {
+			return nil
+		}
+
+		return json.Unmarshal(v, &s)
+	})
+
+	return s, err
+}
+
+func (d *Database) SetJobStatus(id string, s JobStatus) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedule))
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(jobStatusKey(id), data)
+	})
+}
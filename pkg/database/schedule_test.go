@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledJobs(t *testing.T) {
+	db := testDatabase(t)
+
+	id, err := db.AddScheduledJob(ScheduledJob{
+		Label:     "lights out",
+		Enabled:   true,
+		Schedule:  "0 22 * * *",
+		ZapScript: "**stop",
+		CatchUp:   CatchUpSkip,
+	})
+	require.NoError(t, err)
+
+	jobs, err := db.GetAllScheduledJobs()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, id, jobs[0].Id)
+	assert.Equal(t, "**stop", jobs[0].ZapScript)
+
+	status, err := db.GetJobStatus(id)
+	require.NoError(t, err)
+	assert.True(t, status.LastRun.IsZero())
+
+	ran := time.Date(2024, 12, 1, 22, 0, 0, 0, time.UTC)
+	err = db.SetJobStatus(id, JobStatus{LastRun: ran, Success: true})
+	require.NoError(t, err)
+
+	// statuses aren't listed as jobs
+	jobs, err = db.GetAllScheduledJobs()
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	status, err = db.GetJobStatus(id)
+	require.NoError(t, err)
+	assert.True(t, ran.Equal(status.LastRun))
+
+	err = db.DeleteScheduledJob(id)
+	require.NoError(t, err)
+
+	status, err = db.GetJobStatus(id)
+	require.NoError(t, err)
+	assert.True(t, status.LastRun.IsZero())
+
+	err = db.DeleteScheduledJob(id)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+
+	err = db.UpdateScheduledJob("100", ScheduledJob{})
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SessionToken is the token which launched the media of a play session.
+type SessionToken struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+	Text string `json:"text"`
+	Data string `json:"data"`
+}
+
+// Session is a single period of media being active, from when it was
+// started until it was stopped or replaced by other media.
+type Session struct {
+	Start      time.Time     `json:"start"`
+	End        time.Time     `json:"end"`
+	SystemID   string        `json:"systemId"`
+	SystemName string        `json:"systemName"`
+	MediaPath  string        `json:"mediaPath"`
+	MediaName  string        `json:"mediaName"`
+	Token      *SessionToken `json:"token,omitempty"`
+}
+
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func (d *Database) AddSession(s Session) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSessions))
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(timeKey(s.Start, seq), data)
+	})
+}
+
+// GetSessions returns all sessions started between since and until, oldest
+// first. A zero time is not filtered on.
+func (d *Database) GetSessions(since time.Time, until time.Time) ([]Session, error) {
+	sessions := make([]Session, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSessions))
+
+		var end []byte
+		if !until.IsZero() {
+			end = timeKey(until, 0)
+		}
+
+		c := b.Cursor()
+		var k, v []byte
+		if since.IsZero() {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek(timeKey(since, 0))
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+
+			var s Session
+			err := json.Unmarshal(v, &s)
+			if err != nil {
+				return err
+			}
+
+			sessions = append(sessions, s)
+		}
+
+		return nil
+	})
+
+	return sessions, err
+}
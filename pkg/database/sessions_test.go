@@ -0,0 +1,41 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSessions(t *testing.T) {
+	db := testDatabase(t)
+
+	start := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	for i, name := range []string{"a", "b", "c"} {
+		s := start.Add(time.Duration(i) * time.Hour)
+		err := db.AddSession(Session{
+			Start:     s,
+			End:       s.Add(30 * time.Minute),
+			SystemID:  "SNES",
+			MediaName: name,
+		})
+		require.NoError(t, err)
+	}
+
+	sessions, err := db.GetSessions(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	assert.Equal(t, "a", sessions[0].MediaName)
+	assert.Equal(t, 30*time.Minute, sessions[0].Duration())
+
+	sessions, err = db.GetSessions(start.Add(time.Hour), time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "b", sessions[0].MediaName)
+
+	sessions, err = db.GetSessions(start, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "b", sessions[1].MediaName)
+}
@@ -117,7 +117,10 @@ func Start(
 					ScanTime: time.Now(),
 				}
 				st.SetActiveCard(t)
-				itq <- t
+				select {
+				case itq <- t:
+				case <-ctx.Done():
+				}
 			} else if proxyAddr != nil {
 				_, err := proxyConn.WriteTo(gmcBytes, *proxyAddr)
 				if err != nil {
@@ -1,6 +1,7 @@
 package platforms
 
 import (
+	"context"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
@@ -32,6 +33,9 @@ const (
 )
 
 type CmdEnv struct {
+	// Ctx is cancelled if the launch the command is part of is superseded
+	// or stopped. Long running commands should return early when it's done.
+	Ctx           context.Context
 	Cmd           string
 	Args          string
 	NamedArgs     map[string]string
@@ -41,6 +45,13 @@ type CmdEnv struct {
 	TotalCommands int
 	CurrentIndex  int
 	Unsafe        bool
+	// DryRun is true if launch commands should only resolve the media they
+	// would launch, without launching it.
+	DryRun bool
+	// CheckLaunch, if set, is called with the path and launcher ID of the
+	// media a launch command resolved to, right before launching it. The
+	// launch is refused if it returns an error.
+	CheckLaunch func(path string, launcher string) error
 }
 
 // CmdResult returns a summary of what global side effects may or may not have
@@ -54,6 +65,9 @@ type CmdResult struct {
 	PlaylistChanged bool
 	// Playlist is the result of the playlist change.
 	Playlist *playlists.Playlist
+	// Path is the resolved path of the media launched by the command, if
+	// the command launched a file.
+	Path string
 }
 
 type ScanResult struct {
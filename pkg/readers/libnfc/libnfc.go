@@ -134,7 +134,7 @@ func (r *Reader) Close() error {
 	if r.pnd == nil {
 		return nil
 	} else {
-		log.Debug().Msgf("closing device: %s", r.conn)
+		log.Debug().Msgf("closing device: %s", r.conn.ConnectionString())
 		return r.pnd.Close()
 	}
 }
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
+	"github.com/rs/zerolog/log"
+)
+
+// clientConfirm returns a function which asks the most recently connected
+// client with the unsafe scope to confirm a command blocked for remote
+// sources. Commands are refused if no such client is connected.
+func clientConfirm(sess *sessions.Manager) zapscript.ConfirmFunc {
+	return func(ctx context.Context, cmd string, text string) (bool, error) {
+		s, ok := sess.Latest(models.ScopeUnsafe)
+		if !ok {
+			log.Debug().Msgf("no client connected to confirm command: %s", cmd)
+			return false, nil
+		}
+
+		log.Info().Msgf("asking client %s to confirm command: %s", s.ClientName, cmd)
+		result, err := sess.Request(ctx, s.ID, models.ClientMethodConfirm, models.ConfirmParams{
+			Cmd:  cmd,
+			Text: text,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		var resp models.ConfirmResponse
+		err = json.Unmarshal(result, &resp)
+		if err != nil {
+			return false, fmt.Errorf("error decoding confirm response: %w", err)
+		}
+
+		return resp.Confirmed, nil
+	}
+}
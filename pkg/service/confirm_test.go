@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientConfirm(t *testing.T) {
+	sess := sessions.NewManager()
+	confirm := clientConfirm(sess)
+
+	// nobody connected to ask
+	ok, err := confirm(context.Background(), "execute", "**execute:ls")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	clientID := uuid.New()
+	s := sessions.Session{
+		ID:        uuid.New(),
+		ClientID:  &clientID,
+		Scopes:    []string{models.ScopeUnsafe},
+		Connected: time.Now(),
+	}
+
+	var asked models.ConfirmParams
+	reply := true
+	sess.Add(s, func(data []byte) error {
+		var req models.RequestObject
+		err := json.Unmarshal(data, &req)
+		if err != nil {
+			return err
+		}
+		if req.Method != models.ClientMethodConfirm {
+			t.Errorf("unexpected method: %s", req.Method)
+		}
+		err = json.Unmarshal(req.Params, &asked)
+		if err != nil {
+			return err
+		}
+		go sess.Resolve(s.ID, models.ResponseObject{
+			JSONRPC: "2.0",
+			ID:      *req.ID,
+			Result:  models.ConfirmResponse{Confirmed: reply},
+		})
+		return nil
+	})
+
+	ok, err = confirm(context.Background(), "execute", "**execute:ls")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, models.ConfirmParams{Cmd: "execute", Text: "**execute:ls"}, asked)
+
+	reply = false
+	ok, err = confirm(context.Background(), "execute", "**execute:ls")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/systemdefs"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	HookServiceStarted = "service.started"
+	HookServiceStopped = "service.stopped"
+)
+
+// hookEvents is every event which can run a hook.
+var hookEvents = []string{
+	models.NotificationStarted,
+	models.NotificationStopped,
+	models.NotificationReadersConnected,
+	models.NotificationReadersDisconnected,
+	models.NotificationTokensRemoved,
+	HookServiceStarted,
+	HookServiceStopped,
+}
+
+// driverHookEvents are the events which include the reader driver, and
+// can be filtered by it.
+var driverHookEvents = []string{
+	models.NotificationReadersConnected,
+	models.NotificationReadersDisconnected,
+}
+
+// hookEventQueueSize is the number of events queued before new ones are
+// dropped.
+const hookEventQueueSize = 10
+
+// hookEventGrace is how long after a hook's launch finishes that media
+// events are still treated as caused by it. Some platforms only report
+// media changes on their next poll.
+const hookEventGrace = 5 * time.Second
+
+// hookNotification is a notification queued for the hook runner.
+// HookLaunch is true if it was sent while a hook was being launched.
+type hookNotification struct {
+	notif      models.Notification
+	hookLaunch bool
+}
+
+// hookEvent is an event which may run hooks. System is the media system
+// the event relates to, and Driver the reader driver, if known.
+type hookEvent struct {
+	name   string
+	system string
+	driver string
+}
+
+// hookRunner runs the ZapScript of event hooks from the config. Hooks are
+// run through the launch queue after any launch in progress, and are never
+// recorded in the history.
+type hookRunner struct {
+	mu     sync.Mutex
+	cfg    *config.Instance
+	q      *launchQueue
+	events chan hookNotification
+	// system is the system of the active media, as of the last media
+	// event, used for events which don't include it.
+	system string
+}
+
+func newHookRunner(cfg *config.Instance, q *launchQueue) *hookRunner {
+	for _, h := range cfg.HookEvents() {
+		if !utils.Contains(hookEvents, h.On) {
+			log.Warn().Msgf("unknown hook event: %s", h.On)
+		} else if len(h.Driver) > 0 && !utils.Contains(driverHookEvents, h.On) {
+			log.Error().Msgf("driver filter not supported for %s hooks, hook will not run", h.On)
+		}
+	}
+
+	return &hookRunner{
+		cfg:    cfg,
+		q:      q,
+		events: make(chan hookNotification, hookEventQueueSize),
+	}
+}
+
+// notify is an API notification handler. It never blocks.
+func (hr *hookRunner) notify(notif models.Notification) {
+	if !utils.Contains(hookEvents, notif.Method) {
+		return
+	}
+
+	hn := hookNotification{notif: notif}
+	if notif.Method == models.NotificationStarted || notif.Method == models.NotificationStopped {
+		hn.hookLaunch = hr.q.hookLaunching(time.Now())
+	}
+
+	select {
+	case hr.events <- hn:
+	default:
+		log.Warn().Msgf("hook event queue full, dropping: %s", notif.Method)
+	}
+}
+
+func (hr *hookRunner) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case hn := <-hr.events:
+			e := hr.event(hn.notif)
+			if hn.hookLaunch {
+				// a hook's own launch never runs more hooks, so a
+				// media.started hook can't keep relaunching itself
+				log.Debug().Msgf("skipping hooks for event caused by a hook: %s", e.name)
+				continue
+			}
+			hr.fire(e)
+		}
+	}
+}
+
+// event converts a notification to a hook event, and keeps track of the
+// active media system.
+func (hr *hookRunner) event(notif models.Notification) hookEvent {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	e := hookEvent{
+		name:   notif.Method,
+		system: hr.system,
+	}
+
+	switch notif.Method {
+	case models.NotificationStarted:
+		var params models.MediaStartedParams
+		err := json.Unmarshal(notif.Params, &params)
+		if err != nil {
+			log.Error().Err(err).Msg("error parsing media started params")
+		}
+		hr.system = params.SystemID
+		e.system = params.SystemID
+	case models.NotificationStopped:
+		hr.system = ""
+	case models.NotificationReadersConnected, models.NotificationReadersDisconnected:
+		var params models.ReaderResponse
+		err := json.Unmarshal(notif.Params, &params)
+		if err != nil {
+			log.Error().Err(err).Msg("error parsing reader params")
+		}
+		e.driver = params.Driver
+	}
+
+	return e
+}
+
+func matchesSystem(filter []string, id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for _, f := range filter {
+		if strings.EqualFold(f, id) {
+			return true
+		}
+		system, err := systemdefs.LookupSystem(f)
+		if err == nil && strings.EqualFold(system.ID, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hookMatches(h config.HooksEvent, e hookEvent) bool {
+	if h.On != e.name || h.ZapScript == "" {
+		return false
+	}
+
+	if len(h.System) > 0 && !matchesSystem(h.System, e.system) {
+		return false
+	}
+
+	if len(h.Driver) > 0 {
+		if !utils.Contains(driverHookEvents, e.name) {
+			return false
+		}
+		matched := false
+		for _, d := range h.Driver {
+			if e.driver != "" && strings.EqualFold(d, e.driver) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fire adds every hook matching the event to the launch queue. Returns a
+// channel for each hook which is sent its launch result.
+func (hr *hookRunner) fire(e hookEvent) []<-chan tokens.LaunchResult {
+	var results []<-chan tokens.LaunchResult
+
+	for _, h := range hr.cfg.HookEvents() {
+		if !hookMatches(h, e) {
+			continue
+		}
+
+		log.Info().Msgf("running %s hook: %s", e.name, h.ZapScript)
+		resCh := make(chan tokens.LaunchResult, 1)
+		hr.q.push(tokens.Token{
+			ScanTime: time.Now(),
+			Text:     h.ZapScript,
+			Source:   tokens.SourceHook,
+			// shutdown can't be refused
+			IgnoreLimits: e.name == HookServiceStopped,
+			Result:       resCh,
+		}, false)
+		results = append(results, resCh)
+	}
+
+	return results
+}
+
+// shutdown runs the service stopped hooks and waits for them to finish, up
+// to the shutdown timeout.
+func (hr *hookRunner) shutdown() {
+	hr.mu.Lock()
+	system := hr.system
+	hr.mu.Unlock()
+
+	results := hr.fire(hookEvent{
+		name:   HookServiceStopped,
+		system: system,
+	})
+	if len(results) == 0 {
+		return
+	}
+
+	timer := time.NewTimer(config.HookShutdownTimeout)
+	defer timer.Stop()
+
+	for _, resCh := range results {
+		select {
+		case <-timer.C:
+			log.Warn().Msg("timed out waiting for service stopped hooks")
+			return
+		case res := <-resCh:
+			if res.Err != nil {
+				log.Error().Err(res.Err).Msg("error running service stopped hook")
+			}
+		}
+	}
+}
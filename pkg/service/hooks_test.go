@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHookMatches(t *testing.T) {
+	started := hookEvent{name: models.NotificationStarted, system: "SNES"}
+	reader := hookEvent{name: models.NotificationReadersConnected, driver: "pn532_uart"}
+
+	tests := []struct {
+		name  string
+		hook  config.HooksEvent
+		event hookEvent
+		want  bool
+	}{
+		{
+			name:  "any system",
+			hook:  config.HooksEvent{On: models.NotificationStarted, ZapScript: "**stop"},
+			event: started,
+			want:  true,
+		},
+		{
+			name:  "other event",
+			hook:  config.HooksEvent{On: models.NotificationStopped, ZapScript: "**stop"},
+			event: started,
+		},
+		{
+			name:  "no zapscript",
+			hook:  config.HooksEvent{On: models.NotificationStarted},
+			event: started,
+		},
+		{
+			name: "matching system",
+			hook: config.HooksEvent{
+				On:        models.NotificationStarted,
+				System:    []string{"genesis", "snes"},
+				ZapScript: "**stop",
+			},
+			event: started,
+			want:  true,
+		},
+		{
+			name: "other system",
+			hook: config.HooksEvent{
+				On:        models.NotificationStarted,
+				System:    []string{"Genesis"},
+				ZapScript: "**stop",
+			},
+			event: started,
+		},
+		{
+			name: "matching driver",
+			hook: config.HooksEvent{
+				On:        models.NotificationReadersConnected,
+				Driver:    []string{"PN532_UART"},
+				ZapScript: "**stop",
+			},
+			event: reader,
+			want:  true,
+		},
+		{
+			name: "driver filter on tokens removed",
+			hook: config.HooksEvent{
+				On:        models.NotificationTokensRemoved,
+				Driver:    []string{"pn532_uart"},
+				ZapScript: "**stop",
+			},
+			event: hookEvent{name: models.NotificationTokensRemoved, driver: "pn532_uart"},
+		},
+		{
+			name: "driver filter on event with no reader",
+			hook: config.HooksEvent{
+				On:        models.NotificationStarted,
+				Driver:    []string{"pn532_uart"},
+				ZapScript: "**stop",
+			},
+			event: started,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hookMatches(tt.hook, tt.event))
+		})
+	}
+}
+
+func TestHookEventSystem(t *testing.T) {
+	hr := &hookRunner{}
+
+	params, err := json.Marshal(models.MediaStartedParams{SystemID: "SNES"})
+	require.NoError(t, err)
+
+	e := hr.event(models.Notification{
+		Method: models.NotificationStarted,
+		Params: params,
+	})
+	assert.Equal(t, "SNES", e.system)
+
+	// events with no system use the active media's
+	e = hr.event(models.Notification{Method: models.NotificationTokensRemoved})
+	assert.Equal(t, "SNES", e.system)
+
+	e = hr.event(models.Notification{Method: models.NotificationStopped})
+	assert.Equal(t, "SNES", e.system)
+
+	e = hr.event(models.Notification{Method: models.NotificationTokensRemoved})
+	assert.Equal(t, "", e.system)
+}
+
+func TestHookLaunchEvents(t *testing.T) {
+	st, _ := state.NewState(nil)
+	q := newLaunchQueue(st, func(tokens.Token, error) {})
+	hr := &hookRunner{
+		q:      q,
+		events: make(chan hookNotification, hookEventQueueSize),
+	}
+
+	notify := func(method string) bool {
+		hr.notify(models.Notification{Method: method})
+		hn := <-hr.events
+		return hn.hookLaunch
+	}
+
+	q.push(tokens.Token{Text: "**launch.random:snes", Source: tokens.SourceHook}, false)
+	_, _ = q.next(context.Background())
+	assert.True(t, notify(models.NotificationStarted))
+	assert.True(t, notify(models.NotificationStopped))
+	// only media events are caused by launches
+	assert.False(t, notify(models.NotificationTokensRemoved))
+
+	// platforms may report the media after the launch has finished
+	q.done()
+	assert.True(t, notify(models.NotificationStarted))
+	assert.False(t, q.hookLaunching(time.Now().Add(hookEventGrace)))
+
+	q.push(tokens.Token{Text: "**launch.random:snes"}, false)
+	_, _ = q.next(context.Background())
+	assert.False(t, notify(models.NotificationStarted))
+	q.done()
+	assert.False(t, notify(models.NotificationStarted))
+}
+
+func TestHookLimits(t *testing.T) {
+	defaults := config.BaseDefaults
+	defaults.Hooks.Event = []config.HooksEvent{
+		{On: HookServiceStarted, ZapScript: "**launch.random:snes"},
+		{On: HookServiceStopped, ZapScript: "**launch.random:snes"},
+	}
+	cfg, err := config.NewConfig(t.TempDir(), defaults)
+	require.NoError(t, err)
+
+	st, _ := state.NewState(nil)
+	q := newLaunchQueue(st, func(tokens.Token, error) {})
+	hr := newHookRunner(cfg, q)
+
+	hr.fire(hookEvent{name: HookServiceStarted})
+	hr.fire(hookEvent{name: HookServiceStopped})
+
+	started, _ := q.next(context.Background())
+	require.NotNil(t, started)
+	assert.False(t, started.token.IgnoreLimits)
+	q.done()
+
+	stopped, _ := q.next(context.Background())
+	require.NotNil(t, stopped)
+	assert.True(t, stopped.token.IgnoreLimits)
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	widgetModels "github.com/ZaparooProject/zaparoo-core/pkg/configui/widgets/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/systemdefs"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/limits"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
+	zapScriptModels "github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+	"github.com/rs/zerolog/log"
+)
+
+// limitsCheckInterval is how often the running media is checked against
+// the playtime limits.
+const limitsCheckInterval = 10 * time.Second
+
+const (
+	// limitsPinAttempts is the number of wrong override PINs in a row
+	// before further attempts are locked out.
+	limitsPinAttempts = 5
+	// limitsPinLockout is how long the first lockout lasts. Each lockout
+	// after that is twice as long, up to limitsPinMaxLockout.
+	limitsPinLockout    = time.Minute
+	limitsPinMaxLockout = time.Hour
+)
+
+// limitsEnforcer refuses launches and stops running media when a playtime
+// limit is reached, unless limits are currently overridden.
+type limitsEnforcer struct {
+	mu   sync.Mutex
+	pl   platforms.Platform
+	cfg  *config.Instance
+	st   *state.State
+	db   *database.Database
+	sr   *sessionRecorder
+	kill *time.Timer
+	// allowed is the start time of the running session the last time it
+	// passed the limits check.
+	allowed time.Time
+	// pinFailures is the number of wrong override PINs since the last
+	// correct one or lockout, and pinLockouts the number of lockouts since
+	// the last correct PIN.
+	pinFailures int
+	pinLockouts int
+	pinLocked   time.Time
+}
+
+func newLimitsEnforcer(
+	pl platforms.Platform,
+	cfg *config.Instance,
+	st *state.State,
+	db *database.Database,
+	sr *sessionRecorder,
+) *limitsEnforcer {
+	return &limitsEnforcer{
+		pl:  pl,
+		cfg: cfg,
+		st:  st,
+		db:  db,
+		sr:  sr,
+	}
+}
+
+// todaySessions returns all play sessions started today, including the
+// session in progress.
+func (le *limitsEnforcer) todaySessions(now time.Time) []database.Session {
+	sessions, err := le.db.GetSessions(limits.StartOfDay(now), time.Time{})
+	if err != nil {
+		log.Error().Err(err).Msg("error getting play sessions")
+	}
+
+	if s := le.sr.active(); s != nil {
+		s.End = now
+		sessions = append(sessions, *s)
+	}
+
+	return sessions
+}
+
+// rules returns the limits rules currently being enforced, which is none if
+// limits are disabled or overridden.
+func (le *limitsEnforcer) rules(now time.Time) []config.LimitsRule {
+	rules := le.cfg.LimitsRules()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	if now.Before(le.st.LimitsOverride()) {
+		log.Debug().Msg("playtime limits overridden")
+		return nil
+	}
+
+	return rules
+}
+
+// check returns an error if any of the media is not allowed to run right
+// now.
+func (le *limitsEnforcer) check(ms ...limits.Media) error {
+	now := time.Now()
+	rules := le.rules(now)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	sessions := le.todaySessions(now)
+	for _, m := range ms {
+		err := limits.Check(rules, now, m, sessions)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// launcherSystem returns the system ID of the launcher with the given ID.
+func (le *limitsEnforcer) launcherSystem(id string) string {
+	for _, l := range le.pl.Launchers() {
+		if l.Id == id {
+			return l.SystemID
+		}
+	}
+	return ""
+}
+
+// installMedia returns the media an install token downloads and launches.
+func (le *limitsEnforcer) installMedia(args zapScriptModels.CmdLaunchArgs) (limits.Media, error) {
+	path, err := zapscript.MediaInstallPath(le.pl, args)
+	if err != nil {
+		return limits.Media{}, err
+	}
+
+	m := limits.Media{Path: path}
+	system, err := systemdefs.LookupSystem(*args.System)
+	if err == nil {
+		m.SystemID = system.ID
+	}
+	return m, nil
+}
+
+// checkToken returns an error if the token is not allowed to launch right
+// now. Only rules which apply to all media, and the media of install
+// tokens, are checked here. The media launch commands resolve to is checked
+// by checkLaunch as it's launched, so system and path rules refuse the
+// launch instead of stopping the media once it's started.
+func (le *limitsEnforcer) checkToken(t tokens.Token) error {
+	if len(le.rules(time.Now())) == 0 {
+		return nil
+	}
+
+	err := le.check(limits.Media{})
+	if err != nil || t.Install == nil {
+		return err
+	}
+
+	m, err := le.installMedia(*t.Install)
+	if err != nil {
+		log.Debug().Err(err).Msg("error resolving install path for limits")
+		return nil
+	}
+
+	return le.check(m)
+}
+
+// checkLaunch returns an error if the media a launch command resolved to
+// is not allowed to run right now.
+func (le *limitsEnforcer) checkLaunch(path string, launcher string) error {
+	return le.check(limits.Media{
+		SystemID: le.launcherSystem(launcher),
+		Path:     path,
+	})
+}
+
+// isAdminCard returns true if the token was scanned on a reader and is one
+// of the admin cards which override limits.
+func (le *limitsEnforcer) isAdminCard(t tokens.Token) bool {
+	lc := le.cfg.Limits()
+	if !lc.Enabled || t.FromAPI || t.UID == "" {
+		return false
+	}
+
+	uid := database.NormalizeID(t.UID)
+	for _, card := range lc.AdminCard {
+		if database.NormalizeID(card) == uid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// overridePin overrides limits if the PIN matches. After limitsPinAttempts
+// wrong PINs in a row, every attempt is refused until the lockout ends.
+func (le *limitsEnforcer) overridePin(pin string) (time.Time, error) {
+	now := time.Now()
+
+	le.mu.Lock()
+	if now.Before(le.pinLocked) {
+		le.mu.Unlock()
+		return time.Time{}, limits.ErrPinLocked
+	}
+
+	if !le.cfg.IsLimitsPin(pin) {
+		le.pinFailures++
+		if le.pinFailures >= limitsPinAttempts {
+			lockout := limitsPinLockout
+			for i := 0; i < le.pinLockouts && lockout < limitsPinMaxLockout; i++ {
+				lockout *= 2
+			}
+			lockout = min(lockout, limitsPinMaxLockout)
+
+			log.Warn().Msgf("too many invalid limits pins, locked for %s", lockout)
+			le.pinLocked = now.Add(lockout)
+			le.pinFailures = 0
+			le.pinLockouts++
+		}
+		le.mu.Unlock()
+		return time.Time{}, limits.ErrInvalidPin
+	}
+
+	le.pinFailures = 0
+	le.pinLockouts = 0
+	le.mu.Unlock()
+
+	return le.override(), nil
+}
+
+// override turns off limits for the configured override time and cancels
+// any pending stop of the running media.
+func (le *limitsEnforcer) override() time.Time {
+	d := le.cfg.LimitsOverrideDuration()
+	until := time.Now().Add(d)
+	le.st.SetLimitsOverride(until)
+	log.Info().Msgf("playtime limits overridden until: %s", until)
+
+	_, _, err := le.pl.ShowNotice(le.cfg, widgetModels.NoticeArgs{
+		Text:    fmt.Sprintf("Playtime limits off for %d minutes", int(d.Minutes())),
+		Timeout: 5,
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("error showing limits notice")
+	}
+
+	le.enforce()
+	return until
+}
+
+// enforce checks the running media against the limits. If a limit has
+// been reached while playing, a notice is shown and the media is stopped
+// after the grace period. Media which was never allowed to start, such as
+// a blocked system, is stopped straight away. A pending stop is cancelled
+// if the media is allowed again.
+func (le *limitsEnforcer) enforce() {
+	var err error
+	s := le.sr.active()
+	if s != nil {
+		err = le.check(limits.Media{
+			SystemID: s.SystemID,
+			Path:     s.MediaPath,
+		})
+	}
+
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	if err == nil {
+		if s != nil {
+			le.allowed = s.Start
+		}
+		if le.kill != nil && le.kill.Stop() {
+			log.Info().Msg("playtime limit cleared, cancelling stop")
+		}
+		le.kill = nil
+		return
+	} else if le.kill != nil {
+		return
+	}
+
+	grace := le.cfg.LimitsGracePeriod()
+	if !s.Start.Equal(le.allowed) {
+		grace = 0
+	}
+	log.Info().Err(err).Msgf("stopping media in %s", grace)
+
+	notice := widgetModels.NoticeArgs{
+		Text:    fmt.Sprintf("%s.", err),
+		Timeout: 5,
+	}
+	if grace > 0 {
+		notice.Text = fmt.Sprintf("%s. Stopping in %d seconds.", err, int(grace.Seconds()))
+		notice.Timeout = int(grace.Seconds())
+	}
+
+	_, _, nerr := le.pl.ShowNotice(le.cfg, notice)
+	if nerr != nil {
+		log.Warn().Err(nerr).Msg("error showing limits notice")
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(grace, func() {
+		le.mu.Lock()
+		if le.kill != timer {
+			le.mu.Unlock()
+			return
+		}
+		// allow the stop to be retried if the media keeps running
+		le.kill = nil
+		le.mu.Unlock()
+
+		log.Info().Msg("playtime limit reached, stopping media")
+		err := le.pl.KillLauncher()
+		if err != nil {
+			log.Error().Err(err).Msg("error stopping media")
+		}
+	})
+	le.kill = timer
+}
+
+func (le *limitsEnforcer) run(ctx context.Context) {
+	ticker := time.NewTicker(limitsCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			le.enforce()
+		}
+	}
+}
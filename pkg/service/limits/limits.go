@@ -0,0 +1,198 @@
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrLimitReached = errors.New("playtime limit reached")
+	ErrInvalidPin   = errors.New("invalid pin")
+	ErrPinLocked    = errors.New("too many invalid pins, try again later")
+)
+
+// LimitError is returned when media is not allowed to run because of a
+// limits rule.
+type LimitError struct {
+	Reason string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrLimitReached, e.Reason)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitReached
+}
+
+// Media is the media being checked against the limits. Media with no system
+// is a launch which hasn't started yet, and is only checked against rules
+// which apply to all media.
+type Media struct {
+	SystemID string
+	Path     string
+}
+
+// StartOfDay returns local midnight of the given time's day, which is when
+// daily playtime budgets reset.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func parseClock(s string) (int, error) {
+	ps := strings.SplitN(strings.TrimSpace(s), ":", 2)
+	if len(ps) != 2 {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	h, err := strconv.Atoi(ps[0])
+	if err != nil || h < 0 || h > 24 {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	m, err := strconv.Atoi(ps[1])
+	if err != nil || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	return h*60 + m, nil
+}
+
+// inWindow reports whether the time of day falls inside a "HH:MM-HH:MM"
+// window. Windows which end before they start wrap past midnight.
+func inWindow(window string, now time.Time) (bool, error) {
+	ps := strings.SplitN(window, "-", 2)
+	if len(ps) != 2 {
+		return false, fmt.Errorf("invalid time window: %s", window)
+	}
+
+	start, err := parseClock(ps[0])
+	if err != nil {
+		return false, err
+	}
+
+	end, err := parseClock(ps[1])
+	if err != nil {
+		return false, err
+	}
+
+	local := now.Local()
+	mins := local.Hour()*60 + local.Minute()
+
+	if start <= end {
+		return mins >= start && mins < end, nil
+	}
+	return mins >= start || mins < end, nil
+}
+
+func ruleSystems(r config.LimitsRule) string {
+	if len(r.Systems) == 0 {
+		return "all systems"
+	}
+	return strings.Join(r.Systems, ", ")
+}
+
+// applies reports whether the rule covers the given media.
+func applies(r config.LimitsRule, m Media) bool {
+	if len(r.Systems) == 0 {
+		return true
+	}
+
+	for _, s := range r.Systems {
+		if strings.EqualFold(s, m.SystemID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// playtime returns the total playtime of the rule's systems across the
+// given sessions.
+func playtime(r config.LimitsRule, sessions []database.Session) time.Duration {
+	var total time.Duration
+	for _, s := range sessions {
+		if applies(r, Media{SystemID: s.SystemID}) {
+			total += s.Duration()
+		}
+	}
+	return total
+}
+
+// Check returns a LimitError if the media is not allowed to run at the
+// given time under any of the rules. Sessions must be all of today's play
+// sessions, including the one in progress.
+func Check(rules []config.LimitsRule, now time.Time, m Media, sessions []database.Session) error {
+	for _, r := range rules {
+		// rules for specific systems never apply to media with no system,
+		// they're checked again once the media has started
+		if !applies(r, m) {
+			continue
+		}
+
+		if r.Block {
+			return &LimitError{Reason: ruleSystems(r) + " blocked"}
+		}
+
+		if m.Path != "" {
+			for _, pattern := range r.BlockPath {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					log.Error().Err(err).Msgf("invalid limits block path: %s", pattern)
+					continue
+				}
+				if re.MatchString(m.Path) {
+					return &LimitError{Reason: "media blocked"}
+				}
+			}
+		}
+
+		if len(r.AllowedTimes) > 0 {
+			allowed := false
+			for _, w := range r.AllowedTimes {
+				ok, err := inWindow(w, now)
+				if err != nil {
+					log.Error().Err(err).Msg("invalid limits allowed time")
+					continue
+				}
+				if ok {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return &LimitError{
+					Reason: fmt.Sprintf(
+						"%s only allowed %s",
+						ruleSystems(r),
+						strings.Join(r.AllowedTimes, ", "),
+					),
+				}
+			}
+		}
+
+		if r.DailyMinutes != nil {
+			budget := time.Duration(*r.DailyMinutes) * time.Minute
+			if playtime(r, sessions) >= budget {
+				return &LimitError{
+					Reason: fmt.Sprintf(
+						"daily playtime of %d minutes used for %s",
+						*r.DailyMinutes,
+						ruleSystems(r),
+					),
+				}
+			}
+		}
+	}
+
+	return nil
+}
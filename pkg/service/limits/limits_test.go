@@ -0,0 +1,152 @@
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInWindow(t *testing.T) {
+	at := func(h, m int) time.Time {
+		return time.Date(2024, 12, 1, h, m, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		window string
+		now    time.Time
+		want   bool
+	}{
+		{"07:00-19:30", at(7, 0), true},
+		{"07:00-19:30", at(19, 29), true},
+		{"07:00-19:30", at(19, 30), false},
+		{"07:00-19:30", at(6, 59), false},
+		{"22:00-02:00", at(23, 0), true},
+		{"22:00-02:00", at(1, 0), true},
+		{"22:00-02:00", at(12, 0), false},
+		{"00:00-24:00", at(23, 59), true},
+	}
+
+	for _, tt := range tests {
+		got, err := inWindow(tt.window, tt.now)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s at %s", tt.window, tt.now.Format("15:04"))
+	}
+
+	for _, window := range []string{"7-19", "07:00", "25:00-26:00", "07:60-08:00"} {
+		_, err := inWindow(window, at(12, 0))
+		assert.Error(t, err, window)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 12, 1, 18, 0, 0, 0, time.Local)
+	hour := 60
+	fortyFiveMins := 45
+	tenMins := 10
+
+	sessions := []database.Session{
+		{
+			Start:    now.Add(-2 * time.Hour),
+			End:      now.Add(-90 * time.Minute),
+			SystemID: "SNES",
+		},
+		{
+			Start:    now.Add(-time.Hour),
+			End:      now.Add(-45 * time.Minute),
+			SystemID: "Genesis",
+		},
+	}
+
+	snes := Media{SystemID: "SNES", Path: "/games/SNES/Mario.sfc"}
+	genesis := Media{SystemID: "Genesis", Path: "/games/Genesis/Sonic.md"}
+
+	tests := []struct {
+		name  string
+		rules []config.LimitsRule
+		media Media
+		want  bool
+	}{
+		{
+			name:  "no rules",
+			media: snes,
+		},
+		{
+			name:  "blocked system",
+			rules: []config.LimitsRule{{Systems: []string{"snes"}, Block: true}},
+			media: snes,
+			want:  true,
+		},
+		{
+			name:  "other system blocked",
+			rules: []config.LimitsRule{{Systems: []string{"SNES"}, Block: true}},
+			media: genesis,
+		},
+		{
+			name:  "system rule skipped before launch",
+			rules: []config.LimitsRule{{Systems: []string{"SNES"}, Block: true}},
+			media: Media{},
+		},
+		{
+			name:  "blocked path",
+			rules: []config.LimitsRule{{BlockPath: []string{"(?i)sonic"}}},
+			media: genesis,
+			want:  true,
+		},
+		{
+			name:  "outside allowed times",
+			rules: []config.LimitsRule{{AllowedTimes: []string{"07:00-17:00"}}},
+			media: Media{},
+			want:  true,
+		},
+		{
+			name: "inside allowed times",
+			rules: []config.LimitsRule{{
+				AllowedTimes: []string{"07:00-09:00", "16:00-19:00"},
+			}},
+			media: Media{},
+		},
+		{
+			name:  "daily budget used",
+			rules: []config.LimitsRule{{DailyMinutes: &fortyFiveMins}},
+			media: Media{},
+			want:  true,
+		},
+		{
+			name:  "daily budget left",
+			rules: []config.LimitsRule{{DailyMinutes: &hour}},
+			media: Media{},
+		},
+		{
+			name: "system budget left",
+			rules: []config.LimitsRule{{
+				Systems:      []string{"SNES"},
+				DailyMinutes: &hour,
+			}},
+			media: snes,
+		},
+		{
+			name: "system budget used",
+			rules: []config.LimitsRule{{
+				Systems:      []string{"Genesis"},
+				DailyMinutes: &tenMins,
+			}},
+			media: genesis,
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Check(tt.rules, now, tt.media, sessions)
+			if tt.want {
+				assert.ErrorIs(t, err, ErrLimitReached)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
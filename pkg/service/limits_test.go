@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	widgetModels "github.com/ZaparooProject/zaparoo-core/pkg/configui/widgets/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/limits"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type limitsPlatform struct {
+	testPlatform
+}
+
+func (p limitsPlatform) Id() string {
+	return platforms.PlatformIDMister
+}
+
+func (p limitsPlatform) RootDirs(*config.Instance) []string {
+	return []string{"/media/fat/games"}
+}
+
+func (p limitsPlatform) Launchers() []platforms.Launcher {
+	return []platforms.Launcher{
+		{
+			Id:         "SNES",
+			SystemID:   "SNES",
+			Folders:    []string{"SNES"},
+			Extensions: []string{".sfc"},
+			Launch: func(*config.Instance, string) error {
+				return nil
+			},
+		},
+		{
+			Id:         "Genesis",
+			SystemID:   "Genesis",
+			Folders:    []string{"Genesis"},
+			Extensions: []string{".md"},
+		},
+	}
+}
+
+func (p limitsPlatform) LaunchFile(*config.Instance, string) error {
+	return nil
+}
+
+func (p limitsPlatform) GetActiveLauncher() string {
+	return ""
+}
+
+func (p limitsPlatform) LookupMapping(tokens.Token) (string, bool) {
+	return "", false
+}
+
+func (p limitsPlatform) ShowNotice(
+	*config.Instance,
+	widgetModels.NoticeArgs,
+) (func() error, time.Duration, error) {
+	return func() error { return nil }, 0, nil
+}
+
+func testLimitsEnforcer(t *testing.T, lc config.Limits) *limitsEnforcer {
+	defaults := config.BaseDefaults
+	defaults.Limits = lc
+	cfg, err := config.NewConfig(t.TempDir(), defaults)
+	require.NoError(t, err)
+
+	pl := limitsPlatform{testPlatform{dataDir: t.TempDir()}}
+	db, err := database.Open(pl)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+
+	return newLimitsEnforcer(pl, cfg, st, db, newSessionRecorder(st, db))
+}
+
+func TestLimitsCheckToken(t *testing.T) {
+	le := testLimitsEnforcer(t, config.Limits{
+		Enabled: true,
+		Rule: []config.LimitsRule{
+			{Systems: []string{"SNES"}, Block: true},
+		},
+	})
+
+	system := "SNES"
+	url := "https://example.com/game.sfc"
+	install := tokens.Token{
+		Install: &models.CmdLaunchArgs{System: &system, URL: &url},
+	}
+
+	assert.ErrorIs(t, le.checkToken(install), limits.ErrLimitReached)
+
+	// launch commands are checked as they're launched
+	mario := tokens.Token{Text: "/media/fat/games/SNES/mario.sfc"}
+	assert.NoError(t, le.checkToken(mario))
+
+	// overridden limits allow everything
+	le.st.SetLimitsOverride(time.Now().Add(time.Minute))
+	assert.NoError(t, le.checkToken(install))
+}
+
+func TestLimitsCheckLaunch(t *testing.T) {
+	le := testLimitsEnforcer(t, config.Limits{
+		Enabled: true,
+		Rule: []config.LimitsRule{
+			{Systems: []string{"SNES"}, Block: true},
+			{BlockPath: []string{"(?i)sonic"}},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		text    string
+		blocked bool
+	}{
+		{
+			name:    "blocked_system",
+			text:    "/media/fat/games/SNES/mario.sfc",
+			blocked: true,
+		},
+		{
+			name: "allowed_system",
+			text: "/media/fat/games/Genesis/streets.md",
+		},
+		{
+			name:    "blocked_path",
+			text:    "/media/fat/games/Genesis/sonic.md",
+			blocked: true,
+		},
+		{
+			name:    "blocked_launcher",
+			text:    "/media/fat/games/Genesis/streets.md?launcher=SNES",
+			blocked: true,
+		},
+		{
+			name:    "blocked_second_command",
+			text:    "**delay:100||/media/fat/games/SNES/mario.sfc",
+			blocked: true,
+		},
+		{
+			name: "not_media",
+			text: "**delay:100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := launchToken(
+				context.Background(),
+				le.pl,
+				le.cfg,
+				le.st.Notifications,
+				tokens.Token{Text: tt.text, FromAPI: true},
+				nil,
+				le.db,
+				make(chan *tokens.Token, 1),
+				playlists.PlaylistController{Queue: make(chan *playlists.Playlist, 2)},
+				nil,
+				le.checkLaunch,
+			)
+			if tt.blocked {
+				assert.ErrorIs(t, err, limits.ErrLimitReached)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLimitsOverridePin(t *testing.T) {
+	le := testLimitsEnforcer(t, config.Limits{
+		Enabled: true,
+		Pin:     "1234",
+		Rule:    []config.LimitsRule{{Block: true}},
+	})
+
+	for i := 0; i < limitsPinAttempts; i++ {
+		_, err := le.overridePin("0000")
+		assert.ErrorIs(t, err, limits.ErrInvalidPin)
+	}
+
+	// the correct pin is refused during a lockout
+	_, err := le.overridePin("1234")
+	assert.ErrorIs(t, err, limits.ErrPinLocked)
+	assert.True(t, le.st.LimitsOverride().IsZero())
+
+	// each lockout is longer than the last
+	first := le.pinLocked
+	le.pinLocked = time.Time{}
+	for i := 0; i < limitsPinAttempts; i++ {
+		_, err := le.overridePin("0000")
+		assert.ErrorIs(t, err, limits.ErrInvalidPin)
+	}
+	assert.Greater(t, time.Until(le.pinLocked), time.Until(first))
+
+	le.pinLocked = time.Time{}
+	until, err := le.overridePin("1234")
+	require.NoError(t, err)
+	assert.Equal(t, until, le.st.LimitsOverride())
+	assert.Zero(t, le.pinFailures)
+	assert.Zero(t, le.pinLockouts)
+}
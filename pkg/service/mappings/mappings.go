@@ -19,7 +19,7 @@ You should have received a copy of the GNU General Public License
 along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 */
 
-package service
+package mappings
 
 import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
@@ -29,6 +29,7 @@ import (
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -133,39 +134,153 @@ func mappingsFromConfig(cfg *config.Instance) []database.Mapping {
 	return mappings
 }
 
-func getMapping(cfg *config.Instance, db *database.Database, pl platforms.Platform, token tokens.Token) (string, bool) {
-	// TODO: need a way to identify the source of a match so it can be
-	// reported and debugged by the user if there's issues
+const (
+	SourceDatabase = "database"
+	SourceConfig   = "config"
+	SourcePlatform = "platform"
+)
+
+// Match is a mapping which matched a token, and where it came from.
+type Match struct {
+	Source  string
+	Mapping database.Mapping
+}
+
+func checkMapping(m database.Mapping, t tokens.Token) bool {
+	switch {
+	case m.Type == database.MappingTypeUID:
+		return checkMappingUid(m, t)
+	case m.Type == database.MappingTypeText:
+		return checkMappingText(m, t)
+	case m.Type == database.MappingTypeData:
+		return checkMappingData(m, t)
+	}
+	return false
+}
+
+// matchComboParts reports whether every part can be matched by a different
+// token in ts.
+func matchComboParts(m database.Mapping, parts []database.ComboPart, ts []tokens.Token, used []bool) bool {
+	if len(parts) == 0 {
+		return true
+	}
+
+	pm := m
+	pm.Type = parts[0].Type
+	pm.Pattern = parts[0].Pattern
+
+	for i, t := range ts {
+		if used[i] || !checkMapping(pm, t) {
+			continue
+		}
+		used[i] = true
+		if matchComboParts(m, parts[1:], ts, used) {
+			return true
+		}
+		used[i] = false
+	}
+
+	return false
+}
+
+// checkComboMapping reports whether a combo mapping matches the given token
+// together with the other tokens currently active on readers. The token
+// must match one of the combo parts, and each other part must be matched by
+// a different active token.
+func checkComboMapping(m database.Mapping, t tokens.Token, active []tokens.Token) bool {
+	parts, err := database.ParseComboPattern(m.Pattern)
+	if err != nil {
+		log.Error().Err(err).Msgf("error parsing combo mapping")
+		return false
+	}
+
+	others := make([]tokens.Token, 0, len(active))
+	for _, a := range active {
+		if !utils.TokensEqual(&a, &t) {
+			others = append(others, a)
+		}
+	}
+
+	if len(others) < len(parts)-1 {
+		return false
+	}
+
+	for i, p := range parts {
+		pm := m
+		pm.Type = p.Type
+		pm.Pattern = p.Pattern
+		if !checkMapping(pm, t) {
+			continue
+		}
+
+		rest := make([]database.ComboPart, 0, len(parts)-1)
+		rest = append(rest, parts[:i]...)
+		rest = append(rest, parts[i+1:]...)
+		if matchComboParts(m, rest, others, make([]bool, len(others))) {
+			return true
+		}
+	}
+
+	return false
+}
 
+// Get returns the mapping which should be used to launch a token. Combo
+// mappings are checked first, against the other tokens currently active on
+// readers.
+func Get(
+	cfg *config.Instance,
+	db *database.Database,
+	pl platforms.Platform,
+	token tokens.Token,
+	active []tokens.Token,
+) (Match, bool) {
 	// check db mappings
 	ms, err := db.GetEnabledMappings()
 	if err != nil {
 		log.Error().Err(err).Msgf("error getting db mappings")
 	}
 
-	// load config mappings after
-	ms = append(ms, mappingsFromConfig(cfg)...)
+	for _, m := range ms {
+		if m.Type == database.MappingTypeCombo && checkComboMapping(m, token, active) {
+			log.Info().Msgf("launching with db combo match override")
+			return Match{
+				Source:  SourceDatabase,
+				Mapping: m,
+			}, true
+		}
+	}
 
 	for _, m := range ms {
-		switch {
-		case m.Type == database.MappingTypeUID:
-			if checkMappingUid(m, token) {
-				log.Info().Msg("launching with db/cfg uid match override")
-				return m.Override, true
-			}
-		case m.Type == database.MappingTypeText:
-			if checkMappingText(m, token) {
-				log.Info().Msg("launching with db/cfg text match override")
-				return m.Override, true
-			}
-		case m.Type == database.MappingTypeData:
-			if checkMappingData(m, token) {
-				log.Info().Msg("launching with db/cfg data match override")
-				return m.Override, true
-			}
+		if checkMapping(m, token) {
+			log.Info().Msgf("launching with db %s match override", m.Type)
+			return Match{
+				Source:  SourceDatabase,
+				Mapping: m,
+			}, true
+		}
+	}
+
+	// load config mappings after
+	for _, m := range mappingsFromConfig(cfg) {
+		if checkMapping(m, token) {
+			log.Info().Msgf("launching with cfg %s match override", m.Type)
+			return Match{
+				Source:  SourceConfig,
+				Mapping: m,
+			}, true
 		}
 	}
 
 	// check platform mappings
-	return pl.LookupMapping(token)
+	text, ok := pl.LookupMapping(token)
+	if !ok {
+		return Match{}, false
+	}
+
+	return Match{
+		Source: SourcePlatform,
+		Mapping: database.Mapping{
+			Override: text,
+		},
+	}, true
 }
@@ -0,0 +1,49 @@
+package mappings
+
+import (
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckComboMapping(t *testing.T) {
+	p1 := tokens.Token{UID: "04:AA:BB", Text: "**launch.system:snes"}
+	p2 := tokens.Token{UID: "04:CC:DD", Text: "player2"}
+	p3 := tokens.Token{UID: "04:EE:FF", Text: "player3"}
+
+	exact := database.Mapping{
+		Type:    database.MappingTypeCombo,
+		Match:   database.MatchTypeExact,
+		Pattern: "uid:04aabb&&text:player2",
+	}
+	partial := database.Mapping{
+		Type:    database.MappingTypeCombo,
+		Match:   database.MatchTypePartial,
+		Pattern: "text:player&&text:player",
+	}
+
+	tests := []struct {
+		name    string
+		mapping database.Mapping
+		token   tokens.Token
+		active  []tokens.Token
+		want    bool
+	}{
+		{"first token of combo", exact, p1, []tokens.Token{p1, p2}, true},
+		{"second token of combo", exact, p2, []tokens.Token{p1, p2}, true},
+		{"extra tokens ignored", exact, p1, []tokens.Token{p3, p1, p2}, true},
+		{"missing token", exact, p1, []tokens.Token{p1, p3}, false},
+		{"only token", exact, p1, []tokens.Token{p1}, false},
+		{"token not in combo", exact, p3, []tokens.Token{p1, p2, p3}, false},
+		{"parts need different tokens", partial, p2, []tokens.Token{p1, p2}, false},
+		{"parts matched by different tokens", partial, p2, []tokens.Token{p2, p3}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, checkComboMapping(tt.mapping, tt.token, tt.active))
+		})
+	}
+}
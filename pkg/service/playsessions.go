@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/rs/zerolog/log"
+)
+
+// sessionEventQueueSize is the number of media events queued before new
+// ones are dropped.
+const sessionEventQueueSize = 10
+
+type sessionEvent struct {
+	time  time.Time
+	notif models.Notification
+}
+
+// sessionRecorder records play sessions to the database. It's fed media
+// started and stopped notifications, which come from both the platform
+// trackers and updates to the active media in state.
+type sessionRecorder struct {
+	mu      sync.Mutex
+	st      *state.State
+	db      *database.Database
+	current *database.Session
+	events  chan sessionEvent
+	// started is called after a new session has started, if set.
+	started func()
+}
+
+func newSessionRecorder(st *state.State, db *database.Database) *sessionRecorder {
+	return &sessionRecorder{
+		st:     st,
+		db:     db,
+		events: make(chan sessionEvent, sessionEventQueueSize),
+	}
+}
+
+// notify is an API notification handler. It never blocks.
+func (r *sessionRecorder) notify(notif models.Notification) {
+	if notif.Method != models.NotificationStarted &&
+		notif.Method != models.NotificationStopped {
+		return
+	}
+
+	select {
+	case r.events <- sessionEvent{time: time.Now(), notif: notif}:
+	default:
+		log.Warn().Msgf("session event queue full, dropping: %s", notif.Method)
+	}
+}
+
+// run processes media events until the context is done, then ends the
+// current session.
+func (r *sessionRecorder) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			r.stop(time.Now())
+			return
+		case e := <-r.events:
+			r.handle(e)
+		}
+	}
+}
+
+func (r *sessionRecorder) handle(e sessionEvent) {
+	switch e.notif.Method {
+	case models.NotificationStopped:
+		r.stop(e.time)
+	case models.NotificationStarted:
+		var params models.MediaStartedParams
+		err := json.Unmarshal(e.notif.Params, &params)
+		if err != nil {
+			log.Error().Err(err).Msg("error parsing media started params")
+			return
+		}
+		r.start(e.time, params)
+		if r.started != nil {
+			r.started()
+		}
+	}
+}
+
+// start ends the current session, if any, and starts a new one for the
+// given media. The new session is attributed to the token which last
+// launched software.
+func (r *sessionRecorder) start(now time.Time, media models.MediaStartedParams) {
+	r.stop(now)
+
+	s := &database.Session{
+		Start:      now,
+		SystemID:   media.SystemID,
+		SystemName: media.SystemName,
+		MediaPath:  media.MediaPath,
+		MediaName:  media.MediaName,
+	}
+
+	if t := r.st.GetSoftwareToken(); t != nil {
+		s.Token = &database.SessionToken{
+			Type: t.Type,
+			UID:  t.UID,
+			Text: t.Text,
+			Data: t.Data,
+		}
+	}
+
+	r.mu.Lock()
+	r.current = s
+	r.mu.Unlock()
+}
+
+// stop ends and saves the current session, if any.
+func (r *sessionRecorder) stop(now time.Time) {
+	r.mu.Lock()
+	s := r.current
+	r.current = nil
+	r.mu.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	s.End = now
+	log.Debug().Msgf("play session ended: %s (%s)", s.MediaName, s.Duration())
+
+	err := r.db.AddSession(*s)
+	if err != nil {
+		log.Error().Err(err).Msg("error saving play session")
+	}
+}
+
+// active returns a copy of the session in progress, if any.
+func (r *sessionRecorder) active() *database.Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil {
+		return nil
+	}
+	s := *r.current
+	return &s
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPlatform struct {
+	platforms.Platform
+	dataDir string
+}
+
+func (p testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func startedEvent(t *testing.T, at time.Time, path string) sessionEvent {
+	params, err := json.Marshal(models.MediaStartedParams{
+		SystemID:   "SNES",
+		SystemName: "Super Nintendo",
+		MediaPath:  path,
+		MediaName:  path,
+	})
+	require.NoError(t, err)
+	return sessionEvent{
+		time: at,
+		notif: models.Notification{
+			Method: models.NotificationStarted,
+			Params: params,
+		},
+	}
+}
+
+func TestSessionRecorder(t *testing.T) {
+	db, err := database.Open(testPlatform{dataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+	st.SetSoftwareToken(&tokens.Token{UID: "04aabb", Text: "mario.sfc"})
+
+	r := newSessionRecorder(st, db)
+	start := time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC)
+
+	// starting new media ends the previous session
+	r.handle(startedEvent(t, start, "mario.sfc"))
+	r.handle(startedEvent(t, start.Add(10*time.Minute), "zelda.sfc"))
+
+	active := r.active()
+	require.NotNil(t, active)
+	assert.Equal(t, "zelda.sfc", active.MediaPath)
+	assert.True(t, start.Add(10*time.Minute).Equal(active.Start))
+
+	r.handle(sessionEvent{
+		time:  start.Add(40 * time.Minute),
+		notif: models.Notification{Method: models.NotificationStopped},
+	})
+	assert.Nil(t, r.active())
+
+	// a stop with nothing playing saves nothing
+	r.handle(sessionEvent{
+		time:  start.Add(time.Hour),
+		notif: models.Notification{Method: models.NotificationStopped},
+	})
+
+	sessions, err := db.GetSessions(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	assert.Equal(t, "mario.sfc", sessions[0].MediaPath)
+	assert.True(t, start.Equal(sessions[0].Start))
+	assert.Equal(t, 10*time.Minute, sessions[0].Duration())
+	require.NotNil(t, sessions[0].Token)
+	assert.Equal(t, "04aabb", sessions[0].Token.UID)
+
+	assert.Equal(t, "zelda.sfc", sessions[1].MediaPath)
+	assert.True(t, start.Add(10*time.Minute).Equal(sessions[1].Start))
+	assert.Equal(t, 30*time.Minute, sessions[1].Duration())
+}
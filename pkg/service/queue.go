@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLaunchSuperseded = errors.New("launch superseded by newer token")
+	ErrLaunchCancelled  = errors.New("launch cancelled")
+)
+
+// launchJob is a token waiting to be launched, or being launched, by the
+// launch worker.
+type launchJob struct {
+	token   tokens.Token
+	queued  time.Time
+	started time.Time
+}
+
+func (j *launchJob) response() models.LaunchJobResponse {
+	resp := models.LaunchJobResponse{
+		Type:   j.token.Type,
+		UID:    j.token.UID,
+		Text:   j.token.Text,
+		Source: j.token.Source,
+		Queued: j.queued,
+	}
+	if j.token.RunID != uuid.Nil {
+		id := j.token.RunID
+		resp.RunID = &id
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		resp.Started = &started
+	}
+	return resp
+}
+
+// launchQueue runs tokens one at a time, in the order they were added.
+// Each launch gets its own context, which is cancelled if the launch is
+// superseded by a newer token so long running commands can stop early.
+type launchQueue struct {
+	mu      sync.Mutex
+	st      *state.State
+	pending []*launchJob
+	current *launchJob
+	cancel  context.CancelCauseFunc
+	wake    chan struct{}
+	// hookDone is when the last job finished if it was a hook, otherwise
+	// it's zero.
+	hookDone time.Time
+	// dropped is called with every pending job removed from the queue
+	// before it was launched.
+	dropped func(tokens.Token, error)
+}
+
+func newLaunchQueue(st *state.State, dropped func(tokens.Token, error)) *launchQueue {
+	q := &launchQueue{
+		st:      st,
+		wake:    make(chan struct{}, 1),
+		dropped: dropped,
+	}
+	st.SetLaunchCanceller(func() {
+		q.cancelAll(ErrLaunchCancelled)
+	})
+	q.update()
+	return q
+}
+
+// update publishes the current queue status to the state. Must be called
+// with the lock held.
+func (q *launchQueue) update() {
+	status := models.RunQueueResponse{
+		Pending: make([]models.LaunchJobResponse, 0, len(q.pending)),
+	}
+	if q.current != nil {
+		current := q.current.response()
+		status.Current = &current
+	}
+	for _, j := range q.pending {
+		status.Pending = append(status.Pending, j.response())
+	}
+	q.st.SetLaunchQueue(status)
+}
+
+// cancelAll cancels the in-flight launch and drops all pending launches,
+// reporting them with the given cause.
+func (q *launchQueue) cancelAll(cause error) {
+	q.mu.Lock()
+	dropped := q.pending
+	q.pending = nil
+	if q.cancel != nil {
+		q.cancel(cause)
+	}
+	q.update()
+	q.mu.Unlock()
+
+	for _, j := range dropped {
+		q.dropped(j.token, cause)
+	}
+}
+
+// push adds a token to the end of the queue. If supersede is true, the
+// in-flight launch is cancelled and all pending launches are dropped first.
+func (q *launchQueue) push(t tokens.Token, supersede bool) {
+	if supersede {
+		q.cancelAll(ErrLaunchSuperseded)
+	}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, &launchJob{
+		token:  t,
+		queued: time.Now(),
+	})
+	q.update()
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until a job is pending and returns it as the current job,
+// along with the context for its launch. Returns nil when the parent
+// context is done.
+func (q *launchQueue) next(ctx context.Context) (*launchJob, context.Context) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			job := q.pending[0]
+			q.pending = q.pending[1:]
+			job.started = time.Now()
+
+			jobCtx, cancel := context.WithCancelCause(ctx)
+			q.current = job
+			q.cancel = cancel
+			q.update()
+			q.mu.Unlock()
+
+			return job, jobCtx
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-q.wake:
+		}
+	}
+}
+
+// hookLaunching returns true if a hook is being launched, or the last
+// launch was a hook which finished within hookEventGrace, so events it
+// caused may still be arriving.
+func (q *launchQueue) hookLaunching(now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.current != nil {
+		return q.current.token.Source == tokens.SourceHook
+	}
+	return now.Sub(q.hookDone) < hookEventGrace
+}
+
+// done marks the current job as finished.
+func (q *launchQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cancel != nil {
+		q.cancel(nil)
+	}
+	q.hookDone = time.Time{}
+	if q.current != nil && q.current.token.Source == tokens.SourceHook {
+		q.hookDone = time.Now()
+	}
+	q.current = nil
+	q.cancel = nil
+	q.update()
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaunchQueueOrder(t *testing.T) {
+	st, _ := state.NewState(nil)
+	q := newLaunchQueue(st, func(tokens.Token, error) {})
+
+	q.push(tokens.Token{Text: "a"}, false)
+	q.push(tokens.Token{Text: "b"}, false)
+
+	status := st.LaunchQueue()
+	assert.Nil(t, status.Current)
+	require.Len(t, status.Pending, 2)
+
+	job, _ := q.next(context.Background())
+	require.NotNil(t, job)
+	assert.Equal(t, "a", job.token.Text)
+
+	status = st.LaunchQueue()
+	require.NotNil(t, status.Current)
+	assert.Equal(t, "a", status.Current.Text)
+	assert.NotNil(t, status.Current.Started)
+	assert.Len(t, status.Pending, 1)
+
+	q.done()
+	job, _ = q.next(context.Background())
+	require.NotNil(t, job)
+	assert.Equal(t, "b", job.token.Text)
+}
+
+func TestLaunchQueueSupersede(t *testing.T) {
+	st, _ := state.NewState(nil)
+
+	var dropped []string
+	q := newLaunchQueue(st, func(tok tokens.Token, err error) {
+		assert.True(t, errors.Is(err, ErrLaunchSuperseded))
+		dropped = append(dropped, tok.Text)
+	})
+
+	q.push(tokens.Token{Text: "a"}, false)
+	_, ctx := q.next(context.Background())
+	q.push(tokens.Token{Text: "b"}, false)
+
+	q.push(tokens.Token{Text: "c"}, true)
+
+	require.Error(t, ctx.Err())
+	assert.ErrorIs(t, context.Cause(ctx), ErrLaunchSuperseded)
+	assert.Equal(t, []string{"b"}, dropped)
+
+	q.done()
+	job, _ := q.next(context.Background())
+	require.NotNil(t, job)
+	assert.Equal(t, "c", job.token.Text)
+}
+
+func TestLaunchQueueCancel(t *testing.T) {
+	st, _ := state.NewState(nil)
+	q := newLaunchQueue(st, func(tokens.Token, error) {})
+
+	q.push(tokens.Token{Text: "a"}, false)
+	_, ctx := q.next(context.Background())
+
+	st.CancelLaunch()
+	assert.ErrorIs(t, context.Cause(ctx), ErrLaunchCancelled)
+}
+
+func TestLaunchQueueNextDone(t *testing.T) {
+	st, _ := state.NewState(nil)
+	q := newLaunchQueue(st, func(tokens.Token, error) {})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	job, _ := q.next(ctx)
+	assert.Nil(t, job)
+}
+
+func TestSupersedes(t *testing.T) {
+	tests := []struct {
+		name  string
+		token tokens.Token
+		want  bool
+	}{
+		{
+			name:  "reader",
+			token: tokens.Token{Text: "a", Source: "pn532_uart:/dev/ttyUSB0"},
+			want:  true,
+		},
+		{
+			name:  "api",
+			token: tokens.Token{Text: "a", FromAPI: true},
+			want:  true,
+		},
+		{
+			name:  "schedule",
+			token: tokens.Token{Text: "a", Source: tokens.SourceSchedule, FromAPI: true},
+		},
+		{
+			name:  "gmc_proxy",
+			token: tokens.Token{Text: "a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, supersedes(tt.token))
+		})
+	}
+}
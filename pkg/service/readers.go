@@ -1,13 +1,13 @@
 package service
 
 import (
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database/systemdefs"
-	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
@@ -18,11 +18,11 @@ import (
 )
 
 func shouldExit(
-	cfg *config.Instance,
+	scan config.ReadersScan,
 	pl platforms.Platform,
 	st *state.State,
 ) bool {
-	if !cfg.HoldModeEnabled() {
+	if scan.Mode != config.ScanModeHold {
 		return false
 	}
 
@@ -35,13 +35,21 @@ func shouldExit(
 		return false
 	}
 
-	if inExitGameBlocklist(pl, cfg) {
+	if inExitGameBlocklist(pl, scan.IgnoreSystem) {
 		return false
 	}
 
 	return true
 }
 
+// readerScanState is the duplicate suppression and hold mode state of a
+// single reader, so readers with different scan modes don't interfere with
+// each other.
+type readerScanState struct {
+	prevToken *tokens.Token
+	exitTimer *time.Timer
+}
+
 type toConnectDevice struct {
 	connectionString string
 	device           config.ReadersConnect
@@ -66,7 +74,7 @@ func connectReaders(
 	for _, device := range cfg.Readers().Connect {
 		if !utils.Contains(rs, device.ConnectionString()) &&
 			!utils.Contains(toConnectStrs(), device.ConnectionString()) {
-			log.Debug().Msgf("config device not connected, adding: %s", device)
+			log.Debug().Msgf("config device not connected, adding: %s", device.ConnectionString())
 			toConnect = append(toConnect, toConnectDevice{
 				connectionString: device.ConnectionString(),
 				device:           device,
@@ -81,13 +89,13 @@ func connectReaders(
 			for _, r := range pl.SupportedReaders(cfg) {
 				ids := r.Ids()
 				if utils.Contains(ids, rt) {
-					log.Debug().Msgf("connecting to reader: %s", device)
+					log.Debug().Msgf("connecting to reader: %s", device.connectionString)
 					err := r.Open(device.device, iq)
 					if err != nil {
 						log.Error().Msgf("error opening reader: %s", err)
 					} else {
 						st.SetReader(device.connectionString, r)
-						log.Info().Msgf("opened reader: %s", device)
+						log.Info().Msgf("opened reader: %s", device.connectionString)
 						break
 					}
 				}
@@ -152,15 +160,22 @@ func readerManager(
 	db *database.Database,
 	itq chan<- tokens.Token,
 	lsq chan *tokens.Token,
-	plq chan *playlists.Playlist,
+	q *launchQueue,
 ) {
 	scanQueue := make(chan readers.Scan)
 
 	var err error
 	var lastError time.Time
 
-	var prevToken *tokens.Token
-	var exitTimer *time.Timer
+	sources := make(map[string]*readerScanState)
+	sourceState := func(source string) *readerScanState {
+		rs, ok := sources[source]
+		if !ok {
+			rs = &readerScanState{}
+			sources[source] = rs
+		}
+		return rs
+	}
 
 	readerTicker := time.NewTicker(1 * time.Second)
 	stopService := make(chan bool)
@@ -171,24 +186,25 @@ func readerManager(
 		}
 	}
 
-	startTimedExit := func() {
+	startTimedExit := func(source string, rs *readerScanState) {
 		// TODO: this should be moved to processTokenQueue
 
-		if exitTimer != nil {
-			stopped := exitTimer.Stop()
+		if rs.exitTimer != nil {
+			stopped := rs.exitTimer.Stop()
 			if stopped {
-				log.Info().Msg("cancelling previous exit timer")
+				log.Info().Msgf("cancelling previous exit timer: %s", source)
 			}
 		}
 
-		timerLen := time.Second * time.Duration(cfg.ReadersScan().ExitDelay)
+		timerLen := time.Second * time.Duration(cfg.ReaderScan(source).ExitDelay)
 		log.Debug().Msgf("exit timer set to: %s seconds", timerLen)
-		exitTimer = time.NewTimer(timerLen)
+		timer := time.NewTimer(timerLen)
+		rs.exitTimer = timer
 
 		go func() {
-			<-exitTimer.C
+			<-timer.C
 
-			if !cfg.HoldModeEnabled() {
+			if cfg.ReaderScan(source).Mode != config.ScanModeHold {
 				log.Debug().Msg("exit timer expired, but hold mode disabled")
 				return
 			}
@@ -217,17 +233,26 @@ func readerManager(
 					defaults, ok := cfg.LookupSystemDefaults(systemId)
 					if ok && defaults.BeforeExit != "" {
 						log.Info().Msgf("running on remove script: %s", defaults.BeforeExit)
-						plsc := playlists.PlaylistController{
-							Active: st.GetActivePlaylist(),
-							Queue:  plq,
-						}
-						t := tokens.Token{
+						resCh := make(chan tokens.LaunchResult, 1)
+						q.push(tokens.Token{
 							ScanTime: time.Now(),
 							Text:     defaults.BeforeExit,
-						}
-						err := launchToken(pl, cfg, t, db, lsq, plsc)
-						if err != nil {
-							log.Error().Msgf("error launching on remove script: %s", err)
+							Source:   tokens.SourceHook,
+							// exiting media stops play, so it's always allowed
+							IgnoreLimits: true,
+							Result:       resCh,
+						}, false)
+
+						select {
+						case <-st.GetContext().Done():
+							return
+						case res := <-resCh:
+							if errors.Is(res.Err, ErrLaunchSuperseded) {
+								log.Info().Msg("on remove script superseded, not exiting")
+								return
+							} else if res.Err != nil {
+								log.Error().Msgf("error launching on remove script: %s", res.Err)
+							}
 						}
 						break
 					}
@@ -241,7 +266,10 @@ func readerManager(
 				log.Warn().Msgf("error killing launcher: %s", err)
 			}
 
-			lsq <- nil
+			select {
+			case lsq <- nil:
+			case <-st.GetContext().Done():
+			}
 		}()
 	}
 
@@ -273,6 +301,7 @@ func readerManager(
 	isStopped := false
 	for !isStopped {
 		var scan *tokens.Token
+		var source string
 
 		select {
 		case <-st.GetContext().Done():
@@ -288,13 +317,19 @@ func readerManager(
 				continue
 			}
 			scan = t.Token
+			source = t.Source
 		case stoken := <-lsq:
 			// a token has been launched that starts software
 			log.Debug().Msgf("new software token: %v", st)
 
-			if exitTimer != nil && !utils.TokensEqual(stoken, st.GetSoftwareToken()) {
-				if stopped := exitTimer.Stop(); stopped {
-					log.Info().Msg("different software token inserted, cancelling exit")
+			if !utils.TokensEqual(stoken, st.GetSoftwareToken()) {
+				for s, rs := range sources {
+					if rs.exitTimer == nil {
+						continue
+					}
+					if stopped := rs.exitTimer.Stop(); stopped {
+						log.Info().Msgf("different software token inserted, cancelling exit: %s", s)
+					}
 				}
 			}
 
@@ -302,15 +337,18 @@ func readerManager(
 			continue
 		}
 
-		if utils.TokensEqual(scan, prevToken) {
-			log.Debug().Msg("ignoring duplicate scan")
+		rs := sourceState(source)
+
+		if utils.TokensEqual(scan, rs.prevToken) {
+			log.Debug().Msgf("ignoring duplicate scan: %s", source)
 			continue
 		}
 
-		prevToken = scan
+		rs.prevToken = scan
 
 		if scan != nil {
 			log.Info().Msgf("new token scanned: %v", scan)
+			st.SetReaderToken(source, scan)
 			st.SetActiveCard(*scan)
 
 			if !st.RunZapScriptEnabled() {
@@ -318,14 +356,14 @@ func readerManager(
 				continue
 			}
 
-			if exitTimer != nil {
-				stopped := exitTimer.Stop()
+			if rs.exitTimer != nil {
+				stopped := rs.exitTimer.Stop()
 				if stopped && utils.TokensEqual(scan, st.GetSoftwareToken()) {
 					log.Info().Msg("same token reinserted, cancelling exit")
 					continue
 				} else if stopped {
 					log.Info().Msg("new token inserted, restarting exit timer")
-					startTimedExit()
+					startTimedExit(source, rs)
 				}
 			}
 
@@ -340,12 +378,17 @@ func readerManager(
 
 			log.Info().Msgf("sending token: %v", scan)
 			pl.PlaySuccessSound(cfg)
-			itq <- *scan
+			select {
+			case itq <- *scan:
+			case <-st.GetContext().Done():
+				isStopped = true
+			}
 		} else {
 			log.Info().Msg("token was removed")
+			st.SetReaderToken(source, nil)
 			st.SetActiveCard(tokens.Token{})
-			if shouldExit(cfg, pl, st) {
-				startTimedExit()
+			if shouldExit(cfg.ReaderScan(source), pl, st) {
+				startTimedExit(source, rs)
 			}
 		}
 	}
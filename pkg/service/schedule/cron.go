@@ -0,0 +1,197 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSearch is how far ahead Next looks for a matching time before giving
+// up, for specs which can never match such as the 31st of February.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Spec is a parsed schedule. It's either a standard 5 field cron
+// expression ("minute hour day-of-month month day-of-week") in local time,
+// or a fixed interval from "@every <duration>".
+type Spec struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// domAny and dowAny are set if the field was "*", which changes how the
+	// day fields are combined.
+	domAny bool
+	dowAny bool
+	every  time.Duration
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseField parses a single cron field into a bit set of allowed values.
+// Fields are a comma separated list of "*", "N" or "N-M", each optionally
+// followed by "/step".
+func parseField(s string, f field) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid %s step: %s", f.name, part)
+			}
+		}
+
+		start, end := f.min, f.max
+		if rng != "*" {
+			lo, hi, isRange := strings.Cut(rng, "-")
+
+			var err error
+			start, err = strconv.Atoi(lo)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s: %s", f.name, part)
+			}
+
+			end = start
+			if isRange {
+				end, err = strconv.Atoi(hi)
+				if err != nil {
+					return 0, fmt.Errorf("invalid %s: %s", f.name, part)
+				}
+			} else if hasStep {
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("%s out of range: %s", f.name, part)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+// Parse parses a schedule spec. Besides 5 field cron expressions, the
+// descriptors @yearly, @monthly, @weekly, @daily, @midnight, @hourly and
+// "@every <duration>" are supported.
+func Parse(spec string) (*Spec, error) {
+	spec = strings.TrimSpace(spec)
+
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+		if d < time.Minute {
+			return nil, fmt.Errorf("interval must be at least 1 minute: %s", d)
+		}
+		return &Spec{every: d}, nil
+	}
+
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	ps := strings.Fields(spec)
+	if len(ps) != len(fields) {
+		return nil, fmt.Errorf("expected %d fields: %s", len(fields), spec)
+	}
+
+	vals := make([]uint64, len(fields))
+	for i, f := range fields {
+		bits, err := parseField(ps[i], f)
+		if err != nil {
+			return nil, err
+		}
+		vals[i] = bits
+	}
+
+	// 7 is also sunday
+	if vals[4]&(1<<7) != 0 {
+		vals[4] |= 1
+	}
+
+	return &Spec{
+		minute: vals[0],
+		hour:   vals[1],
+		dom:    vals[2],
+		month:  vals[3],
+		dow:    vals[4],
+		domAny: ps[2] == "*",
+		dowAny: ps[4] == "*",
+	}, nil
+}
+
+func has(bits uint64, i int) bool {
+	return bits&(1<<uint(i)) != 0
+}
+
+// dayMatches follows the usual cron rule: if both day fields are
+// restricted, a day matching either one is allowed.
+func (s *Spec) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first scheduled time after the given time. Returns the
+// zero time if the spec never matches.
+func (s *Spec) Next(after time.Time) time.Time {
+	if s.every > 0 {
+		return after.Add(s.every)
+	}
+
+	t := after.Local().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.Local)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.Local)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.Local)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
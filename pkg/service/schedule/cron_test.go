@@ -0,0 +1,77 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"*/30 * * * *",
+		"0 9 * * 1-5",
+		"0 22 * * *",
+		"15,45 8-18/2 1 */3 7",
+		"@daily",
+		"@every 30m",
+	} {
+		_, err := Parse(spec)
+		assert.NoError(t, err, spec)
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every 10s",
+		"@every soon",
+		"@sometimes",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2024-12-02 is a Monday
+	at := func(d, h, m int) time.Time {
+		return time.Date(2024, 12, d, h, m, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"* * * * *", at(2, 10, 0), at(2, 10, 1)},
+		{"*/30 * * * *", at(2, 10, 5), at(2, 10, 30)},
+		{"*/30 * * * *", at(2, 10, 30), at(2, 11, 0)},
+		{"0 9 * * *", at(2, 9, 0), at(3, 9, 0)},
+		{"0 22 * * *", at(2, 10, 0), at(2, 22, 0)},
+		{"0 9 * * 6", at(2, 10, 0), at(7, 9, 0)},
+		{"0 9 * * 7", at(2, 10, 0), at(8, 9, 0)},
+		{"0 9 25 12 *", at(2, 10, 0), at(25, 9, 0)},
+		{"0 0 1 * *", at(2, 10, 0), time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)},
+		// both day fields restricted matches either
+		{"0 9 10 * 3", at(2, 10, 0), at(4, 9, 0)},
+		{"@hourly", at(2, 10, 15), at(2, 11, 0)},
+		{"@every 90m", at(2, 10, 15), at(2, 11, 45)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, s.Next(tt.after), tt.spec)
+	}
+
+	s, err := Parse("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(at(2, 10, 0)).IsZero())
+}
@@ -0,0 +1,96 @@
+package schedule
+
+import (
+	"strings"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	JobSourceConfig   = "config"
+	JobSourceDatabase = "database"
+	// configJobPrefix is added to the names of jobs from the config file so
+	// their IDs can't clash with jobs stored in the database.
+	configJobPrefix = "config:"
+)
+
+// Job is a scheduled job from either the config file or the database.
+type Job struct {
+	ID        string
+	Label     string
+	Source    string
+	Enabled   bool
+	Schedule  string
+	ZapScript string
+	CatchUp   string
+	// Spec is nil if the schedule couldn't be parsed, and SpecErr is the
+	// reason why.
+	Spec    *Spec
+	SpecErr error
+}
+
+// IsConfigJob returns true if the ID belongs to a job from the config file.
+func IsConfigJob(id string) bool {
+	return strings.HasPrefix(id, configJobPrefix)
+}
+
+func normalizeCatchUp(s string) string {
+	s = strings.ToLower(s)
+	if !utils.Contains(database.AllowedCatchUps, s) {
+		return database.CatchUpSkip
+	}
+	return s
+}
+
+func parseJobSpec(j *Job) {
+	j.Spec, j.SpecErr = Parse(j.Schedule)
+}
+
+// Jobs returns all scheduled jobs, including disabled ones. Config jobs
+// are listed first.
+func Jobs(cfg *config.Instance, db *database.Database) ([]Job, error) {
+	var jobs []Job
+
+	for _, cj := range cfg.ScheduleJobs() {
+		if cj.Name == "" {
+			log.Warn().Msg("skipping scheduled job with no name")
+			continue
+		}
+
+		j := Job{
+			ID:        configJobPrefix + cj.Name,
+			Label:     cj.Name,
+			Source:    JobSourceConfig,
+			Enabled:   cj.Enabled == nil || *cj.Enabled,
+			Schedule:  cj.Schedule,
+			ZapScript: cj.ZapScript,
+			CatchUp:   normalizeCatchUp(cj.CatchUp),
+		}
+		parseJobSpec(&j)
+		jobs = append(jobs, j)
+	}
+
+	dbJobs, err := db.GetAllScheduledJobs()
+	if err != nil {
+		return jobs, err
+	}
+
+	for _, dj := range dbJobs {
+		j := Job{
+			ID:        dj.Id,
+			Label:     dj.Label,
+			Source:    JobSourceDatabase,
+			Enabled:   dj.Enabled,
+			Schedule:  dj.Schedule,
+			ZapScript: dj.ZapScript,
+			CatchUp:   normalizeCatchUp(dj.CatchUp),
+		}
+		parseJobSpec(&j)
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
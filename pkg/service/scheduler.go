@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/schedule"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
+)
+
+// scheduler runs the ZapScript of scheduled jobs through the input token
+// queue, so they're launched and recorded in the history like any other
+// token. Jobs are checked once a minute.
+type scheduler struct {
+	cfg *config.Instance
+	st  *state.State
+	db  *database.Database
+	itq chan<- tokens.Token
+	// base is the time each job's next run is counted from. It's set when
+	// a job is first seen and after every run.
+	base map[string]time.Time
+	// invalid is the schedule of each job which couldn't be parsed, so
+	// it's only logged once per change instead of every tick.
+	invalid map[string]string
+}
+
+func newScheduler(
+	cfg *config.Instance,
+	st *state.State,
+	db *database.Database,
+	itq chan<- tokens.Token,
+) *scheduler {
+	return &scheduler{
+		cfg:     cfg,
+		st:      st,
+		db:      db,
+		itq:     itq,
+		base:    make(map[string]time.Time),
+		invalid: make(map[string]string),
+	}
+}
+
+// jobBase returns the time to count a newly seen job's next run from. Jobs
+// which catch up count from their last run, so a run missed while Core
+// wasn't running is due straight away.
+func (s *scheduler) jobBase(j schedule.Job, now time.Time) time.Time {
+	if j.CatchUp != database.CatchUpOnce {
+		return now
+	}
+
+	status, err := s.db.GetJobStatus(j.ID)
+	if err != nil {
+		log.Error().Err(err).Msgf("error getting job status: %s", j.ID)
+		return now
+	}
+
+	if status.LastRun.IsZero() {
+		return now
+	}
+
+	return status.LastRun
+}
+
+// tick runs all jobs which are due at the given time.
+func (s *scheduler) tick(now time.Time) {
+	jobs, err := schedule.Jobs(s.cfg, s.db)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting scheduled jobs")
+	}
+
+	seen := make(map[string]bool)
+	listed := make(map[string]bool)
+	for _, j := range jobs {
+		listed[j.ID] = true
+		if j.SpecErr != nil {
+			if last, ok := s.invalid[j.ID]; !ok || last != j.Schedule {
+				log.Error().Err(j.SpecErr).Msgf("invalid schedule for job: %s", j.ID)
+				s.invalid[j.ID] = j.Schedule
+			}
+			continue
+		}
+		delete(s.invalid, j.ID)
+
+		if !j.Enabled {
+			continue
+		}
+		seen[j.ID] = true
+
+		base, ok := s.base[j.ID]
+		if !ok {
+			base = s.jobBase(j, now)
+			s.base[j.ID] = base
+		}
+
+		next := j.Spec.Next(base)
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		// missed runs are only ever caught up once
+		s.base[j.ID] = now
+		go s.run(j)
+	}
+
+	for id := range s.base {
+		if !seen[id] {
+			delete(s.base, id)
+		}
+	}
+	for id := range s.invalid {
+		if !listed[id] {
+			delete(s.invalid, id)
+		}
+	}
+}
+
+// run sends the job's ZapScript to be launched, waits for the outcome and
+// saves it as the job's status.
+func (s *scheduler) run(j schedule.Job) {
+	log.Info().Msgf("running scheduled job: %s", j.ID)
+
+	resCh := make(chan tokens.LaunchResult, 1)
+	t := tokens.Token{
+		Text:     j.ZapScript,
+		ScanTime: time.Now(),
+		Source:   tokens.SourceSchedule,
+		FromAPI:  true,
+		Result:   resCh,
+	}
+
+	ctx := s.st.GetContext()
+	select {
+	case <-ctx.Done():
+		return
+	case s.itq <- t:
+	}
+
+	var err error
+	timer := time.NewTimer(config.LaunchWaitTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+		err = errors.New("timed out waiting for launch")
+	case res := <-resCh:
+		err = res.Err
+	}
+
+	status := database.JobStatus{
+		LastRun: t.ScanTime,
+		Success: err == nil,
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("scheduled job failed: %s", j.ID)
+		status.Error = err.Error()
+	}
+
+	err = s.db.SetJobStatus(j.ID, status)
+	if err != nil {
+		log.Error().Err(err).Msgf("error saving job status: %s", j.ID)
+	}
+}
+
+// start checks for due jobs straight away, then at the start of every
+// minute until the service is stopped.
+func (s *scheduler) start(ctx context.Context) {
+	s.tick(time.Now())
+
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now = <-timer.C:
+			s.tick(now)
+		}
+	}
+}
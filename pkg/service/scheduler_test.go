@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testScheduler(t *testing.T, jobs ...config.ScheduleJob) (*scheduler, chan tokens.Token) {
+	defaults := config.BaseDefaults
+	defaults.Schedule.Job = jobs
+	cfg, err := config.NewConfig(t.TempDir(), defaults)
+	require.NoError(t, err)
+
+	db, err := database.Open(testPlatform{dataDir: t.TempDir()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+	go func() {
+		for range ns {
+		}
+	}()
+
+	itq := make(chan tokens.Token, 10)
+	return newScheduler(cfg, st, db, itq), itq
+}
+
+// launches returns the number of jobs sent to the token queue, replying to
+// each so the job's run finishes.
+func launches(t *testing.T, itq chan tokens.Token) int {
+	n := 0
+	for {
+		select {
+		case tok := <-itq:
+			assert.Equal(t, tokens.SourceSchedule, tok.Source)
+			tok.Result <- tokens.LaunchResult{}
+			n++
+		case <-time.After(100 * time.Millisecond):
+			return n
+		}
+	}
+}
+
+func TestSchedulerTick(t *testing.T) {
+	at := func(h, m int) time.Time {
+		return time.Date(2025, 3, 1, h, m, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name    string
+		catchUp string
+		lastRun time.Time
+		ticks   []time.Time
+		want    []int
+	}{
+		{
+			name:    "skip_missed_runs",
+			catchUp: database.CatchUpSkip,
+			lastRun: at(9, 0),
+			ticks:   []time.Time{at(12, 30), at(12, 59), at(13, 0)},
+			want:    []int{0, 0, 1},
+		},
+		{
+			name:    "catch_up_once_from_last_run",
+			catchUp: database.CatchUpOnce,
+			lastRun: at(9, 0),
+			ticks:   []time.Time{at(12, 30), at(12, 31), at(12, 59), at(13, 0)},
+			want:    []int{1, 0, 0, 1},
+		},
+		{
+			name:    "catch_up_once_nothing_missed",
+			catchUp: database.CatchUpOnce,
+			lastRun: at(12, 0),
+			ticks:   []time.Time{at(12, 30), at(13, 0)},
+			want:    []int{0, 1},
+		},
+		{
+			name:    "catch_up_once_never_run",
+			catchUp: database.CatchUpOnce,
+			ticks:   []time.Time{at(12, 30), at(13, 0)},
+			want:    []int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, itq := testScheduler(t, config.ScheduleJob{
+				Name:      "hourly",
+				Schedule:  "0 * * * *",
+				ZapScript: "**launch.random:snes",
+				CatchUp:   tt.catchUp,
+			})
+
+			if !tt.lastRun.IsZero() {
+				err := s.db.SetJobStatus("config:hourly", database.JobStatus{
+					LastRun: tt.lastRun,
+					Success: true,
+				})
+				require.NoError(t, err)
+			}
+
+			for i, now := range tt.ticks {
+				s.tick(now)
+				assert.Equal(t, tt.want[i], launches(t, itq), "tick at %s", now.Format("15:04"))
+			}
+		})
+	}
+}
+
+func TestSchedulerInvalidJobLoggedOnce(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	t.Cleanup(func() {
+		log.Logger = logger
+	})
+
+	s, itq := testScheduler(t, config.ScheduleJob{
+		Name:      "broken",
+		Schedule:  "not a schedule",
+		ZapScript: "**launch.random:snes",
+	})
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		s.tick(now.Add(time.Duration(i) * time.Minute))
+	}
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "invalid schedule for job: config:broken"))
+	assert.Equal(t, "not a schedule", s.invalid["config:broken"])
+	assert.Zero(t, launches(t, itq))
+}
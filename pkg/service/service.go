@@ -22,6 +22,8 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -29,8 +31,13 @@ import (
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/api"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/sessions"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/mappings"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/webhooks"
 
 	"golang.org/x/exp/slices"
 
@@ -40,35 +47,81 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
 	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-func inExitGameBlocklist(platform platforms.Platform, cfg *config.Instance) bool {
+func inExitGameBlocklist(platform platforms.Platform, ignoreSystems []string) bool {
 	var blocklist []string
-	for _, v := range cfg.ReadersScan().IgnoreSystem {
+	for _, v := range ignoreSystems {
 		blocklist = append(blocklist, strings.ToLower(v))
 	}
 	return slices.Contains(blocklist, strings.ToLower(platform.GetActiveLauncher()))
 }
 
+// launchNotificationToken returns the token details sent with launch
+// notifications.
+func launchNotificationToken(t tokens.Token) models.LaunchToken {
+	lt := models.LaunchToken{
+		Type:   t.Type,
+		UID:    t.UID,
+		Text:   t.Text,
+		Data:   t.Data,
+		Source: t.Source,
+	}
+	if t.RunID != uuid.Nil {
+		id := t.RunID
+		lt.RunID = &id
+	}
+	return lt
+}
+
 func launchToken(
+	ctx context.Context,
 	platform platforms.Platform,
 	cfg *config.Instance,
+	ns chan<- models.Notification,
 	token tokens.Token,
+	active []tokens.Token,
 	db *database.Database,
 	lsq chan<- *tokens.Token,
 	plsc playlists.PlaylistController,
-) error {
+	confirm zapscript.ConfirmFunc,
+	check zapscript.LaunchCheckFunc,
+) (tokens.LaunchResult, error) {
+	res := tokens.LaunchResult{
+		RunID: token.RunID,
+	}
+
+	if token.Install != nil {
+		path, err := zapscript.InstallRunMedia(ctx, cfg, platform, *token.Install)
+		if err != nil {
+			return res, fmt.Errorf("error installing media: %w", err)
+		}
+		token.Text = path
+	}
+
 	text := token.Text
+	nt := launchNotificationToken(token)
 
-	mappingText, mapped := getMapping(cfg, db, platform, token)
+	match, mapped := mappings.Get(cfg, db, platform, token, active)
 	if mapped {
-		log.Info().Msgf("found mapping: %s", mappingText)
-		text = mappingText
+		log.Info().Msgf("found %s mapping: %s", match.Source, match.Mapping.Override)
+		text = match.Mapping.Override
+		notifications.LaunchMapping(ns, models.LaunchMappingParams{
+			Token:     nt,
+			Source:    match.Source,
+			MappingID: match.Mapping.Id,
+			Label:     match.Mapping.Label,
+			Type:      match.Mapping.Type,
+			Match:     match.Mapping.Match,
+			Pattern:   match.Mapping.Pattern,
+			ZapScript: match.Mapping.Override,
+		})
 	}
 
 	if text == "" {
-		return fmt.Errorf("no ZapScript in token")
+		return res, fmt.Errorf("no ZapScript in token")
 	}
 
 	log.Info().Msgf("launching ZapScript: %s", text)
@@ -77,7 +130,19 @@ func launchToken(
 	pls := plsc.Active
 
 	for i, cmd := range cmds {
+		if ctx.Err() != nil {
+			return res, context.Cause(ctx)
+		}
+
+		notifications.LaunchCmdStarted(ns, models.LaunchCmdStartedParams{
+			Token:   nt,
+			Index:   i,
+			Total:   len(cmds),
+			Command: cmd,
+		})
+
 		result, err := zapscript.LaunchToken(
+			ctx,
 			platform,
 			cfg,
 			playlists.PlaylistController{
@@ -88,15 +153,45 @@ func launchToken(
 			cmd,
 			len(cmds),
 			i,
+			confirm,
+			check,
 		)
+
+		finished := models.LaunchCmdFinishedParams{
+			Token:           nt,
+			Index:           i,
+			Total:           len(cmds),
+			Command:         cmd,
+			Success:         err == nil,
+			MediaChanged:    result.MediaChanged,
+			PlaylistChanged: result.PlaylistChanged,
+			Path:            result.Path,
+		}
 		if err != nil {
-			return err
+			finished.Error = err.Error()
+		}
+		notifications.LaunchCmdFinished(ns, finished)
+
+		if err != nil {
+			return res, err
+		}
+
+		if result.Path != "" {
+			res.Path = result.Path
+		}
+
+		if result.MediaChanged {
+			res.MediaChanged = true
+			res.Launcher = platform.GetActiveLauncher()
 		}
 
 		if result.MediaChanged && !token.FromAPI {
 			log.Debug().Any("token", token).Msg("media changed, updating token")
 			log.Info().Msgf("current media launched set to: %s", token.UID)
-			lsq <- &token
+			select {
+			case lsq <- &token:
+			case <-ctx.Done():
+			}
 		}
 
 		if result.PlaylistChanged {
@@ -104,65 +199,181 @@ func launchToken(
 		}
 	}
 
-	return nil
+	return res, nil
 }
 
-func processTokenQueue(
+// reportLaunchResult sends the outcome of a launch back to the API, if the
+// token was run from the API and is waiting on a result. Failed launches
+// of any token are also sent as a launch failed notification.
+func reportLaunchResult(
+	st *state.State,
+	token tokens.Token,
+	res tokens.LaunchResult,
+	err error,
+) {
+	res.RunID = token.RunID
+	res.Err = err
+
+	if err != nil {
+		notifications.LaunchFailed(st.Notifications, models.LaunchFailedParams{
+			Token: launchNotificationToken(token),
+			Error: err.Error(),
+		})
+	}
+
+	if token.RunID != uuid.Nil {
+		resp := models.LaunchResultResponse{
+			RunID:        res.RunID,
+			Success:      err == nil,
+			Path:         res.Path,
+			Launcher:     res.Launcher,
+			MediaChanged: res.MediaChanged,
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		notifications.LaunchResult(st.Notifications, resp)
+	}
+
+	if token.Result != nil {
+		select {
+		case token.Result <- res:
+		default:
+			log.Warn().Msgf("launch result not received: %s", token.RunID)
+		}
+	}
+}
+
+func addTokenHistory(db *database.Database, t tokens.Token, success bool) {
+	he := database.HistoryEntry{
+		Time:    t.ScanTime,
+		Type:    t.Type,
+		UID:     t.UID,
+		Text:    t.Text,
+		Data:    t.Data,
+		Source:  t.Source,
+		Success: success,
+	}
+	err := db.AddHistory(he)
+	if err != nil {
+		log.Error().Err(err).Msgf("error adding history")
+	}
+}
+
+// runLaunchQueue is the launch worker. It launches queued tokens one at a
+// time until the service is stopped.
+func runLaunchQueue(
 	platform platforms.Platform,
 	cfg *config.Instance,
 	st *state.State,
-	itq <-chan tokens.Token,
 	db *database.Database,
 	lsq chan<- *tokens.Token,
 	plq chan *playlists.Playlist,
+	q *launchQueue,
+	le *limitsEnforcer,
+	confirm zapscript.ConfirmFunc,
+) {
+	for {
+		job, ctx := q.next(st.GetContext())
+		if job == nil {
+			log.Debug().Msg("exiting launch worker via context cancellation")
+			return
+		}
+
+		t := job.token
+		plsc := playlists.PlaylistController{
+			Active: st.GetActivePlaylist(),
+			Queue:  plq,
+		}
+
+		var res tokens.LaunchResult
+		var err error
+		var check zapscript.LaunchCheckFunc
+		if !t.IgnoreLimits {
+			err = le.checkToken(t)
+			check = le.checkLaunch
+		}
+		if err == nil {
+			res, err = launchToken(ctx, platform, cfg, st.Notifications, t, st.GetActiveTokens(), db, lsq, plsc, confirm, check)
+		}
+		if err != nil {
+			log.Error().Err(err).Msgf("error launching token")
+		}
+		q.done()
+
+		reportLaunchResult(st, t, res, err)
+		if t.Source != tokens.SourceHook {
+			addTokenHistory(db, t, err == nil)
+		}
+	}
+}
+
+// notifyPlaylistChanged sends the new state of the active playlist, which is
+// nil if it was cleared.
+func notifyPlaylistChanged(st *state.State, pls *playlists.Playlist) {
+	params := models.PlaylistChangedParams{}
+	if pls != nil {
+		params.ID = pls.ID
+		params.Index = pls.Index
+		params.Playing = pls.Playing
+		params.Media = len(pls.Media)
+		if len(pls.Media) > 0 {
+			current := pls.Current()
+			params.Current = &models.PlaylistMediaParams{
+				Name: current.Name,
+				Path: current.Path,
+			}
+		}
+	}
+	notifications.PlaylistChanged(st.Notifications, params)
+}
+
+// supersedes returns true if the token was run by someone, from a reader
+// or the API, so it takes over from any launch in progress. Tokens from
+// scheduled jobs and the GMC proxy wait their turn instead.
+func supersedes(t tokens.Token) bool {
+	if t.Source == tokens.SourceSchedule {
+		return false
+	}
+	return t.FromAPI || t.Source != ""
+}
+
+func processTokenQueue(
+	platform platforms.Platform,
+	st *state.State,
+	itq <-chan tokens.Token,
+	db *database.Database,
+	plq chan *playlists.Playlist,
+	q *launchQueue,
+	le *limitsEnforcer,
 ) {
 	for {
 		select {
 		case pls := <-plq:
 			activePlaylist := st.GetActivePlaylist()
 			launchPlaylistMedia := func() {
-				t := tokens.Token{
+				q.push(tokens.Token{
 					Text:     pls.Current().Path,
 					ScanTime: time.Now(),
 					Source:   tokens.SourcePlaylist,
-				}
-				plsc := playlists.PlaylistController{
-					Active: activePlaylist,
-					Queue:  plq,
-				}
-
-				err := launchToken(platform, cfg, t, db, lsq, plsc)
-				if err != nil {
-					log.Error().Err(err).Msgf("error launching token")
-				}
-
-				he := database.HistoryEntry{
-					Time: t.ScanTime,
-					Type: t.Type,
-					UID:  t.UID,
-					Text: t.Text,
-					Data: t.Data,
-				}
-				he.Success = err == nil
-				err = db.AddHistory(he)
-				if err != nil {
-					log.Error().Err(err).Msgf("error adding history")
-				}
+				}, false)
 			}
 
 			if pls == nil {
 				// playlist is cleared
 				if activePlaylist != nil {
 					log.Info().Msg("clearing playlist")
+					notifyPlaylistChanged(st, nil)
 				}
 				st.SetActivePlaylist(nil)
 				continue
 			} else if activePlaylist == nil {
 				// new playlist loaded
 				st.SetActivePlaylist(pls)
+				notifyPlaylistChanged(st, pls)
 				if pls.Playing {
 					log.Info().Any("pls", pls).Msg("setting new playlist, launching token")
-					go launchPlaylistMedia()
+					launchPlaylistMedia()
 				} else {
 					log.Info().Any("pls", pls).Msg("setting new playlist")
 				}
@@ -176,9 +387,10 @@ func processTokenQueue(
 				}
 
 				st.SetActivePlaylist(pls)
+				notifyPlaylistChanged(st, pls)
 				if pls.Playing {
 					log.Info().Any("pls", pls).Msg("updating playlist, launching token")
-					go launchPlaylistMedia()
+					launchPlaylistMedia()
 				} else {
 					log.Info().Any("pls", pls).Msg("updating playlist")
 				}
@@ -198,44 +410,27 @@ func processTokenQueue(
 				log.Error().Err(err).Msgf("error writing tmp scan result")
 			}
 
-			he := database.HistoryEntry{
-				Time: t.ScanTime,
-				Type: t.Type,
-				UID:  t.UID,
-				Text: t.Text,
-				Data: t.Data,
+			if le.isAdminCard(t) {
+				log.Info().Msg("admin card scanned, overriding playtime limits")
+				le.override()
+				reportLaunchResult(st, t, tokens.LaunchResult{RunID: t.RunID}, nil)
+				continue
 			}
 
 			if !st.RunZapScriptEnabled() {
 				log.Debug().Msg("ZapScript disabled, skipping run")
-				err = db.AddHistory(he)
-				if err != nil {
-					log.Error().Err(err).Msgf("error adding history")
-				}
+				reportLaunchResult(
+					st, t, tokens.LaunchResult{},
+					errors.New("ZapScript disabled"),
+				)
+				addTokenHistory(db, t, false)
 				continue
 			}
 
-			// launch tokens in separate thread
-			go func() {
-				plsc := playlists.PlaylistController{
-					Active: st.GetActivePlaylist(),
-					Queue:  plq,
-				}
-
-				err = launchToken(platform, cfg, t, db, lsq, plsc)
-				if err != nil {
-					log.Error().Err(err).Msgf("error launching token")
-				}
-
-				he.Success = err == nil
-				err = db.AddHistory(he)
-				if err != nil {
-					log.Error().Err(err).Msgf("error adding history")
-				}
-			}()
+			q.push(t, supersedes(t))
 		case <-st.GetContext().Done():
 			log.Debug().Msg("Exiting Service worker via context cancellation")
-			break
+			return
 		}
 	}
 }
@@ -294,8 +489,43 @@ func Start(
 		return nil, err
 	}
 
+	log.Info().Msg("starting webhooks dispatcher")
+	wh := webhooks.NewDispatcher(cfg)
+	wh.Start(st.GetContext())
+
+	log.Info().Msg("starting play session recorder")
+	sr := newSessionRecorder(st, db)
+
+	log.Info().Msg("starting playtime limits enforcer")
+	le := newLimitsEnforcer(pl, cfg, st, db, sr)
+	// check new media straight away, instead of on the next tick
+	sr.started = le.enforce
+
+	go sr.run(st.GetContext())
+	go le.run(st.GetContext())
+
+	sess := sessions.NewManager()
+
+	log.Info().Msg("starting launch worker")
+	q := newLaunchQueue(st, func(t tokens.Token, err error) {
+		reportLaunchResult(st, t, tokens.LaunchResult{}, err)
+		if t.Source != tokens.SourceHook {
+			addTokenHistory(db, t, false)
+		}
+	})
+	go runLaunchQueue(pl, cfg, st, db, lsq, plq, q, le, clientConfirm(sess))
+
+	log.Info().Msg("starting event hooks")
+	hr := newHookRunner(cfg, q)
+	go hr.run(st.GetContext())
+
 	log.Info().Msg("starting API service")
-	go api.Start(pl, cfg, st, itq, db, ns)
+	apiDone, err := api.Start(pl, cfg, st, itq, db, ns, sess, le.overridePin, wh.Notify, sr.notify, hr.notify)
+	if err != nil {
+		log.Error().Err(err).Msg("error starting API service")
+		st.StopService()
+		return nil, err
+	}
 
 	if cfg.GmcProxyEnabled() {
 		log.Info().Msg("starting GroovyMiSTer GMC Proxy service")
@@ -303,10 +533,13 @@ func Start(
 	}
 
 	log.Info().Msg("starting reader manager")
-	go readerManager(pl, cfg, st, db, itq, lsq, plq)
+	go readerManager(pl, cfg, st, db, itq, lsq, q)
 
 	log.Info().Msg("starting input token queue manager")
-	go processTokenQueue(pl, cfg, st, itq, db, lsq, plq)
+	go processTokenQueue(pl, st, itq, db, plq, q, le)
+
+	log.Info().Msg("starting scheduler")
+	go newScheduler(cfg, st, db, itq).start(st.GetContext())
 
 	log.Info().Msg("running platform post start")
 	err = pl.StartPost(cfg, st.Notifications)
@@ -315,15 +548,16 @@ func Start(
 		return nil, err
 	}
 
+	hr.fire(hookEvent{name: HookServiceStarted})
+
 	return func() error {
+		hr.shutdown()
 		err = pl.Stop()
 		if err != nil {
 			log.Warn().Msgf("error stopping platform: %s", err)
 		}
 		st.StopService()
-		close(plq)
-		close(lsq)
-		close(itq)
+		<-apiDone
 		return nil
 	}, nil
 }
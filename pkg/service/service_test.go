@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/mappings"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collectNotifications runs f and returns every notification it sent.
+func collectNotifications(t *testing.T, f func(st *state.State)) []models.Notification {
+	st, ns := state.NewState(nil)
+	t.Cleanup(st.StopService)
+
+	var sent []models.Notification
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := range ns {
+			sent = append(sent, n)
+		}
+	}()
+
+	f(st)
+	close(st.Notifications)
+	<-done
+
+	return sent
+}
+
+func TestReportLaunchResult(t *testing.T) {
+	resCh := make(chan tokens.LaunchResult, 1)
+	token := tokens.Token{
+		Text:   "snes/game.sfc",
+		RunID:  uuid.New(),
+		Result: resCh,
+	}
+
+	sent := collectNotifications(t, func(st *state.State) {
+		reportLaunchResult(st, token, tokens.LaunchResult{
+			Path:         "/media/fat/games/SNES/game.sfc",
+			Launcher:     "SNES",
+			MediaChanged: true,
+		}, nil)
+	})
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, models.NotificationLaunchResult, sent[0].Method)
+
+	var resp models.LaunchResultResponse
+	require.NoError(t, json.Unmarshal(sent[0].Params, &resp))
+	assert.Equal(t, models.LaunchResultResponse{
+		RunID:        token.RunID,
+		Success:      true,
+		Path:         "/media/fat/games/SNES/game.sfc",
+		Launcher:     "SNES",
+		MediaChanged: true,
+	}, resp)
+
+	res := <-resCh
+	assert.Equal(t, token.RunID, res.RunID)
+	assert.NoError(t, res.Err)
+}
+
+func TestReportLaunchResultFailed(t *testing.T) {
+	token := tokens.Token{
+		Text:  "snes/missing.sfc",
+		RunID: uuid.New(),
+	}
+
+	sent := collectNotifications(t, func(st *state.State) {
+		reportLaunchResult(st, token, tokens.LaunchResult{}, errors.New("file not found"))
+	})
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, models.NotificationLaunchFailed, sent[0].Method)
+	assert.Equal(t, models.NotificationLaunchResult, sent[1].Method)
+
+	var resp models.LaunchResultResponse
+	require.NoError(t, json.Unmarshal(sent[1].Params, &resp))
+	assert.Equal(t, token.RunID, resp.RunID)
+	assert.False(t, resp.Success)
+	assert.Equal(t, "file not found", resp.Error)
+}
+
+func TestReportLaunchResultNoRunID(t *testing.T) {
+	// tokens not run from the API only report failures
+	sent := collectNotifications(t, func(st *state.State) {
+		reportLaunchResult(st, tokens.Token{Text: "snes/game.sfc"}, tokens.LaunchResult{}, nil)
+	})
+	assert.Empty(t, sent)
+}
+
+// mappedPlatform maps every token to the same ZapScript.
+type mappedPlatform struct {
+	limitsPlatform
+	mapping string
+}
+
+func (p mappedPlatform) LookupMapping(tokens.Token) (string, bool) {
+	return p.mapping, true
+}
+
+func TestLaunchTokenNotifications(t *testing.T) {
+	cfg, err := config.NewConfig(t.TempDir(), config.BaseDefaults)
+	require.NoError(t, err)
+
+	pl := mappedPlatform{
+		limitsPlatform: limitsPlatform{testPlatform{dataDir: t.TempDir()}},
+		mapping:        "**delay:1||/media/fat/games/Genesis/streets.md",
+	}
+	db, err := database.Open(pl)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	token := tokens.Token{UID: "04aabbcc", Text: "streets"}
+	var res tokens.LaunchResult
+	sent := collectNotifications(t, func(st *state.State) {
+		res, err = launchToken(
+			context.Background(),
+			pl,
+			cfg,
+			st.Notifications,
+			token,
+			nil,
+			db,
+			make(chan *tokens.Token, 1),
+			playlists.PlaylistController{Queue: make(chan *playlists.Playlist, 1)},
+			nil,
+			nil,
+		)
+	})
+	require.NoError(t, err)
+	assert.True(t, res.MediaChanged)
+
+	methods := make([]string, 0, len(sent))
+	for _, n := range sent {
+		methods = append(methods, n.Method)
+	}
+	require.Equal(t, []string{
+		models.NotificationLaunchMapping,
+		models.NotificationLaunchCmdStarted,
+		models.NotificationLaunchCmdFinished,
+		models.NotificationLaunchCmdStarted,
+		models.NotificationLaunchCmdFinished,
+	}, methods)
+
+	nt := models.LaunchToken{UID: "04aabbcc", Text: "streets"}
+
+	var mapping models.LaunchMappingParams
+	require.NoError(t, json.Unmarshal(sent[0].Params, &mapping))
+	assert.Equal(t, models.LaunchMappingParams{
+		Token:     nt,
+		Source:    mappings.SourcePlatform,
+		ZapScript: pl.mapping,
+	}, mapping)
+
+	var started models.LaunchCmdStartedParams
+	require.NoError(t, json.Unmarshal(sent[3].Params, &started))
+	assert.Equal(t, models.LaunchCmdStartedParams{
+		Token:   nt,
+		Index:   1,
+		Total:   2,
+		Command: "/media/fat/games/Genesis/streets.md",
+	}, started)
+
+	var finished models.LaunchCmdFinishedParams
+	require.NoError(t, json.Unmarshal(sent[2].Params, &finished))
+	assert.Equal(t, models.LaunchCmdFinishedParams{
+		Token:   nt,
+		Index:   0,
+		Total:   2,
+		Command: "**delay:1",
+		Success: true,
+	}, finished)
+
+	require.NoError(t, json.Unmarshal(sent[4].Params, &finished))
+	assert.Equal(t, models.LaunchCmdFinishedParams{
+		Token:        nt,
+		Index:        1,
+		Total:        2,
+		Command:      "/media/fat/games/Genesis/streets.md",
+		Success:      true,
+		MediaChanged: true,
+		Path:         "/media/fat/games/Genesis/streets.md",
+	}, finished)
+}
+
+func TestPlaylistChangedNotifications(t *testing.T) {
+	pls := playlists.NewPlaylist("games", []playlists.PlaylistMedia{
+		{Name: "Mario", Path: "/media/fat/games/SNES/mario.sfc"},
+		{Name: "Sonic", Path: "/media/fat/games/Genesis/sonic.md"},
+	})
+	next := *pls
+	next.Index = 1
+
+	sent := collectNotifications(t, func(st *state.State) {
+		plq := make(chan *playlists.Playlist)
+		exited := make(chan struct{})
+		go func() {
+			defer close(exited)
+			processTokenQueue(nil, st, nil, nil, plq, nil, nil)
+		}()
+
+		plq <- pls
+		plq <- &next
+		plq <- nil
+		st.StopService()
+		<-exited
+	})
+
+	require.Len(t, sent, 3)
+	params := make([]models.PlaylistChangedParams, 0, len(sent))
+	for _, n := range sent {
+		assert.Equal(t, models.NotificationPlaylistChanged, n.Method)
+		var p models.PlaylistChangedParams
+		require.NoError(t, json.Unmarshal(n.Params, &p))
+		params = append(params, p)
+	}
+
+	assert.Equal(t, []models.PlaylistChangedParams{
+		{
+			ID:    "games",
+			Index: 0,
+			Current: &models.PlaylistMediaParams{
+				Name: "Mario",
+				Path: "/media/fat/games/SNES/mario.sfc",
+			},
+			Media: 2,
+		},
+		{
+			ID:    "games",
+			Index: 1,
+			Current: &models.PlaylistMediaParams{
+				Name: "Sonic",
+				Path: "/media/fat/games/Genesis/sonic.md",
+			},
+			Media: 2,
+		},
+		{},
+	}, params)
+}
@@ -2,8 +2,10 @@ package state
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
@@ -24,6 +26,7 @@ type State struct {
 	stopService    bool         // ctx used for observers when stopped
 	platform       platforms.Platform
 	readers        map[string]readers.Reader
+	readerTokens   map[string]tokens.Token
 	softwareToken  *tokens.Token
 	wroteToken     *tokens.Token
 	Notifications  chan<- models.Notification // TODO: move outside state
@@ -31,6 +34,9 @@ type State struct {
 	ctx            context.Context
 	ctxCancelFunc  context.CancelFunc
 	activeMedia    *models.ActiveMedia
+	launchQueue    models.RunQueueResponse
+	cancelLaunch   func()
+	limitsOverride time.Time
 }
 
 func NewState(platform platforms.Platform) (*State, <-chan models.Notification) {
@@ -40,6 +46,7 @@ func NewState(platform platforms.Platform) (*State, <-chan models.Notification)
 		runZapScript:  true,
 		platform:      platform,
 		readers:       make(map[string]readers.Reader),
+		readerTokens:  make(map[string]tokens.Token),
 		Notifications: ns,
 		ctx:           ctx,
 		ctxCancelFunc: ctxCancelFunc,
@@ -78,6 +85,45 @@ func (s *State) GetActiveCard() tokens.Token {
 	return s.activeToken
 }
 
+// SetReaderToken sets the token currently present on a reader device. A nil
+// token means the reader is now empty.
+func (s *State) SetReaderToken(device string, token *tokens.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token == nil {
+		delete(s.readerTokens, device)
+	} else {
+		s.readerTokens[device] = *token
+	}
+}
+
+// GetReaderTokens returns a copy of the tokens currently present on each
+// reader device.
+func (s *State) GetReaderTokens() map[string]tokens.Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts := make(map[string]tokens.Token, len(s.readerTokens))
+	for k, v := range s.readerTokens {
+		ts[k] = v
+	}
+	return ts
+}
+
+// GetActiveTokens returns all tokens currently present on any reader, oldest
+// scan first.
+func (s *State) GetActiveTokens() []tokens.Token {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ts := make([]tokens.Token, 0, len(s.readerTokens))
+	for _, t := range s.readerTokens {
+		ts = append(ts, t)
+	}
+	sort.Slice(ts, func(i, j int) bool {
+		return ts[i].ScanTime.Before(ts[j].ScanTime)
+	})
+	return ts
+}
+
 func (s *State) GetLastScanned() tokens.Token {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -152,6 +198,7 @@ func (s *State) RemoveReader(device string) {
 		path = ps[1]
 	}
 	delete(s.readers, device)
+	delete(s.readerTokens, device)
 	notifications.ReadersRemoved(s.Notifications, models.ReaderResponse{
 		Connected: false,
 		Driver:    driver,
@@ -214,10 +261,72 @@ func (s *State) ActiveMedia() *models.ActiveMedia {
 	return s.activeMedia
 }
 
+// SetActiveMedia sets the currently active media, and sends a media
+// started or stopped notification if it changed. A nil media means no media
+// is active.
 func (s *State) SetActiveMedia(media *models.ActiveMedia) {
 	s.mu.Lock()
+	prev := s.activeMedia
 	s.activeMedia = media
 	s.mu.Unlock()
+
+	switch {
+	case media == nil && prev != nil:
+		notifications.MediaStopped(s.Notifications)
+	case media != nil && (prev == nil || *prev != *media):
+		notifications.MediaStarted(s.Notifications, models.MediaStartedParams{
+			SystemID:   media.SystemId,
+			SystemName: media.SystemName,
+			MediaPath:  media.MediaPath,
+			MediaName:  media.MediaName,
+		})
+	}
+}
+
+// LaunchQueue returns the current job and pending jobs of the launch queue.
+func (s *State) LaunchQueue() models.RunQueueResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.launchQueue
+}
+
+func (s *State) SetLaunchQueue(queue models.RunQueueResponse) {
+	s.mu.Lock()
+	s.launchQueue = queue
+	s.mu.Unlock()
+}
+
+// SetLaunchCanceller sets the function used to cancel all in-flight and
+// pending launches.
+func (s *State) SetLaunchCanceller(cancel func()) {
+	s.mu.Lock()
+	s.cancelLaunch = cancel
+	s.mu.Unlock()
+}
+
+// CancelLaunch cancels the in-flight launch, if any, and drops all pending
+// launches.
+func (s *State) CancelLaunch() {
+	s.mu.RLock()
+	cancel := s.cancelLaunch
+	s.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// LimitsOverride returns the time until which playtime limits are
+// overridden.
+func (s *State) LimitsOverride() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limitsOverride
+}
+
+func (s *State) SetLimitsOverride(until time.Time) {
+	s.mu.Lock()
+	s.limitsOverride = until
+	s.mu.Unlock()
 }
 
 func (s *State) GetContext() context.Context {
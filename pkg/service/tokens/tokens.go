@@ -2,6 +2,9 @@ package tokens
 
 import (
 	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+	"github.com/google/uuid"
 )
 
 const (
@@ -10,6 +13,11 @@ const (
 	TypeAmiibo         = "Amiibo"
 	TypeLegoDimensions = "LegoDimensions"
 	SourcePlaylist     = "Playlist"
+	// SourceHook tokens are run internally by Core, such as system
+	// before_exit scripts, and aren't recorded in the history.
+	SourceHook = "Hook"
+	// SourceSchedule tokens are run by a scheduled job.
+	SourceSchedule = "Schedule"
 )
 
 type Token struct {
@@ -21,4 +29,24 @@ type Token struct {
 	FromAPI  bool
 	Source   string
 	Unsafe   bool
+	// IgnoreLimits skips the playtime limits check, for hooks which must
+	// run even when a limit has been reached.
+	IgnoreLimits bool
+	// RunID is set on tokens run from the API, and is reported back with the
+	// launch result so async callers can match it to their request.
+	RunID uuid.UUID
+	// Result, if not nil, is sent the outcome of the launch when finished.
+	Result chan<- LaunchResult
+	// Install, if not nil, is remote media which is downloaded when the
+	// token is launched. Text is replaced with the installed media path.
+	Install *models.CmdLaunchArgs
+}
+
+// LaunchResult is the outcome of running a token's ZapScript.
+type LaunchResult struct {
+	RunID        uuid.UUID
+	Path         string
+	Launcher     string
+	MediaChanged bool
+	Err          error
 }
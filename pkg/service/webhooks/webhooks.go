@@ -0,0 +1,239 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// QueueSize is the number of notifications queued for each target
+	// before new ones are dropped.
+	QueueSize      = 100
+	DefaultRetries = 3
+	RequestTimeout = 10 * time.Second
+	HeaderEvent    = "X-Zaparoo-Event"
+	// HeaderSignature is set when a target has a secret. Its value is
+	// "sha256=" followed by the hex HMAC-SHA256 of the request body.
+	HeaderSignature = "X-Zaparoo-Signature"
+	// RetryBackoff is the delay before the first retry of a failed
+	// request, doubled after each following attempt.
+	RetryBackoff = time.Second
+)
+
+// statusError is returned when a target responds with a non-2xx status.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.code)
+}
+
+// retryable reports if a failed request should be sent again. Client errors
+// won't succeed on a retry, except for timeouts and rate limiting.
+func retryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	if se.code >= 400 && se.code < 500 {
+		return se.code == http.StatusRequestTimeout ||
+			se.code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+type payload struct {
+	method string
+	body   []byte
+}
+
+type target struct {
+	url     string
+	headers map[string]string
+	secret  string
+	retries int
+	subs    *notifications.Subscriptions
+	queue   chan payload
+}
+
+// Dispatcher posts API notifications to the webhook targets set in the
+// config. Each target has its own queue and worker, so a slow or offline
+// target never delays the others or the notifications channel.
+type Dispatcher struct {
+	client  *http.Client
+	backoff time.Duration
+	targets []*target
+}
+
+func NewDispatcher(cfg *config.Instance) *Dispatcher {
+	d := &Dispatcher{
+		client: &http.Client{
+			Timeout: RequestTimeout,
+		},
+		backoff: RetryBackoff,
+	}
+
+	for _, wt := range cfg.WebhookTargets() {
+		if wt.URL == "" {
+			log.Warn().Msg("skipping webhook target with no url")
+			continue
+		}
+
+		subs := notifications.NewSubscriptions()
+		if len(wt.Events) > 0 {
+			var patterns []string
+			for _, e := range wt.Events {
+				if !notifications.ValidPattern(e) {
+					log.Warn().Msgf("ignoring invalid webhook event pattern for %s: %s", wt.URL, e)
+					continue
+				}
+				patterns = append(patterns, e)
+			}
+			if len(patterns) == 0 {
+				log.Warn().Msgf("skipping webhook target with no valid events: %s", wt.URL)
+				continue
+			}
+			subs.Unsubscribe(nil)
+			subs.Subscribe(patterns)
+		}
+
+		retries := DefaultRetries
+		if wt.Retries != nil && *wt.Retries >= 0 {
+			retries = *wt.Retries
+		}
+
+		d.targets = append(d.targets, &target{
+			url:     wt.URL,
+			headers: wt.Headers,
+			secret:  wt.Secret,
+			retries: retries,
+			subs:    subs,
+			queue:   make(chan payload, QueueSize),
+		})
+	}
+
+	return d
+}
+
+// Start runs a worker for each target until the context is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	if len(d.targets) > 0 {
+		log.Info().Msgf("starting %d webhook targets", len(d.targets))
+	}
+
+	for _, t := range d.targets {
+		go d.worker(ctx, t)
+	}
+}
+
+// Notify queues a notification on every target subscribed to its method.
+// It never blocks, if a target's queue is full the notification is dropped.
+func (d *Dispatcher) Notify(notif models.Notification) {
+	if len(d.targets) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(models.RequestObject{
+		JSONRPC: "2.0",
+		Method:  notif.Method,
+		Params:  notif.Params,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("marshalling webhook notification")
+		return
+	}
+
+	for _, t := range d.targets {
+		if !t.subs.Matches(notif.Method) {
+			continue
+		}
+
+		select {
+		case t.queue <- payload{method: notif.Method, body: data}:
+		default:
+			log.Warn().Msgf("webhook queue full, dropping %s for: %s", notif.Method, t.url)
+		}
+	}
+}
+
+// Sign returns the signature header value of a request body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) post(ctx context.Context, t *target, p payload) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(p.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", config.AppName+"/"+config.AppVersion)
+	req.Header.Set(HeaderEvent, p.method)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if t.secret != "" {
+		req.Header.Set(HeaderSignature, Sign(t.secret, p.body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) worker(ctx context.Context, t *target) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-t.queue:
+			backoff := d.backoff
+			for attempt := 0; ; attempt++ {
+				err := d.post(ctx, t, p)
+				if err == nil {
+					break
+				}
+
+				if attempt >= t.retries || !retryable(err) || ctx.Err() != nil {
+					log.Error().Err(err).Msgf("error sending webhook: %s", t.url)
+					break
+				}
+
+				log.Debug().Err(err).Msgf("retrying webhook in %s: %s", backoff, t.url)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+			}
+		}
+	}
+}
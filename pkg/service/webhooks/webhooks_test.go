@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/notifications"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcherNotify(t *testing.T) {
+	type received struct {
+		event     string
+		signature string
+		body      []byte
+	}
+	got := make(chan received, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{
+			event:     r.Header.Get(HeaderEvent),
+			signature: r.Header.Get(HeaderSignature),
+			body:      body,
+		}
+	}))
+	defer srv.Close()
+
+	subs := notifications.NewSubscriptions()
+	subs.Unsubscribe(nil)
+	subs.Subscribe([]string{"tokens.*"})
+
+	d := &Dispatcher{
+		client: srv.Client(),
+		targets: []*target{{
+			url:    srv.URL,
+			secret: "secret",
+			subs:   subs,
+			queue:  make(chan payload, QueueSize),
+		}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.Start(ctx)
+
+	d.Notify(models.Notification{Method: models.NotificationStarted})
+	d.Notify(models.Notification{Method: models.NotificationTokensRemoved})
+
+	select {
+	case r := <-got:
+		assert.Equal(t, models.NotificationTokensRemoved, r.event)
+		assert.Equal(t, Sign("secret", r.body), r.signature)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "webhook not received")
+	}
+
+	assert.Empty(t, got)
+}
+
+// testRetries sends a single notification to a target which responds with
+// each status in turn, and returns the time of every attempt received.
+func testRetries(t *testing.T, retries int, statuses ...int) []time.Time {
+	var mu sync.Mutex
+	var attempts []time.Time
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		status := http.StatusOK
+		if len(attempts) < len(statuses) {
+			status = statuses[len(attempts)]
+		}
+		attempts = append(attempts, time.Now())
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	d := &Dispatcher{
+		client:  srv.Client(),
+		backoff: 20 * time.Millisecond,
+		targets: []*target{{
+			url:     srv.URL,
+			retries: retries,
+			subs:    notifications.NewSubscriptions(),
+			queue:   make(chan payload, QueueSize),
+		}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.Start(ctx)
+
+	d.Notify(models.Notification{Method: models.NotificationTokensRemoved})
+
+	// long enough for every retry in the tests to finish
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return attempts
+}
+
+func TestDispatcherRetryBackoff(t *testing.T) {
+	attempts := testRetries(t, 3,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+	)
+	require.Len(t, attempts, 4)
+
+	// each delay is double the previous one
+	for i, want := range []time.Duration{20, 40, 80} {
+		delay := attempts[i+1].Sub(attempts[i])
+		assert.GreaterOrEqual(t, delay, want*time.Millisecond, "attempt %d", i+1)
+	}
+}
+
+func TestDispatcherRetryLimit(t *testing.T) {
+	attempts := testRetries(t, 1,
+		http.StatusInternalServerError,
+		http.StatusInternalServerError,
+		http.StatusInternalServerError,
+	)
+	assert.Len(t, attempts, 2)
+}
+
+func TestDispatcherRetryClientErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		attempts int
+	}{
+		{http.StatusBadRequest, 1},
+		{http.StatusUnauthorized, 1},
+		{http.StatusNotFound, 1},
+		{http.StatusRequestTimeout, 2},
+		{http.StatusTooManyRequests, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			attempts := testRetries(t, 3, tt.status)
+			assert.Len(t, attempts, tt.attempts)
+		})
+	}
+}
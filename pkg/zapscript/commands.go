@@ -21,6 +21,7 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package zapscript
 
 import (
+	"context"
 	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
 	"net/url"
@@ -84,6 +85,26 @@ var cmdMap = map[string]func(
 	models.ZapScriptCmdGet:      cmdHttpGet, // DEPRECATED
 }
 
+// unsafeCmds are the commands which are refused when run from a remote
+// source, like a zap link, unless a client confirms them.
+var unsafeCmds = map[string]bool{
+	models.ZapScriptCmdExecute:       true,
+	models.ZapScriptCmdInputKeyboard: true,
+	models.ZapScriptCmdInputGamepad:  true,
+	models.ZapScriptCmdInputKey:      true, // DEPRECATED
+	models.ZapScriptCmdKey:           true, // DEPRECATED
+	models.ZapScriptCmdShell:         true, // DEPRECATED
+	models.ZapScriptCmdCommand:       true, // DEPRECATED
+}
+
+// ConfirmFunc asks for a command from a remote source to be allowed to
+// run. Returns true if it was confirmed.
+type ConfirmFunc func(ctx context.Context, cmd string, text string) (bool, error)
+
+// LaunchCheckFunc is called with the media a launch command resolved to,
+// before it's launched. The launch is refused if it returns an error.
+type LaunchCheckFunc func(path string, launcher string) error
+
 func forwardCmd(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult, error) {
 	return pl.ForwardCmd(env)
 }
@@ -122,8 +143,46 @@ func findFile(pl platforms.Platform, cfg *config.Instance, path string) (string,
 	return path, fmt.Errorf("file not found: %s", path)
 }
 
-// LaunchToken parses and runs a single ZapScript command.
+// parseNamedArgs splits the advanced args query string off the end of a
+// ZapScript command. Returns the remaining text and the named args.
+func parseNamedArgs(text string) (string, map[string]string, error) {
+	namedArgs := make(map[string]string)
+	if i := strings.LastIndex(text, "?"); i != -1 {
+		u, err := url.Parse(text[i:])
+		if err != nil {
+			return text, namedArgs, err
+		}
+
+		qs, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return text, namedArgs, err
+		}
+
+		text = text[:i]
+
+		for k, v := range qs {
+			namedArgs[k] = v[0]
+		}
+	}
+	return text, namedArgs, nil
+}
+
+// splitCommand splits an explicit command, with its ** prefix removed, into
+// the command name and its args.
+func splitCommand(text string) (string, string) {
+	ps := strings.SplitN(text, ":", 2)
+	cmd := strings.ToLower(strings.TrimSpace(ps[0]))
+	if len(ps) < 2 {
+		return cmd, ""
+	}
+	return cmd, strings.TrimSpace(ps[1])
+}
+
+// LaunchToken parses and runs a single ZapScript command. Commands blocked
+// for remote sources are run if the optional confirm function allows them,
+// and media is only launched if the optional check function allows it.
 func LaunchToken(
+	ctx context.Context,
 	pl platforms.Platform,
 	cfg *config.Instance,
 	plsc playlists.PlaylistController,
@@ -131,14 +190,16 @@ func LaunchToken(
 	text string,
 	totalCommands int,
 	currentIndex int,
+	confirm ConfirmFunc,
+	check LaunchCheckFunc,
 ) (platforms.CmdResult, error) {
 	var unsafe bool
-	newText, err := checkLink(cfg, pl, text)
+	link, err := checkLink(ctx, cfg, pl, text, false)
 	if err != nil {
 		log.Error().Err(err).Msgf("error checking link, continuing")
-	} else if newText != "" {
-		log.Info().Msgf("valid zap link, replacing text: %s", newText)
-		text = newText
+	} else if link.text != "" {
+		log.Info().Msgf("valid zap link, replacing text: %s", link.text)
+		text = link.text
 		unsafe = true
 	}
 
@@ -146,24 +207,9 @@ func LaunchToken(
 		unsafe = true
 	}
 
-	// parse advanced args
-	namedArgs := make(map[string]string)
-	if i := strings.LastIndex(text, "?"); i != -1 {
-		u, err := url.Parse(text[i:])
-		if err != nil {
-			return platforms.CmdResult{}, err
-		}
-
-		qs, err := url.ParseQuery(u.RawQuery)
-		if err != nil {
-			return platforms.CmdResult{}, err
-		}
-
-		text = text[:i]
-
-		for k, v := range qs {
-			namedArgs[k] = v[0]
-		}
+	text, namedArgs, perr := parseNamedArgs(text)
+	if perr != nil {
+		return platforms.CmdResult{}, perr
 	}
 	log.Debug().Msgf("named args: %v", namedArgs)
 
@@ -176,20 +222,10 @@ func LaunchToken(
 		}
 
 		text = strings.TrimPrefix(text, "**")
-		ps := strings.SplitN(text, ":", 2)
-
-		var cmd string
-		var args string
-
-		if len(ps) < 2 {
-			cmd = strings.ToLower(strings.TrimSpace(ps[0]))
-			args = ""
-		} else {
-			cmd = strings.ToLower(strings.TrimSpace(ps[0]))
-			args = strings.TrimSpace(ps[1])
-		}
+		cmd, args := splitCommand(text)
 
 		env := platforms.CmdEnv{
+			Ctx:           ctx,
 			Cmd:           cmd,
 			Args:          args,
 			NamedArgs:     namedArgs,
@@ -199,6 +235,17 @@ func LaunchToken(
 			TotalCommands: totalCommands,
 			CurrentIndex:  currentIndex,
 			Unsafe:        unsafe,
+			CheckLaunch:   check,
+		}
+
+		if unsafe && unsafeCmds[cmd] && confirm != nil {
+			ok, err := confirm(ctx, cmd, text)
+			if err != nil {
+				log.Error().Err(err).Msgf("error confirming command: %s", cmd)
+			} else if ok {
+				log.Info().Msgf("unsafe command confirmed by client: %s", cmd)
+				env.Unsafe = false
+			}
 		}
 
 		if f, ok := cmdMap[cmd]; ok {
@@ -218,6 +265,7 @@ func LaunchToken(
 
 	// if it's not a command, treat it as a generic launch command
 	res, err := cmdLaunch(pl, platforms.CmdEnv{
+		Ctx:           ctx,
 		Cmd:           "launch",
 		Args:          text,
 		NamedArgs:     namedArgs,
@@ -226,6 +274,7 @@ func LaunchToken(
 		TotalCommands: totalCommands,
 		CurrentIndex:  currentIndex,
 		Unsafe:        unsafe,
+		CheckLaunch:   check,
 	})
 
 	if err == nil && res.MediaChanged && t.Source != tokens.SourcePlaylist {
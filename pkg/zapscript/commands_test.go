@@ -0,0 +1,68 @@
+package zapscript
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNamedArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantText  string
+		wantNamed map[string]string
+	}{
+		{
+			name:      "no_args",
+			text:      "snes/Super Metroid.sfc",
+			wantText:  "snes/Super Metroid.sfc",
+			wantNamed: map[string]string{},
+		},
+		{
+			name:     "launcher",
+			text:     "snes/Super Metroid.sfc?launcher=SNESAlt",
+			wantText: "snes/Super Metroid.sfc",
+			wantNamed: map[string]string{
+				"launcher": "SNESAlt",
+			},
+		},
+		{
+			name:     "last_query_only",
+			text:     "**http.get:https://example.com/?a=1?b=2",
+			wantText: "**http.get:https://example.com/?a=1",
+			wantNamed: map[string]string{
+				"b": "2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, named, err := parseNamedArgs(tt.text)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantText, text)
+			assert.Equal(t, tt.wantNamed, named)
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		text     string
+		wantCmd  string
+		wantArgs string
+	}{
+		{"stop", "stop", ""},
+		{" Launch.Random : snes ", "launch.random", "snes"},
+		{"http.get:https://example.com", "http.get", "https://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			cmd, args := splitCommand(tt.text)
+			assert.Equal(t, tt.wantCmd, cmd)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
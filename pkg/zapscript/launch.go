@@ -47,6 +47,7 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         game.Path,
 		}, launch(game.Path)
 	}
 
@@ -74,6 +75,7 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         file,
 		}, launch(file)
 	}
 
@@ -114,6 +116,7 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         game.Path,
 		}, launch(game.Path)
 	}
 
@@ -137,9 +140,39 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 	return platforms.CmdResult{
 		MediaChanged: true,
+		Path:         game.Path,
 	}, launch(game.Path)
 }
 
+// checkedLaunch wraps a launch function so the media is passed to the
+// env's launch check first, if it has one.
+func checkedLaunch(
+	pl platforms.Platform,
+	env platforms.CmdEnv,
+	launch func(args string) error,
+) func(args string) error {
+	if env.CheckLaunch == nil {
+		return launch
+	}
+
+	return func(args string) error {
+		launcher := env.NamedArgs["launcher"]
+		if launcher == "" {
+			launchers := utils.PathToLaunchers(env.Cfg, pl, args)
+			if len(launchers) > 0 {
+				launcher = launchers[0].Id
+			}
+		}
+
+		err := env.CheckLaunch(args, launcher)
+		if err != nil {
+			return err
+		}
+
+		return launch(args)
+	}
+}
+
 func getAltLauncher(
 	pl platforms.Platform,
 	env platforms.CmdEnv,
@@ -158,15 +191,21 @@ func getAltLauncher(
 			return nil, fmt.Errorf("alt launcher not found: %s", env.NamedArgs["launcher"])
 		}
 
+		if env.DryRun {
+			return func(string) error { return nil }, nil
+		}
+
 		log.Info().Msgf("launching with alt launcher: %s", env.NamedArgs["launcher"])
 
-		return func(args string) error {
+		return checkedLaunch(pl, env, func(args string) error {
 			return launcher.Launch(env.Cfg, args)
-		}, nil
+		}), nil
+	} else if env.DryRun {
+		return func(string) error { return nil }, nil
 	} else {
-		return func(args string) error {
+		return checkedLaunch(pl, env, func(args string) error {
 			return pl.LaunchFile(env.Cfg, args)
-		}, nil
+		}), nil
 	}
 }
 
@@ -183,6 +222,7 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 		log.Debug().Msgf("launching absolute path: %s", env.Args)
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         env.Args,
 		}, launch(env.Args)
 	}
 
@@ -191,6 +231,7 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 		log.Debug().Msgf("launching uri: %s", env.Args)
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         env.Args,
 		}, launch(env.Args)
 	}
 
@@ -200,6 +241,7 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 		log.Debug().Msgf("launching found relative path: %s", p)
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         p,
 		}, launch(p)
 	} else {
 		log.Debug().Err(err).Msgf("error finding file: %s", env.Args)
@@ -243,6 +285,7 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 			log.Debug().Msgf("launching found system path: %s", fp)
 			return platforms.CmdResult{
 				MediaChanged: true,
+				Path:         fp,
 			}, launch(fp)
 		} else {
 			log.Debug().Err(err).Msgf("error finding system file: %s", path)
@@ -275,6 +318,7 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 			game := res[0]
 			return platforms.CmdResult{
 				MediaChanged: true,
+				Path:         game.Path,
 			}, launch(game.Path)
 		}
 	}
@@ -308,6 +352,7 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 		return platforms.CmdResult{
 			MediaChanged: true,
+			Path:         res[0].Path,
 		}, launch(res[0].Path)
 	}
 
@@ -346,5 +391,6 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult
 
 	return platforms.CmdResult{
 		MediaChanged: true,
+		Path:         res[0].Path,
 	}, launch(res[0].Path)
 }
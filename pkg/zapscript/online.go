@@ -1,17 +1,21 @@
 package zapscript
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
 	widgetModels "github.com/ZaparooProject/zaparoo-core/pkg/configui/widgets/models"
 	zapScriptModels "github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/systemdefs"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/rs/zerolog/log"
 )
@@ -35,8 +39,8 @@ func maybeRemoteZapScript(s string) bool {
 	}
 }
 
-func getRemoteZapScript(url string) (zapScriptModels.ZapScript, error) {
-	req, err := http.NewRequest("GET", url, nil)
+func getRemoteZapScript(ctx context.Context, url string) (zapScriptModels.ZapScript, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return zapScriptModels.ZapScript{}, err
 	}
@@ -100,23 +104,37 @@ func getRemoteZapScript(url string) (zapScriptModels.ZapScript, error) {
 	return zl, nil
 }
 
+// zapLink is the result of following a remote zap link.
+type zapLink struct {
+	// text is the ZapScript the link resolved to, or empty if the link
+	// was handled without one.
+	text string
+	// url is the download URL of the media a launch link installs.
+	url string
+}
+
+// checkLink follows a remote zap link. In dry run mode, linked media is not
+// downloaded and no widgets are shown, the returned text is the path the
+// media would be installed to.
 func checkLink(
+	ctx context.Context,
 	cfg *config.Instance,
 	pl platforms.Platform,
 	value string,
-) (string, error) {
+	dryRun bool,
+) (zapLink, error) {
 	if !maybeRemoteZapScript(value) {
-		return "", nil
+		return zapLink{}, nil
 	}
 
 	log.Info().Msgf("checking link: %s", value)
-	zl, err := getRemoteZapScript(value)
+	zl, err := getRemoteZapScript(ctx, value)
 	if err != nil {
-		return "", err
+		return zapLink{}, err
 	}
 
 	if len(zl.Cmds) == 0 {
-		return "", errors.New("no commands")
+		return zapLink{}, errors.New("no commands")
 	} else if len(zl.Cmds) > 1 {
 		log.Warn().Msgf("multiple commands in link, using first: %v", zl.Cmds[0])
 	}
@@ -129,26 +147,35 @@ func checkLink(
 		var args zapScriptModels.CmdEvaluateArgs
 		err = json.Unmarshal(cmd.Args, &args)
 		if err != nil {
-			return "", fmt.Errorf("error unmarshalling evaluate params: %w", err)
+			return zapLink{}, fmt.Errorf("error unmarshalling evaluate params: %w", err)
 		}
-		return args.ZapScript, nil
+		return zapLink{text: args.ZapScript}, nil
 	case zapScriptModels.ZapScriptCmdLaunch:
 		var args zapScriptModels.CmdLaunchArgs
 		err = json.Unmarshal(cmd.Args, &args)
 		if err != nil {
-			return "", fmt.Errorf("error unmarshalling launch args: %w", err)
+			return zapLink{}, fmt.Errorf("error unmarshalling launch args: %w", err)
 		}
 		if args.URL != nil && *args.URL != "" {
-			return methods.InstallRunMedia(cfg, pl, args)
+			var path string
+			if dryRun {
+				path, err = MediaInstallPath(pl, args)
+			} else {
+				path, err = InstallRunMedia(ctx, cfg, pl, args)
+			}
+			return zapLink{text: path, url: *args.URL}, err
 		} else {
 			// TODO: missing stuff like launcher arg
-			return args.Path, nil
+			return zapLink{text: args.Path}, nil
 		}
 	case zapScriptModels.ZapScriptCmdUIPicker:
+		if dryRun {
+			return zapLink{}, errors.New("picker links can't be resolved")
+		}
 		var cmdArgs zapScriptModels.CmdPicker
 		err = json.Unmarshal(cmd.Args, &cmdArgs)
 		if err != nil {
-			return "", fmt.Errorf("error unmarshalling picker args: %w", err)
+			return zapLink{}, fmt.Errorf("error unmarshalling picker args: %w", err)
 		}
 		pickerArgs := widgetModels.PickerArgs{
 			Items:  cmdArgs.Items,
@@ -159,12 +186,197 @@ func checkLink(
 		}
 		err := pl.ShowPicker(cfg, pickerArgs)
 		if err != nil {
-			return "", fmt.Errorf("error showing picker: %w", err)
+			return zapLink{}, fmt.Errorf("error showing picker: %w", err)
 		} else {
 			// TODO: this results in an error even though it's valid
-			return "", nil
+			return zapLink{}, nil
 		}
 	default:
-		return "", fmt.Errorf("unknown cmdName: %s", cmdName)
+		return zapLink{}, fmt.Errorf("unknown cmdName: %s", cmdName)
+	}
+}
+
+// MediaInstallPath returns the path remote media would be installed to,
+// without downloading it.
+func MediaInstallPath(
+	pl platforms.Platform,
+	launchArgs zapScriptModels.CmdLaunchArgs,
+) (string, error) {
+	if pl.Id() != platforms.PlatformIDMister {
+		return "", errors.New("media install only supported for mister")
+	}
+
+	if launchArgs.URL == nil {
+		return "", errors.New("media download url is empty")
+	} else if launchArgs.System == nil {
+		return "", errors.New("media system is empty")
+	}
+
+	system, err := systemdefs.LookupSystem(*launchArgs.System)
+	if err != nil {
+		return "", fmt.Errorf("error getting system: %w", err)
+	}
+
+	var launchers []platforms.Launcher
+	for _, l := range pl.Launchers() {
+		if l.SystemID == system.ID {
+			launchers = append(launchers, l)
+		}
+	}
+
+	if len(launchers) == 0 {
+		return "", fmt.Errorf("no launchers for system: %s", system.ID)
+	}
+
+	// just use the first launcher for now
+	launcher := launchers[0]
+
+	if launcher.Folders == nil {
+		return "", errors.New("no folders for launcher")
+	}
+
+	// just use the first folder for now
+	folder := launcher.Folders[0]
+
+	name := filepath.Base(*launchArgs.URL)
+
+	// roots := pl.RootDirs(cfg)
+
+	// if len(roots) == 0 {
+	// 	return "", errors.New("no root dirs")
+	// }
+
+	// root := roots[0]
+
+	root := "/media/fat/games" // TODO: this is hardcoded for now
+
+	path := filepath.Join(root, folder, name)
+
+	log.Debug().Msgf("media path: %s", path)
+
+	return path, nil
+}
+
+// InstallRunMedia downloads remote media to the games folder of its system,
+// if it doesn't already exist, and returns its path.
+func InstallRunMedia(
+	ctx context.Context,
+	cfg *config.Instance,
+	pl platforms.Platform,
+	launchArgs zapScriptModels.CmdLaunchArgs,
+) (string, error) {
+	path, err := MediaInstallPath(pl, launchArgs)
+	if err != nil {
+		return "", err
 	}
+
+	// check if the file already exists
+	if _, err := os.Stat(path); err == nil {
+		if launchArgs.PreNotice != nil && *launchArgs.PreNotice != "" {
+			hide, delay, err := pl.ShowNotice(cfg, widgetModels.NoticeArgs{
+				Text: *launchArgs.PreNotice,
+			})
+			if err != nil {
+				return "", fmt.Errorf("error showing pre-notice: %w", err)
+			}
+
+			if delay > 0 {
+				log.Debug().Msgf("delaying pre-notice: %d", delay)
+				select {
+				case <-ctx.Done():
+					return "", context.Cause(ctx)
+				case <-time.After(delay):
+				}
+			}
+
+			err = hide()
+			if err != nil {
+				return "", fmt.Errorf("error hiding pre-notice: %w", err)
+			}
+		}
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("error checking file: %w", err)
+	}
+
+	// download the file
+	log.Info().Msgf("downloading media: %s", *launchArgs.URL)
+
+	itemDisplay := *launchArgs.URL
+	if launchArgs.Name != nil && *launchArgs.Name != "" {
+		itemDisplay = *launchArgs.Name
+	}
+	loadingText := fmt.Sprintf("Downloading %s...", itemDisplay)
+
+	hideLoader, err := pl.ShowLoader(cfg, widgetModels.NoticeArgs{
+		Text: loadingText,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error showing loading dialog: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *launchArgs.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting url: %w", err)
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Error().Err(err).Msgf("closing body")
+		}
+	}(resp.Body)
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating file: %w", err)
+	}
+	defer func(File *os.File) {
+		err := File.Close()
+		if err != nil {
+			log.Error().Err(err).Msgf("closing file")
+		}
+	}(file)
+
+	_, err = io.Copy(file, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error copying file: %w", err)
+	}
+
+	err = hideLoader()
+	if err != nil {
+		return "", fmt.Errorf("error hiding loading dialog: %w", err)
+	}
+
+	if launchArgs.PreNotice != nil && *launchArgs.PreNotice != "" {
+		hide, delay, err := pl.ShowNotice(cfg, widgetModels.NoticeArgs{
+			Text: *launchArgs.PreNotice,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error showing pre-notice: %w", err)
+		}
+
+		if delay > 0 {
+			log.Debug().Msgf("delaying pre-notice: %d", delay)
+			select {
+			case <-ctx.Done():
+				return "", context.Cause(ctx)
+			case <-time.After(delay):
+			}
+		}
+
+		err = hide()
+		if err != nil {
+			return "", fmt.Errorf("error hiding pre-notice: %w", err)
+		}
+	}
+
+	return path, nil
 }
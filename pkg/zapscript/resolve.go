@@ -0,0 +1,115 @@
+package zapscript
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript/models"
+	"github.com/rs/zerolog/log"
+)
+
+// resolveCmdMap is the subset of commands which can be resolved to a media
+// path without launching anything.
+var resolveCmdMap = map[string]func(
+	platforms.Platform,
+	platforms.CmdEnv,
+) (platforms.CmdResult, error){
+	models.ZapScriptCmdLaunch:       cmdLaunch,
+	models.ZapScriptCmdLaunchRandom: cmdRandom,
+	models.ZapScriptCmdLaunchSearch: cmdSearch,
+	models.ZapScriptCmdRandom:       cmdRandom, // DEPRECATED
+}
+
+// Resolution is a summary of what a single ZapScript command would do if it
+// was run.
+type Resolution struct {
+	// Text is the command text after any zap link was followed.
+	Text    string
+	ZapLink bool
+	// Download is the URL of remote media a zap link would download to
+	// Path before launching it.
+	Download  string
+	Cmd       string
+	Args      string
+	NamedArgs map[string]string
+	// Path is the file path, URI or search result a launch command resolved
+	// to. Empty for commands which don't launch a specific file.
+	Path string
+	// Launcher is the ID of the launcher which would be used to launch Path.
+	Launcher string
+}
+
+// ResolveToken parses a single ZapScript command and reports what it would
+// do, without running it. Launch commands are resolved to the media and
+// launcher they would use, other commands are only parsed.
+func ResolveToken(
+	ctx context.Context,
+	pl platforms.Platform,
+	cfg *config.Instance,
+	text string,
+) (Resolution, error) {
+	res := Resolution{
+		Text: text,
+	}
+
+	link, err := checkLink(ctx, cfg, pl, text, true)
+	if err != nil {
+		log.Error().Err(err).Msgf("error checking link, continuing")
+	} else if link.text != "" {
+		log.Info().Msgf("valid zap link, replacing text: %s", link.text)
+		res.Text = link.text
+		res.ZapLink = true
+		res.Download = link.url
+	}
+
+	text, namedArgs, err := parseNamedArgs(res.Text)
+	if err != nil {
+		return res, err
+	}
+	res.NamedArgs = namedArgs
+
+	if strings.HasPrefix(text, "**") {
+		text = strings.TrimPrefix(text, "**")
+		res.Cmd, res.Args = splitCommand(text)
+		if _, ok := cmdMap[res.Cmd]; !ok {
+			return res, fmt.Errorf("unknown command: %s", res.Cmd)
+		}
+	} else {
+		res.Cmd = models.ZapScriptCmdLaunch
+		res.Args = text
+	}
+
+	f, ok := resolveCmdMap[res.Cmd]
+	if !ok {
+		return res, nil
+	}
+
+	cr, err := f(pl, platforms.CmdEnv{
+		Ctx:       ctx,
+		Cmd:       res.Cmd,
+		Args:      res.Args,
+		NamedArgs: namedArgs,
+		Cfg:       cfg,
+		Text:      text,
+		DryRun:    true,
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Path = cr.Path
+
+	if id := namedArgs["launcher"]; id != "" {
+		res.Launcher = id
+	} else if cr.Path != "" {
+		launchers := utils.PathToLaunchers(cfg, pl, cr.Path)
+		if len(launchers) > 0 {
+			res.Launcher = launchers[0].Id
+		}
+	}
+
+	return res, nil
+}
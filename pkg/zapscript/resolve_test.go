@@ -0,0 +1,200 @@
+package zapscript
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	widgetModels "github.com/ZaparooProject/zaparoo-core/pkg/configui/widgets/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sideEffectPlatform records every call which would launch media or show
+// something on screen. Any other unexpected call panics on the nil
+// embedded interface.
+type sideEffectPlatform struct {
+	platforms.Platform
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *sideEffectPlatform) record(call string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, call)
+}
+
+func (p *sideEffectPlatform) Id() string {
+	return platforms.PlatformIDMister
+}
+
+func (p *sideEffectPlatform) RootDirs(*config.Instance) []string {
+	return []string{"/media/fat/games"}
+}
+
+func (p *sideEffectPlatform) Launchers() []platforms.Launcher {
+	return []platforms.Launcher{
+		{
+			Id:         "SNES",
+			SystemID:   "SNES",
+			Folders:    []string{"SNES"},
+			Extensions: []string{".sfc"},
+			Launch: func(*config.Instance, string) error {
+				p.record("launcher SNES")
+				return nil
+			},
+		},
+		{
+			Id:         "SNESAlt",
+			SystemID:   "SNES",
+			Extensions: []string{".sfc"},
+			Launch: func(*config.Instance, string) error {
+				p.record("launcher SNESAlt")
+				return nil
+			},
+		},
+	}
+}
+
+func (p *sideEffectPlatform) LaunchFile(*config.Instance, string) error {
+	p.record("LaunchFile")
+	return nil
+}
+
+func (p *sideEffectPlatform) LaunchSystem(*config.Instance, string) error {
+	p.record("LaunchSystem")
+	return nil
+}
+
+func (p *sideEffectPlatform) KillLauncher() error {
+	p.record("KillLauncher")
+	return nil
+}
+
+func (p *sideEffectPlatform) ShowNotice(
+	*config.Instance,
+	widgetModels.NoticeArgs,
+) (func() error, time.Duration, error) {
+	p.record("ShowNotice")
+	return func() error { return nil }, 0, nil
+}
+
+func (p *sideEffectPlatform) ShowLoader(
+	*config.Instance,
+	widgetModels.NoticeArgs,
+) (func() error, error) {
+	p.record("ShowLoader")
+	return func() error { return nil }, nil
+}
+
+func (p *sideEffectPlatform) ShowPicker(*config.Instance, widgetModels.PickerArgs) error {
+	p.record("ShowPicker")
+	return nil
+}
+
+func TestResolveToken(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/link":
+			w.Header().Set("Content-Type", MIMEZaparooZapScript)
+			_, _ = w.Write([]byte(`{"zapscript":1,"cmds":[{"cmd":"launch","args":` +
+				`{"system":"SNES","url":"http://` + r.Host + `/download/game.sfc"}}]}`))
+		case "/picker":
+			w.Header().Set("Content-Type", MIMEZaparooZapScript)
+			_, _ = w.Write([]byte(`{"zapscript":1,"cmds":[{"cmd":"ui.picker","args":` +
+				`{"items":[]}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg, err := config.NewConfig(t.TempDir(), config.BaseDefaults)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		text         string
+		wantErr      bool
+		wantCmd      string
+		wantPath     string
+		wantLauncher string
+		wantDownload string
+	}{
+		{
+			name:         "absolute_path",
+			text:         "/media/fat/games/SNES/game.sfc",
+			wantCmd:      "launch",
+			wantPath:     "/media/fat/games/SNES/game.sfc",
+			wantLauncher: "SNES",
+		},
+		{
+			name:         "alt_launcher",
+			text:         "/media/fat/games/SNES/game.sfc?launcher=SNESAlt",
+			wantCmd:      "launch",
+			wantPath:     "/media/fat/games/SNES/game.sfc",
+			wantLauncher: "SNESAlt",
+		},
+		{
+			name:    "unknown_alt_launcher",
+			text:    "/media/fat/games/SNES/game.sfc?launcher=Missing",
+			wantErr: true,
+			wantCmd: "launch",
+		},
+		{
+			name:    "other_command",
+			text:    "**launch.system:snes",
+			wantCmd: "launch.system",
+		},
+		{
+			name:         "zap_link_download",
+			text:         srv.URL + "/link",
+			wantCmd:      "launch",
+			wantPath:     "/media/fat/games/SNES/game.sfc",
+			wantLauncher: "SNES",
+			wantDownload: srv.URL + "/download/game.sfc",
+		},
+		{
+			name:     "zap_link_picker",
+			text:     srv.URL + "/picker",
+			wantCmd:  "launch",
+			wantPath: srv.URL + "/picker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pl := &sideEffectPlatform{}
+
+			res, err := ResolveToken(context.Background(), pl, cfg, tt.text)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantCmd, res.Cmd)
+			assert.Equal(t, tt.wantPath, res.Path)
+			assert.Equal(t, tt.wantLauncher, res.Launcher)
+			assert.Equal(t, tt.wantDownload, res.Download)
+			assert.Empty(t, pl.calls)
+		})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NotContains(t, requested, "/download/game.sfc")
+}
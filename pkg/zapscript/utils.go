@@ -1,6 +1,7 @@
 package zapscript
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strconv"
@@ -27,7 +28,11 @@ func cmdDelay(_ platforms.Platform, env platforms.CmdEnv) (platforms.CmdResult,
 		return platforms.CmdResult{}, err
 	}
 
-	time.Sleep(time.Duration(amount) * time.Millisecond)
+	select {
+	case <-env.Ctx.Done():
+		return platforms.CmdResult{}, context.Cause(env.Ctx)
+	case <-time.After(time.Duration(amount) * time.Millisecond):
+	}
 
 	return platforms.CmdResult{}, nil
 }